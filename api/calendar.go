@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"whereismychildren/calendar"
+)
+
+// calendarPath - адрес выгрузки iCalendar. Приложения календаря не умеют передавать заголовки,
+// поэтому для него токен календаря (/api_token calendar) можно указать и в параметре ?token=
+const calendarPath = "/api/calendar.ics"
+
+// getCalendar выгружает уходы и деятельность в формате iCalendar за период
+// (?from=&to=, по умолчанию последние 30 дней) для подчиненного (?subordinate_id=) или группы (?group=)
+func (s *Server) getCalendar(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	to, err := parseDate(query.Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid to: %v", err))
+		return
+	}
+	from := to.AddDate(0, 0, -29)
+	if query.Get("from") != "" {
+		if from, err = parseDate(query.Get("from")); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from: %v", err))
+			return
+		}
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, errors.New("to is before from"))
+		return
+	}
+
+	var subordinateID int
+	if value := query.Get("subordinate_id"); value != "" {
+		if subordinateID, err = strconv.Atoi(value); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid subordinate_id: %v", err))
+			return
+		}
+	}
+
+	absences, err := s.db.GetAbsencesBetween(from, to, subordinateID, query.Get("group"))
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	name := "Где подчинённые"
+	if group := query.Get("group"); group != "" {
+		name += ": " + group
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="calendar.ics"`)
+	w.Write(calendar.Generate(calendar.Calendar{
+		Name:        name,
+		Absences:    absences,
+		GeneratedAt: time.Now(),
+	}))
+}
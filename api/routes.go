@@ -0,0 +1,215 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"whereismychildren/database"
+)
+
+func (s *Server) listSubordinates(w http.ResponseWriter, r *http.Request) {
+	var subordinates []database.Subordinate
+	var err error
+	if r.URL.Query().Get("archived") == "true" {
+		subordinates, err = s.db.GetSubordinatesIncludingArchived()
+	} else {
+		subordinates, err = s.db.GetAllSubordinates()
+	}
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	sort.Slice(subordinates, func(i, j int) bool {
+		if subordinates[i].LastName != subordinates[j].LastName {
+			return subordinates[i].LastName < subordinates[j].LastName
+		}
+		return subordinates[i].FirstName < subordinates[j].FirstName
+	})
+
+	if subordinates == nil {
+		subordinates = []database.Subordinate{}
+	}
+	writeJSON(w, http.StatusOK, subordinates)
+}
+
+func (s *Server) createSubordinate(w http.ResponseWriter, r *http.Request) {
+	var sub database.Subordinate
+	if err := decodeJSON(r, &sub); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := s.db.CreateSubordinate(database.Subordinate{
+		LastName:   sub.LastName,
+		FirstName:  sub.FirstName,
+		MiddleName: sub.MiddleName,
+		Group:      sub.Group,
+	})
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+type leaveRequest struct {
+	SubordinateID  int        `json:"subordinate_id"`
+	LeaveTime      *time.Time `json:"leave_time,omitempty"`
+	ExpectedReturn *time.Time `json:"expected_return,omitempty"`
+	// Заменить уход на то же время, если он уже есть; без этого на такой уход отвечаем 409
+	Replace bool `json:"replace,omitempty"`
+}
+
+func (s *Server) createLeave(w http.ResponseWriter, r *http.Request) {
+	var req leaveRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	leaveTime := eventTime(req.LeaveTime)
+	if err := checkExpectedReturn(leaveTime, req.ExpectedReturn); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	leave, err := s.db.RecordLeave(req.SubordinateID, leaveTime, req.Replace)
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	if req.ExpectedReturn != nil {
+		// Напоминание о невернувшемся получат администраторы и группы ответственных
+		if err := s.db.SetExpectedReturn(database.KindLeave, leave.ID, *req.ExpectedReturn, 0); err != nil {
+			writeRecordError(w, err)
+			return
+		}
+		leave.ExpectedReturn = req.ExpectedReturn
+	}
+
+	writeJSON(w, http.StatusCreated, leave)
+}
+
+type activityRequest struct {
+	SubordinateID  int        `json:"subordinate_id"`
+	ActivityTime   *time.Time `json:"activity_time,omitempty"`
+	Description    string     `json:"description"`
+	ExpectedReturn *time.Time `json:"expected_return,omitempty"`
+	Replace        bool       `json:"replace,omitempty"`
+}
+
+func (s *Server) createActivity(w http.ResponseWriter, r *http.Request) {
+	var req activityRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	activityTime := eventTime(req.ActivityTime)
+	if err := checkExpectedReturn(activityTime, req.ExpectedReturn); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	activity, err := s.db.RecordActivity(req.SubordinateID, activityTime, req.Description, req.Replace)
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	if req.ExpectedReturn != nil {
+		if err := s.db.SetExpectedReturn(database.KindActivity, activity.ID, *req.ExpectedReturn, 0); err != nil {
+			writeRecordError(w, err)
+			return
+		}
+		activity.ExpectedReturn = req.ExpectedReturn
+	}
+
+	writeJSON(w, http.StatusCreated, activity)
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	date, err := parseDate(r.URL.Query().Get("date"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid date: %v", err))
+		return
+	}
+
+	statuses, err := s.db.GetStatusesForDate(date)
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	present, left, activity := database.CountStatuses(statuses)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"date":     date.Format("2006-01-02"),
+		"present":  present,
+		"left":     left,
+		"activity": activity,
+		"statuses": statuses,
+	})
+}
+
+func (s *Server) getStatistics(w http.ResponseWriter, r *http.Request) {
+	from, err := parseDate(r.URL.Query().Get("from"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from: %v", err))
+		return
+	}
+	to, err := parseDate(r.URL.Query().Get("to"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid to: %v", err))
+		return
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, errors.New("to is before from"))
+		return
+	}
+
+	leaves, err := s.db.GetLeavesBetween(from, to)
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+	activities, err := s.db.GetUnplannedActivitiesBetween(from, to)
+	if err != nil {
+		writeRecordError(w, err)
+		return
+	}
+
+	if leaves == nil {
+		leaves = []database.LeaveRecord{}
+	}
+	if activities == nil {
+		activities = []database.ActivityRecord{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"leaves":     leaves,
+		"activities": activities,
+	})
+}
+
+// eventTime возвращает время события из запроса или текущее время
+func eventTime(value *time.Time) time.Time {
+	if value == nil {
+		return time.Now()
+	}
+	return value.In(time.Local)
+}
+
+func checkExpectedReturn(eventTime time.Time, expected *time.Time) error {
+	if expected != nil && !expected.After(eventTime) {
+		return errors.New("expected_return must be after the event time")
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"whereismychildren/database"
+)
+
+// Server - HTTP JSON API для интеграции с другими системами.
+// Все запросы требуют заголовок "Authorization: Bearer <токен>", токены выдаёт администратор командой /api_token.
+// Токен календаря открывает только calendarPath и только его можно передать в адресе
+type Server struct {
+	db     database.Store
+	server *http.Server
+}
+
+func NewServer(db database.Store, addr string) *Server {
+	s := &Server{db: db}
+	// Потоки событий не завершаются сами, поэтому при остановке сервера закрываем их через контекст
+	ctx, cancel := context.WithCancel(context.Background())
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	s.server.RegisterOnShutdown(cancel)
+	return s
+}
+
+// Handler возвращает обработчик всех маршрутов API с проверкой токена
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/subordinates", s.listSubordinates)
+	mux.HandleFunc("POST /api/subordinates", s.createSubordinate)
+	mux.HandleFunc("POST /api/leaves", s.createLeave)
+	mux.HandleFunc("POST /api/activities", s.createActivity)
+	mux.HandleFunc("GET /api/status", s.getStatus)
+	mux.HandleFunc("GET /api/statistics", s.getStatistics)
+	mux.Handle("GET /api/events", s.db.Events())
+	mux.HandleFunc("GET "+calendarPath, s.getCalendar)
+	return s.authenticate(mux)
+}
+
+// Start запускает сервер в фоне
+func (s *Server) Start() {
+	go func() {
+		log.Printf("API server listening on %s", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("API server error: %v", err)
+		}
+	}()
+}
+
+// Shutdown останавливает сервер, дожидаясь завершения текущих запросов
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		inURL := false
+		if !found && r.URL.Path == calendarPath {
+			token, found, inURL = r.URL.Query().Get("token"), true, true
+		}
+		if !found || token == "" {
+			writeError(w, http.StatusUnauthorized, errors.New("missing bearer token"))
+			return
+		}
+
+		apiToken, err := s.db.ValidateAPIToken(token)
+		if err != nil {
+			log.Printf("Error validating API token: %v", err)
+			writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+		if apiToken == nil {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+		// Адрес с токеном оседает в журналах прокси и в настройках календаря, поэтому в нём
+		// принимаются только токены календаря, которые ничего другого не открывают
+		if inURL && apiToken.Scope != database.TokenScopeCalendar {
+			writeError(w, http.StatusUnauthorized, errors.New("only calendar tokens may be passed in the URL, send this token in the Authorization header"))
+			return
+		}
+		if apiToken.Scope == database.TokenScopeCalendar && r.URL.Path != calendarPath {
+			writeError(w, http.StatusForbidden, errors.New("calendar token is valid only for "+calendarPath))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error encoding API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeRecordError отвечает на ошибку записи кодом, соответствующим правилу, которое нарушено
+func writeRecordError(w http.ResponseWriter, err error) {
+	// Запись на то же время заменяется только по явному "replace": true, как в боте - после подтверждения
+	var conflict *database.ConflictError
+	if errors.As(err, &conflict) {
+		existing := map[string]interface{}{
+			"kind": conflict.Kind,
+			"id":   conflict.ID,
+			"time": conflict.Time,
+		}
+		if conflict.Description != "" {
+			existing["description"] = conflict.Description
+		}
+		writeJSON(w, http.StatusConflict, map[string]interface{}{
+			"error":    err.Error() + `, send "replace": true to overwrite it`,
+			"conflict": existing,
+		})
+		return
+	}
+
+	switch err {
+	case database.ErrSubordinateNotFound:
+		writeError(w, http.StatusNotFound, err)
+	case database.ErrSubordinateArchived, database.ErrSubordinateExists:
+		writeError(w, http.StatusConflict, err)
+	case database.ErrInvalidName, database.ErrEmptyDescription:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		log.Printf("API error: %v", err)
+		writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+	}
+}
+
+func decodeJSON(r *http.Request, value interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(value)
+}
+
+// parseDate разбирает дату вида 2006-01-02 из параметра запроса; пустое значение - сегодня
+func parseDate(value string) (time.Time, error) {
+	if value == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"whereismychildren/api"
+	"whereismychildren/config"
+	"whereismychildren/database"
+	"whereismychildren/handlers"
+	"whereismychildren/scheduler"
+	"whereismychildren/web"
+	"whereismychildren/webhooks"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Run запускает бота с указанной конфигурацией и обрабатывает обновления, пока не отменён ctx
+func Run(ctx context.Context, cfg *config.Config) error {
+	if cfg.TelegramToken == "" {
+		return errors.New("TELEGRAM_TOKEN is required")
+	}
+
+	if len(cfg.AdminIDs) == 0 {
+		log.Println("Warning: ADMIN_IDS not set, some commands will be unavailable")
+	}
+
+	// Инициализация базы данных
+	db, err := database.Open(cfg.DBDriver, cfg.DatabaseSource())
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %v", err)
+	}
+	defer db.Close()
+	log.Printf("Using %s database", db.Driver())
+
+	// Инициализация бота
+	bot, err := newTelegramClient(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create bot: %v", err)
+	}
+
+	bot.Debug = true
+	log.Printf("Authorized on account %s", bot.Self.UserName)
+
+	// Инициализация обработчика с конфигом
+	handler := handlers.NewBotHandler(bot, bot.Self.UserName, db, cfg)
+
+	// Плановая рассылка отчётов подписчикам
+	sched := scheduler.New(cfg.Location)
+	for _, at := range cfg.SummaryTimes {
+		if err := sched.Daily("daily summary "+at, at, handler.SendScheduledSummary); err != nil {
+			log.Printf("Failed to schedule summary: %v", err)
+		}
+	}
+	// Вечерняя проверка тех, кто ещё не вернулся
+	if cfg.CurfewTime != "" {
+		if err := sched.Daily("curfew check", cfg.CurfewTime, handler.RunCurfewCheck); err != nil {
+			log.Printf("Failed to schedule curfew check: %v", err)
+		}
+	}
+
+	// Резервная копия базы SQLite; PostgreSQL копируется средствами сервера
+	if cfg.BackupDir != "" && db.Driver() != database.DriverPostgres {
+		if err := sched.Daily("database backup", cfg.BackupTime, handler.RunBackup); err != nil {
+			log.Printf("Failed to schedule backup: %v", err)
+		}
+	}
+
+	// Напоминания о невернувшихся к ожидаемому времени
+	sched.Every("overdue returns", time.Minute, handler.CheckOverdueReturns)
+	// Отправка событий на вебхуки с повторами при ошибках
+	sched.Every("webhook deliveries", 5*time.Second, webhooks.NewDispatcher(db).DeliverPending)
+	sched.Start()
+	defer sched.Stop()
+
+	// HTTP API для интеграции с другими системами
+	if cfg.APIAddr != "" {
+		apiServer := api.NewServer(db, cfg.APIAddr)
+		apiServer.Start()
+		defer apiServer.Shutdown(context.Background())
+	}
+
+	// Веб-панель "Где подчинённые" для компьютера дежурного
+	if cfg.WebAddr != "" {
+		webServer := web.NewServer(db, cfg.WebAddr)
+		webServer.Start()
+		defer webServer.Shutdown(context.Background())
+	}
+
+	// Настройка обновлений
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+
+	updates := bot.GetUpdatesChan(u)
+	defer bot.StopReceivingUpdates()
+
+	// Обработка сообщений
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Shutting down")
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			handleUpdate(bot, handler, cfg, update)
+		}
+	}
+}
+
+func handleUpdate(bot handlers.Sender, handler *handlers.BotHandler, cfg *config.Config, update tgbotapi.Update) {
+	// Ошибка в обработке одного обновления не должна останавливать бота
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Update %d handler panicked: %v", update.UpdateID, r)
+		}
+	}()
+
+	if update.CallbackQuery != nil {
+		handler.HandleCallback(update)
+		return
+	}
+
+	if update.InlineQuery != nil {
+		handler.HandleInlineQuery(update)
+		return
+	}
+
+	if update.Message != nil {
+		// Проверяем права для административных команд
+		if isAdminCommand(update.Message.Text) && !cfg.IsAdmin(update.Message.From.ID) {
+			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ У вас нет прав для выполнения этой команды")
+			bot.Send(msg)
+			return
+		}
+
+		handler.HandleMessage(update)
+	}
+}
+
+// isAdminCommand проверяет, является ли команда административной
+func isAdminCommand(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	adminCommands := []string{
+		"/add_excel",
+		"/stat excel",
+		"/api_token",
+		"/webhook",
+		"/backup",
+	}
+
+	for _, cmd := range adminCommands {
+		if strings.HasPrefix(text, cmd) {
+			return true
+		}
+	}
+	return false
+}
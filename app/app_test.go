@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"whereismychildren/bottest"
+	"whereismychildren/config"
+	"whereismychildren/database/dbtest"
+	"whereismychildren/telegramtest"
+	"whereismychildren/utils"
+)
+
+const timeout = 5 * time.Second
+
+// startBot запускает настоящий цикл бота против заглушки Bot API и останавливает его по окончании теста
+func startBot(t *testing.T) *telegramtest.Server {
+	t.Helper()
+
+	server := telegramtest.NewServer(t)
+	driver, dsn := dbtest.Source(t)
+	cfg := &config.Config{
+		TelegramToken:  telegramtest.Token,
+		TelegramAPIURL: server.URL,
+		DBDriver:       driver,
+		DBURL:          dsn,
+		AdminIDs:       []int64{bottest.AdminID},
+		Location:       time.UTC,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Run(ctx, cfg) }()
+
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("Run returned error: %v", err)
+			}
+		case <-time.After(timeout):
+			t.Error("Run did not stop after cancel")
+		}
+	})
+
+	return server
+}
+
+func TestImportExcelAndRecordLeave(t *testing.T) {
+	server := startBot(t)
+
+	server.SendDocument(bottest.AdminID, "/add_excel", "list.xlsx", bottest.SubordinatesExcel(t,
+		[]string{"Фамилия", "Имя", "Отчество"},
+		[]string{"Иванов", "Иван", "Иванович"},
+		[]string{"Петров", "Петр", "Петрович"},
+	))
+	server.WaitFor(t, "Добавлено 2 новых подчиненных", timeout)
+
+	server.SendText(bottest.UserID, "Иванов 14:30")
+	server.WaitFor(t, "Иванов Иван ушёл в 14:30", timeout)
+
+	today := utils.GetMoscowTime().Format("02.01.2006")
+	server.SendText(bottest.UserID, "/stat "+today)
+	stat := server.WaitFor(t, "Статистика за "+today, timeout)
+	if !strings.Contains(stat.Text, "Иванов Иван Иванович") || strings.Contains(stat.Text, "Петров") {
+		t.Fatalf("unexpected statistics: %q", stat.Text)
+	}
+}
+
+func TestRecordLeaveViaButton(t *testing.T) {
+	server := startBot(t)
+
+	server.SendDocument(bottest.AdminID, "/add_excel", "list.xlsx", bottest.SubordinatesExcel(t,
+		[]string{"Фамилия", "Имя", "Отчество"},
+		[]string{"Сидорова", "Анна", "Павловна"},
+	))
+	server.WaitFor(t, "Добавлено 1 новых подчиненных", timeout)
+
+	server.SendText(bottest.UserID, "Зафиксировать уход")
+	selection := server.WaitFor(t, "Выберите подчиненного", timeout)
+
+	server.PressButton(bottest.UserID, selection.MessageID, bottest.ButtonData(t, selection.ReplyMarkup, "Сидорова Анна"))
+	server.WaitFor(t, "Сидорова Анна ушёл в", timeout)
+}
+
+func TestAdminCommandsAreRejectedForOtherUsers(t *testing.T) {
+	server := startBot(t)
+
+	server.SendDocument(bottest.UserID, "/add_excel", "list.xlsx", bottest.SubordinatesExcel(t,
+		[]string{"Фамилия", "Имя", "Отчество"},
+		[]string{"Иванов", "Иван", "Иванович"},
+	))
+	server.WaitFor(t, "нет прав", timeout)
+
+	for _, sent := range server.Sent() {
+		if strings.Contains(sent.Text, "Добавлено") {
+			t.Fatalf("non-admin import must not add subordinates: %q", sent.Text)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"fmt"
+
+	"whereismychildren/config"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramClient - клиент Bot API с настраиваемым адресом. tgbotapi позволяет сменить адрес методов,
+// но ссылки на файлы всегда строит на api.telegram.org, поэтому их формируем сами
+type telegramClient struct {
+	*tgbotapi.BotAPI
+	fileEndpoint string
+}
+
+func newTelegramClient(cfg *config.Config) (*telegramClient, error) {
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(cfg.TelegramToken, cfg.TelegramAPIEndpoint())
+	if err != nil {
+		return nil, err
+	}
+	return &telegramClient{BotAPI: bot, fileEndpoint: cfg.TelegramFileEndpoint()}, nil
+}
+
+// GetFileDirectURL возвращает ссылку для скачивания файла с того же сервера Bot API
+func (c *telegramClient) GetFileDirectURL(fileID string) (string, error) {
+	file, err := c.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(c.fileEndpoint, c.Token, file.FilePath), nil
+}
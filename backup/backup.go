@@ -0,0 +1,202 @@
+// Package backup делает резервные копии базы SQLite по расписанию, хранит их по схеме
+// "ежедневные - еженедельные - ежемесячные" и восстанавливает базу из копии
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"whereismychildren/database"
+)
+
+const (
+	filePrefix = "bot-"
+	fileSuffix = ".db"
+	// Время в имени файла копии: bot-20261018-030000.db
+	timeLayout = "20060102-150405"
+)
+
+// ErrNoBackups - в каталоге ещё нет ни одной копии
+var ErrNoBackups = errors.New("no backups yet")
+
+// Retention - сколько копий хранить: последнюю копию каждого из Daily последних дней,
+// Weekly последних недель и Monthly последних месяцев. Самая свежая копия не удаляется никогда
+type Retention struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+// File - резервная копия в каталоге
+type File struct {
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// Manager создаёт копии базы в каталоге dir и удаляет лишние по правилам Retention
+type Manager struct {
+	db       database.Store
+	dir      string
+	keep     Retention
+	location *time.Location
+	mu       sync.Mutex
+}
+
+func New(db database.Store, dir string, keep Retention, location *time.Location) *Manager {
+	if location == nil {
+		location = time.Local
+	}
+	return &Manager{db: db, dir: dir, keep: keep, location: location}
+}
+
+// Run создаёт копию и логирует результат. Вызывается планировщиком
+func (m *Manager) Run() {
+	file, err := m.Create()
+	if err != nil {
+		log.Printf("Database backup failed: %v", err)
+		return
+	}
+	log.Printf("Database backup saved to %s (%d bytes)", file.Path, file.Size)
+}
+
+// Create сохраняет копию базы в каталог и удаляет копии, которые больше не нужно хранить
+func (m *Manager) Create() (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return File{}, err
+	}
+
+	// Копия пишется во временный файл, чтобы в каталоге не появлялись недописанные копии
+	createdAt := time.Now().In(m.location)
+	path := filepath.Join(m.dir, filePrefix+createdAt.Format(timeLayout)+fileSuffix)
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if err := m.db.Backup(tmp); err != nil {
+		os.Remove(tmp)
+		return File{}, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return File{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return File{}, err
+	}
+
+	if err := m.prune(); err != nil {
+		log.Printf("Failed to remove old backups: %v", err)
+	}
+
+	return File{Path: path, CreatedAt: createdAt, Size: info.Size()}, nil
+}
+
+// Latest возвращает самую свежую копию или ErrNoBackups
+func (m *Manager) Latest() (File, error) {
+	files, err := m.List()
+	if err != nil {
+		return File{}, err
+	}
+	if len(files) == 0 {
+		return File{}, ErrNoBackups
+	}
+	return files[0], nil
+}
+
+// List возвращает копии в каталоге, начиная с самой свежей
+func (m *Manager) List() ([]File, error) {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, filePrefix), fileSuffix), m.location)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, File{Path: filepath.Join(m.dir, name), CreatedAt: createdAt, Size: info.Size()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].CreatedAt.After(files[j].CreatedAt) })
+	return files, nil
+}
+
+// prune удаляет копии, которые не нужно хранить по правилам Retention
+func (m *Manager) prune() error {
+	files, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	kept := m.keep.kept(files)
+	for _, file := range files {
+		if kept[file.Path] {
+			continue
+		}
+		if err := os.Remove(file.Path); err != nil {
+			return err
+		}
+		log.Printf("Removed old backup %s", file.Path)
+	}
+	return nil
+}
+
+// kept возвращает пути копий, которые нужно хранить. files отсортированы от самой свежей:
+// в каждом дне, неделе и месяце хранится последняя копия, пока не набрано нужное число периодов
+func (r Retention) kept(files []File) map[string]bool {
+	kept := make(map[string]bool)
+	if len(files) > 0 {
+		kept[files[0].Path] = true
+	}
+
+	periods := []struct {
+		limit int
+		key   func(t time.Time) string
+	}{
+		{r.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{r.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, period := range periods {
+		seen := make(map[string]bool)
+		for _, file := range files {
+			if len(seen) >= period.limit {
+				break
+			}
+			key := period.key(file.CreatedAt)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			kept[file.Path] = true
+		}
+	}
+	return kept
+}
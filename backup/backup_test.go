@@ -0,0 +1,252 @@
+package backup
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/database/dbtest"
+)
+
+func TestRetentionKeepsLatestCopyOfEachPeriod(t *testing.T) {
+	// Копии каждый день в 03:00 с 1 сентября по 18 октября 2026 (воскресенье), от самой свежей
+	var files []File
+	for day := time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC); !day.Before(time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)); day = day.AddDate(0, 0, -1) {
+		files = append(files, File{Path: day.Format("2006-01-02"), CreatedAt: day})
+	}
+
+	kept := Retention{Daily: 3, Weekly: 2, Monthly: 2}.kept(files)
+
+	want := []string{
+		"2026-10-18", "2026-10-17", "2026-10-16", // последние три дня
+		"2026-10-11", // предыдущая неделя (последняя неделя - это 18.10)
+		"2026-09-30", // предыдущий месяц
+	}
+	if len(kept) != len(want) {
+		t.Fatalf("expected %d kept backups, got %v", len(want), kept)
+	}
+	for _, path := range want {
+		if !kept[path] {
+			t.Errorf("expected %s to be kept, got %v", path, kept)
+		}
+	}
+}
+
+func TestRetentionAlwaysKeepsNewest(t *testing.T) {
+	files := []File{
+		{Path: "new", CreatedAt: time.Date(2026, 10, 18, 3, 0, 0, 0, time.UTC)},
+		{Path: "old", CreatedAt: time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)},
+	}
+
+	kept := Retention{}.kept(files)
+	if len(kept) != 1 || !kept["new"] {
+		t.Fatalf("expected only the newest backup to be kept, got %v", kept)
+	}
+}
+
+func TestCreateRemovesOldBackups(t *testing.T) {
+	db := openFileDB(t, filepath.Join(t.TempDir(), "bot.db"))
+	dir := t.TempDir()
+
+	// Копии за прошлые дни: по правилу "3 дня" останутся две последние и новая
+	for _, name := range []string{"bot-20261001-030000.db", "bot-20261002-030000.db", "bot-20261003-030000.db", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("old"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager := New(db, dir, Retention{Daily: 3}, time.UTC)
+	file, err := manager.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := manager.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Path != file.Path || latest.Size == 0 {
+		t.Fatalf("expected latest backup %s, got %+v", file.Path, latest)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	got := strings.Join(names, " ")
+	want := "bot-20261002-030000.db bot-20261003-030000.db " + filepath.Base(file.Path) + " notes.txt"
+	if got != want {
+		t.Fatalf("expected files %q, got %q", want, got)
+	}
+}
+
+func TestLatestWithoutBackups(t *testing.T) {
+	manager := New(nil, filepath.Join(t.TempDir(), "missing"), Retention{Daily: 7}, time.UTC)
+	if _, err := manager.Latest(); err != ErrNoBackups {
+		t.Fatalf("expected ErrNoBackups, got %v", err)
+	}
+}
+
+func TestRestoreReplacesDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "bot.db")
+	db := openFileDB(t, dbPath)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+	if _, err := db.RecordLeave(subs[0].ID, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := New(db, t.TempDir(), Retention{Daily: 7}, time.Local).Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// После копии появился ещё один подчиненный - восстановление его уберёт
+	dbtest.AddSubordinates(t, db, "Петров Петр Петрович")
+	db.Close()
+
+	previous, err := Restore(dbtest.Driver(), file.Path, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(previous); err != nil {
+		t.Fatalf("expected previous database to be kept: %v", err)
+	}
+
+	restored := openFileDB(t, dbPath)
+	all, err := restored.GetAllSubordinates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].LastName != "Иванов" {
+		t.Fatalf("expected only Иванов after restore, got %+v", all)
+	}
+	if _, err := restored.GetLeaveForDate(all[0].ID, time.Now()); err != nil {
+		t.Fatalf("expected restored leave: %v", err)
+	}
+}
+
+// Бот остановился посреди записи: в файле базы половина транзакции, а горячий журнал хранит прежние страницы.
+// Сохранённая при восстановлении копия должна содержать данные до прерванной транзакции
+func TestRestoreKeepsConsistentCopyOfInterruptedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	livePath := filepath.Join(dir, "live.db")
+	db := openFileDB(t, livePath)
+	dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+	file, err := New(db, t.TempDir(), Retention{Daily: 7}, time.Local).Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Маленький кеш заставляет SQLite записывать страницы в файл базы до фиксации транзакции
+	raw, err := sql.Open(database.DriverSQLitePure, livePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	raw.SetMaxOpenConns(1)
+	if _, err := raw.Exec("PRAGMA cache_size = 2"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := raw.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 500; i++ {
+		if _, err := tx.Exec("INSERT INTO subordinates (last_name, first_name) VALUES (?, ?)",
+			fmt.Sprintf("Недописанный%03d", i), strings.Repeat("Имя", 50)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Снимок файлов в этот момент - то, что осталось бы на диске после сбоя
+	dbPath := filepath.Join(dir, "bot.db")
+	for _, suffix := range []string{"", "-journal"} {
+		data, err := os.ReadFile(livePath + suffix)
+		if err != nil {
+			t.Fatalf("expected %s to exist mid-transaction: %v", "live.db"+suffix, err)
+		}
+		if err := os.WriteFile(dbPath+suffix, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx.Rollback()
+
+	previous, err := Restore(dbtest.Driver(), file.Path, dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := database.ValidateBackup(dbtest.Driver(), previous); err != nil {
+		t.Fatalf("previous database copy is damaged: %v", err)
+	}
+	saved := openFileDB(t, previous)
+	all, err := saved.GetAllSubordinates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].LastName != "Иванов" {
+		t.Fatalf("expected the copy to hold only committed data, got %d subordinates", len(all))
+	}
+}
+
+func TestRestoreRejectsInvalidFiles(t *testing.T) {
+	if dbtest.Driver() == database.DriverPostgres {
+		t.Skip("backups are SQLite only")
+	}
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("это не база данных"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	foreign := filepath.Join(dir, "foreign.db")
+	other, err := sql.Open(database.DriverSQLitePure, foreign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Exec("CREATE TABLE subordinates (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	other.Close()
+
+	dbPath := filepath.Join(dir, "bot.db")
+	if err := os.WriteFile(dbPath, []byte("current"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, src := range []string{garbage, foreign, filepath.Join(dir, "missing.db")} {
+		if _, err := Restore(dbtest.Driver(), src, dbPath); err == nil {
+			t.Errorf("expected %s to be rejected", filepath.Base(src))
+		}
+	}
+
+	if data, err := os.ReadFile(dbPath); err != nil || string(data) != "current" {
+		t.Fatalf("current database must stay untouched, got %q (%v)", data, err)
+	}
+}
+
+// openFileDB открывает базу SQLite в файле драйвером тестов; с PostgreSQL тест пропускается
+func openFileDB(t *testing.T, path string) *database.DB {
+	t.Helper()
+
+	if dbtest.Driver() == database.DriverPostgres {
+		t.Skip("backups are SQLite only")
+	}
+	db, err := database.Open(dbtest.Driver(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"whereismychildren/database"
+)
+
+// Restore заменяет файл базы dbPath копией src. Бот в это время должен быть остановлен.
+// Копия проверяется (целостность и схема бота), миграции применяются к ней заранее,
+// а прежняя база сохраняется рядом в dbPath.before-restore-<время>, путь к ней возвращается.
+// Новая база подменяет старую переименованием, поэтому при ошибке на любом шаге старая остаётся на месте.
+// Если прежнюю базу не удаётся открыть (например, она повреждена), её журналы сохраняются рядом с копией
+// под теми же суффиксами, и SQLite применит их при открытии копии
+func Restore(driver, src, dbPath string) (previous string, err error) {
+	if err := database.ValidateBackup(driver, src); err != nil {
+		return "", fmt.Errorf("invalid backup: %v", err)
+	}
+
+	tmp := dbPath + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+
+	// Открытие базы применяет миграции: если копия от прежней версии не откроется, заменять нечего
+	db, err := database.NewSQLiteDB(driver, tmp)
+	if err != nil {
+		return "", fmt.Errorf("failed to open backup: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		// Сначала SQLite завершает работу с журналами прежней базы: без них файл базы после сбоя
+		// может содержать половину транзакции, и такая копия была бы испорчена
+		recoverErr := database.RecoverJournal(driver, dbPath)
+		if recoverErr != nil {
+			log.Printf("Failed to recover journal of %s before restore: %v", dbPath, recoverErr)
+		}
+
+		previous = dbPath + ".before-restore-" + time.Now().Format(timeLayout)
+		if err := copyFile(dbPath, previous); err != nil {
+			return "", err
+		}
+		if recoverErr != nil {
+			for _, suffix := range []string{"-journal", "-wal"} {
+				if err := copyFile(dbPath+suffix, previous+suffix); err != nil && !os.IsNotExist(err) {
+					return "", err
+				}
+			}
+		}
+	}
+
+	// Журнал от прежней базы SQLite применил бы к новой чужие страницы
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// copyFile копирует файл и дожидается его записи на диск
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,85 @@
+// Package bottest - общие данные и помощники для тестов обработчика (handlers)
+// и интеграционных тестов бота (app): пользователи, кнопки клавиатур и списки подчиненных в Excel
+package bottest
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/xuri/excelize/v2"
+)
+
+// Пользователи тестов: администратор бота и обычный пользователь
+const (
+	AdminID = 1001
+	UserID  = 2002
+)
+
+// ButtonData находит в клавиатуре кнопку с подстрокой label в тексте и возвращает её данные.
+// replyMarkup - клавиатура из отправленного сообщения: tgbotapi.InlineKeyboardMarkup
+// или её JSON, как его получает заглушка Bot API
+func ButtonData(t testing.TB, replyMarkup interface{}, label string) string {
+	t.Helper()
+
+	var keyboard tgbotapi.InlineKeyboardMarkup
+	switch markup := replyMarkup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		keyboard = markup
+	case *tgbotapi.InlineKeyboardMarkup:
+		keyboard = *markup
+	case string:
+		if err := json.Unmarshal([]byte(markup), &keyboard); err != nil {
+			t.Fatalf("reply markup %q is not an inline keyboard: %v", markup, err)
+		}
+	default:
+		t.Fatalf("message has no inline keyboard: %#v", replyMarkup)
+	}
+
+	var labels []string
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if strings.Contains(button.Text, label) && button.CallbackData != nil {
+				return *button.CallbackData
+			}
+			labels = append(labels, button.Text)
+		}
+	}
+	t.Fatalf("no button %q among %q", label, labels)
+	return ""
+}
+
+// SubordinatesExcel возвращает файл Excel со строками rows на первом листе, как его загружают в /add_excel
+func SubordinatesExcel(t testing.TB, rows ...[]string) []byte {
+	t.Helper()
+
+	f := excelize.NewFile()
+	defer f.Close()
+	for i, row := range rows {
+		for j, value := range row {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+1)
+			f.SetCellValue("Sheet1", cell, value)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := f.Write(&buf); err != nil {
+		t.Fatalf("failed to write excel: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// WriteSubordinatesExcel сохраняет SubordinatesExcel во временный файл теста и возвращает путь к нему
+func WriteSubordinatesExcel(t testing.TB, rows ...[]string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "list.xlsx")
+	if err := os.WriteFile(path, SubordinatesExcel(t, rows...), 0o600); err != nil {
+		t.Fatalf("failed to write excel: %v", err)
+	}
+	return path
+}
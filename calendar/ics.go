@@ -0,0 +1,141 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"whereismychildren/database"
+)
+
+const (
+	// Длительность события, если ни возвращение, ни ожидаемое время не известны
+	defaultDuration = time.Hour
+	// Максимальная длина строки iCalendar в байтах (RFC 5545, 3.1)
+	maxLineLength = 75
+	timeFormat    = "20060102T150405Z"
+)
+
+// Calendar содержит данные для выгрузки в формате iCalendar (.ics)
+type Calendar struct {
+	Name        string
+	Absences    []database.Absence
+	GeneratedAt time.Time
+}
+
+// Generate формирует файл iCalendar: каждый уход и каждая внеплановая деятельность - отдельное событие
+// от времени начала до возвращения (или ожидаемого возвращения)
+func Generate(c Calendar) []byte {
+	var buf bytes.Buffer
+
+	writeLine(&buf, "BEGIN:VCALENDAR")
+	writeLine(&buf, "VERSION:2.0")
+	writeLine(&buf, "PRODID:-//whereismychildren//Attendance//RU")
+	writeLine(&buf, "CALSCALE:GREGORIAN")
+	writeLine(&buf, "METHOD:PUBLISH")
+	writeLine(&buf, "X-WR-CALNAME:"+escapeText(c.Name))
+
+	stamp := c.GeneratedAt.UTC().Format(timeFormat)
+	for _, absence := range c.Absences {
+		start, end := eventBounds(absence)
+
+		writeLine(&buf, "BEGIN:VEVENT")
+		writeLine(&buf, fmt.Sprintf("UID:%s-%d@whereismychildren", absence.Kind, absence.ID))
+		writeLine(&buf, "DTSTAMP:"+stamp)
+		writeLine(&buf, "DTSTART:"+start.UTC().Format(timeFormat))
+		writeLine(&buf, "DTEND:"+end.UTC().Format(timeFormat))
+		writeLine(&buf, "SUMMARY:"+escapeText(summary(absence)))
+		writeLine(&buf, "DESCRIPTION:"+escapeText(description(absence)))
+		if absence.Subordinate.Group != "" {
+			writeLine(&buf, "CATEGORIES:"+escapeText(absence.Subordinate.Group))
+		}
+		writeLine(&buf, "END:VEVENT")
+	}
+
+	writeLine(&buf, "END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// eventBounds возвращает начало и конец события
+func eventBounds(absence database.Absence) (time.Time, time.Time) {
+	start := absence.Start
+	end := start.Add(defaultDuration)
+
+	switch {
+	case absence.ReturnedAt != nil && absence.ReturnedAt.After(start):
+		end = *absence.ReturnedAt
+	case absence.ExpectedReturn != nil && absence.ExpectedReturn.After(start):
+		end = *absence.ExpectedReturn
+	}
+
+	return start, end
+}
+
+func fullName(sub database.Subordinate) string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", sub.LastName, sub.FirstName, sub.MiddleName))
+}
+
+func summary(absence database.Absence) string {
+	name := absence.Subordinate.LastName + " " + absence.Subordinate.FirstName
+	if absence.Kind == database.KindActivity {
+		return fmt.Sprintf("%s: %s", name, absence.Description)
+	}
+	return name + ": уход"
+}
+
+func description(absence database.Absence) string {
+	lines := []string{fullName(absence.Subordinate)}
+	if absence.Subordinate.Group != "" {
+		lines = append(lines, "Группа: "+absence.Subordinate.Group)
+	}
+
+	if absence.Kind == database.KindActivity {
+		lines = append(lines, "Внеплановая деятельность: "+absence.Description)
+	} else {
+		lines = append(lines, "Уход в "+absence.Start.Format("15:04"))
+	}
+
+	switch {
+	case absence.ReturnedAt != nil:
+		lines = append(lines, "Вернулся в "+absence.ReturnedAt.Format("15:04"))
+	case absence.StaysOut:
+		lines = append(lines, "Остаётся вне до следующего дня")
+	case absence.ExpectedReturn != nil:
+		lines = append(lines, "Ожидаемое возвращение: "+absence.ExpectedReturn.Format("15:04"))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// escapeText экранирует значение свойства типа TEXT (RFC 5545, 3.3.11)
+func escapeText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// writeLine записывает строку с переносом длинных строк: продолжение начинается с пробела,
+// а многобайтовые символы UTF-8 не разрываются
+func writeLine(buf *bytes.Buffer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Пробел в начале строки продолжения входит в её длину
+		limit = maxLineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
@@ -0,0 +1,281 @@
+package charts
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	chartWidth  = 900
+	chartHeight = 500
+	marginLeft  = 60
+	marginRight = 20
+	marginTop   = 60
+	marginBot   = 50
+	barRowH     = 24
+)
+
+var (
+	// Цвета серий по умолчанию
+	ColorLeaves     = color.RGBA{66, 133, 244, 255}
+	ColorActivities = color.RGBA{251, 140, 0, 255}
+
+	colorBackground = color.RGBA{255, 255, 255, 255}
+	colorAxis       = color.RGBA{90, 90, 90, 255}
+	colorGrid       = color.RGBA{225, 225, 225, 255}
+	colorText       = color.RGBA{30, 30, 30, 255}
+)
+
+// Series - набор значений одной серии с подписью для легенды
+type Series struct {
+	Name   string
+	Values []float64
+	Color  color.RGBA
+}
+
+// BarChart описывает столбчатую диаграмму: категории по оси X и одну или несколько серий
+type BarChart struct {
+	Title  string
+	Labels []string
+	Series []Series
+}
+
+// RenderBars рисует вертикальную диаграмму со сгруппированными столбцами и возвращает PNG
+func RenderBars(chart BarChart) ([]byte, error) {
+	face, err := newFace(13)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	img := newCanvas(chartWidth, chartHeight)
+	drawHeader(img, face, chart)
+
+	plot := image.Rect(marginLeft, marginTop, chartWidth-marginRight, chartHeight-marginBot)
+	maxValue, step := scale(chart.Series)
+
+	// Сетка и подписи значений по оси Y
+	for v := 0.0; v <= maxValue; v += step {
+		y := plot.Max.Y - int(float64(plot.Dy())*v/maxValue)
+		fillRect(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), colorGrid)
+		label := formatValue(v)
+		drawText(img, face, label, plot.Min.X-8-textWidth(face, label), y+5)
+	}
+
+	n := len(chart.Labels)
+	if n > 0 && len(chart.Series) > 0 {
+		groupW := float64(plot.Dx()) / float64(n)
+		barW := groupW * 0.8 / float64(len(chart.Series))
+
+		// Если подписи не помещаются, выводим каждую k-ю
+		labelEvery := 1
+		for _, label := range chart.Labels {
+			for float64(textWidth(face, label)+6) > groupW*float64(labelEvery) {
+				labelEvery++
+			}
+		}
+
+		for i, label := range chart.Labels {
+			groupX := float64(plot.Min.X) + groupW*float64(i) + groupW*0.1
+			for j, series := range chart.Series {
+				if i >= len(series.Values) {
+					continue
+				}
+				h := int(float64(plot.Dy()) * series.Values[i] / maxValue)
+				x0 := int(groupX + barW*float64(j))
+				x1 := int(groupX + barW*float64(j+1))
+				if x1-x0 > 2 {
+					x1-- // зазор между столбцами
+				}
+				fillRect(img, image.Rect(x0, plot.Max.Y-h, x1, plot.Max.Y), series.Color)
+			}
+
+			if i%labelEvery == 0 {
+				center := float64(plot.Min.X) + groupW*(float64(i)+0.5)
+				drawText(img, face, label, int(center)-textWidth(face, label)/2, plot.Max.Y+18)
+			}
+		}
+	}
+
+	drawAxes(img, plot)
+	return encode(img)
+}
+
+// RenderHorizontalBars рисует горизонтальную диаграмму с накоплением серий.
+// Подходит для длинных подписей, например списка подчиненных. Высота зависит от числа категорий.
+func RenderHorizontalBars(chart BarChart) ([]byte, error) {
+	face, err := newFace(13)
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	height := marginTop + len(chart.Labels)*barRowH + marginBot
+	if height < 200 {
+		height = 200
+	}
+	img := newCanvas(chartWidth, height)
+	drawHeader(img, face, chart)
+
+	labelW := 0
+	for _, label := range chart.Labels {
+		if w := textWidth(face, label); w > labelW {
+			labelW = w
+		}
+	}
+	if labelW > chartWidth/3 {
+		labelW = chartWidth / 3
+	}
+
+	plot := image.Rect(labelW+20, marginTop, chartWidth-marginRight-40, marginTop+len(chart.Labels)*barRowH)
+
+	totals := make([]float64, len(chart.Labels))
+	for _, series := range chart.Series {
+		for i, v := range series.Values {
+			if i < len(totals) {
+				totals[i] += v
+			}
+		}
+	}
+	maxValue, step := scale([]Series{{Values: totals}})
+
+	for v := 0.0; v <= maxValue; v += step {
+		x := plot.Min.X + int(float64(plot.Dx())*v/maxValue)
+		fillRect(img, image.Rect(x, plot.Min.Y, x+1, plot.Max.Y), colorGrid)
+		label := formatValue(v)
+		drawText(img, face, label, x-textWidth(face, label)/2, plot.Max.Y+18)
+	}
+
+	for i, label := range chart.Labels {
+		y0 := plot.Min.Y + i*barRowH + 4
+		y1 := y0 + barRowH - 8
+
+		drawText(img, face, truncate(face, label, labelW), 10, y0+barRowH/2+1)
+
+		x := plot.Min.X
+		for _, series := range chart.Series {
+			if i >= len(series.Values) {
+				continue
+			}
+			w := int(float64(plot.Dx()) * series.Values[i] / maxValue)
+			fillRect(img, image.Rect(x, y0, x+w, y1), series.Color)
+			x += w
+		}
+		drawText(img, face, formatValue(totals[i]), x+6, y0+barRowH/2+1)
+	}
+
+	drawAxes(img, plot)
+	return encode(img)
+}
+
+func newFace(size float64) (font.Face, error) {
+	ttf, err := opentype.Parse(goregular.TTF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %v", err)
+	}
+	return opentype.NewFace(ttf, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+func newCanvas(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(colorBackground), image.Point{}, draw.Src)
+	return img
+}
+
+// drawHeader выводит заголовок и легенду диаграммы
+func drawHeader(img *image.RGBA, face font.Face, chart BarChart) {
+	drawText(img, face, chart.Title, marginLeft, 22)
+
+	x := marginLeft
+	for _, series := range chart.Series {
+		if series.Name == "" {
+			continue
+		}
+		fillRect(img, image.Rect(x, 33, x+12, 45), series.Color)
+		drawText(img, face, series.Name, x+18, 44)
+		x += 18 + textWidth(face, series.Name) + 24
+	}
+}
+
+func drawAxes(img *image.RGBA, plot image.Rectangle) {
+	fillRect(img, image.Rect(plot.Min.X, plot.Min.Y, plot.Min.X+1, plot.Max.Y+1), colorAxis)
+	fillRect(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+1), colorAxis)
+}
+
+func fillRect(img *image.RGBA, rect image.Rectangle, c color.RGBA) {
+	draw.Draw(img, rect, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func drawText(img *image.RGBA, face font.Face, text string, x, y int) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(colorText),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func textWidth(face font.Face, text string) int {
+	return font.MeasureString(face, text).Ceil()
+}
+
+// truncate обрезает подпись по ширине, добавляя многоточие
+func truncate(face font.Face, text string, width int) string {
+	if textWidth(face, text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && textWidth(face, string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+// scale подбирает верхнюю границу оси и шаг сетки с целыми значениями
+func scale(series []Series) (float64, float64) {
+	maxValue := 0.0
+	for _, s := range series {
+		for _, v := range s.Values {
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	if maxValue < 1 {
+		maxValue = 1
+	}
+
+	step := 1.0
+	for _, candidate := range []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000} {
+		step = candidate
+		if maxValue/candidate <= 6 {
+			break
+		}
+	}
+
+	return math.Ceil(maxValue/step) * step, step
+}
+
+func formatValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%d", int(v))
+	}
+	return fmt.Sprintf("%.1f", v)
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
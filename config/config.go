@@ -5,14 +5,43 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	TelegramToken string
-	DBPath        string
-	AdminIDs      []int64
+	// Адрес Bot API: https://api.telegram.org, собственный сервер telegram-bot-api или тестовая заглушка
+	TelegramAPIURL string
+	// База данных: sqlite (файл DB_PATH, драйвер на чистом Go, по умолчанию) или sqlite3 (тот же файл,
+	// драйвер с CGO) либо postgres (строка подключения DB_URL)
+	DBDriver     string
+	DBPath       string
+	DBURL        string
+	AdminIDs     []int64
+	Location     *time.Location
+	SummaryTimes []string
+
+	// Ожидаемая длительность отсутствия по умолчанию (0 - не отслеживать)
+	LeaveReturnAfter    time.Duration
+	ActivityReturnAfter time.Duration
+	// Интервал повторных напоминаний о невернувшихся
+	OverdueRepeatInterval time.Duration
+	// Время вечерней проверки (отбоя), пусто - проверка отключена
+	CurfewTime string
+	// Адрес HTTP API (например, 127.0.0.1:8080), пусто - API отключено
+	APIAddr string
+	// Адрес веб-панели и её внешний адрес для ссылок входа, пусто - панель отключена
+	WebAddr string
+	WebURL  string
+	// Каталог резервных копий базы SQLite и время ежедневного копирования, пусто - копирование отключено
+	BackupDir  string
+	BackupTime string
+	// Сколько хранить копий: по одной за каждый из последних дней, недель и месяцев
+	BackupKeepDaily   int
+	BackupKeepWeekly  int
+	BackupKeepMonthly int
 }
 
 func Load() *Config {
@@ -24,10 +53,58 @@ func Load() *Config {
 	adminIDs := parseAdminIDs(os.Getenv("ADMIN_IDS"))
 
 	return &Config{
-		TelegramToken: os.Getenv("TELEGRAM_TOKEN"),
-		DBPath:        getEnv("DB_PATH", "bot.db"),
-		AdminIDs:      adminIDs,
+		TelegramToken:  os.Getenv("TELEGRAM_TOKEN"),
+		TelegramAPIURL: strings.TrimSuffix(getEnv("TELEGRAM_API_URL", "https://api.telegram.org"), "/"),
+		DBDriver:       getEnv("DB_DRIVER", "sqlite"),
+		DBPath:         getEnv("DB_PATH", "bot.db"),
+		DBURL:          os.Getenv("DB_URL"),
+		AdminIDs:       adminIDs,
+		Location:       loadLocation(getEnv("TIMEZONE", "Europe/Moscow")),
+		SummaryTimes:   parseClockTimes(getEnv("SUMMARY_TIMES", "09:00,21:00")),
+
+		LeaveReturnAfter:      getDuration("LEAVE_RETURN_AFTER", 0),
+		ActivityReturnAfter:   getDuration("ACTIVITY_RETURN_AFTER", 0),
+		OverdueRepeatInterval: getDuration("OVERDUE_REPEAT_INTERVAL", 30*time.Minute),
+		CurfewTime:            os.Getenv("CURFEW_TIME"),
+		APIAddr:               os.Getenv("API_ADDR"),
+		WebAddr:               os.Getenv("WEB_ADDR"),
+		WebURL:                webURL(os.Getenv("WEB_URL"), os.Getenv("WEB_ADDR")),
+		BackupDir:             backupDir(getEnv("BACKUP_DIR", "backups")),
+		BackupTime:            getEnv("BACKUP_TIME", "03:00"),
+		BackupKeepDaily:       getInt("BACKUP_KEEP_DAILY", 7),
+		BackupKeepWeekly:      getInt("BACKUP_KEEP_WEEKLY", 4),
+		BackupKeepMonthly:     getInt("BACKUP_KEEP_MONTHLY", 12),
+	}
+}
+
+// DatabaseSource возвращает, к чему подключаться: DB_URL, если задан, иначе файл DB_PATH
+func (c *Config) DatabaseSource() string {
+	if c.DBURL != "" {
+		return c.DBURL
+	}
+	return c.DBPath
+}
+
+// webURL возвращает внешний адрес веб-панели; если он не задан, панель открывается на этом же компьютере
+func webURL(url, addr string) string {
+	if url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	if addr == "" {
+		return ""
 	}
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
+
+// backupDir возвращает каталог резервных копий; off отключает копирование
+func backupDir(dir string) string {
+	if strings.EqualFold(dir, "off") {
+		return ""
+	}
+	return dir
 }
 
 func getEnv(key, defaultValue string) string {
@@ -38,6 +115,34 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using %s: %v", key, value, defaultValue, err)
+		return defaultValue
+	}
+	return duration
+}
+
+func getInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Printf("Invalid %s=%q, using %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
 func parseAdminIDs(adminIDsStr string) []int64 {
 	if adminIDsStr == "" {
 		return []int64{}
@@ -56,6 +161,45 @@ func parseAdminIDs(adminIDsStr string) []int64 {
 	return adminIDs
 }
 
+func loadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		// Если не удалось, используем UTC+3 (Московское время)
+		log.Printf("Unknown TIMEZONE %q, using UTC+3: %v", name, err)
+		return time.FixedZone("MSK", 3*60*60)
+	}
+	return location
+}
+
+// parseClockTimes разбирает список времени в формате ЧЧ:ММ через запятую
+func parseClockTimes(timesStr string) []string {
+	var times []string
+
+	for _, value := range strings.Split(timesStr, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", value); err != nil {
+			log.Printf("Skipping invalid time %q: %v", value, err)
+			continue
+		}
+		times = append(times, value)
+	}
+
+	return times
+}
+
+// TelegramAPIEndpoint возвращает шаблон адреса методов Bot API для tgbotapi (токен и метод через %s)
+func (c *Config) TelegramAPIEndpoint() string {
+	return c.TelegramAPIURL + "/bot%s/%s"
+}
+
+// TelegramFileEndpoint возвращает шаблон адреса для скачивания файлов (токен и путь к файлу через %s)
+func (c *Config) TelegramFileEndpoint() string {
+	return c.TelegramAPIURL + "/file/bot%s/%s"
+}
+
 // IsAdmin проверяет, является ли пользователь администратором
 func (c *Config) IsAdmin(userID int64) bool {
 	for _, adminID := range c.AdminIDs {
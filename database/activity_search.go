@@ -0,0 +1,205 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"whereismychildren/utils"
+)
+
+// Триггеры, поддерживающие индекс activities_fts в актуальном состоянии
+var activitySearchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS unplanned_activities_fts_insert AFTER INSERT ON unplanned_activities BEGIN
+		INSERT INTO activities_fts (rowid, description) VALUES (new.id, new.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS unplanned_activities_fts_delete AFTER DELETE ON unplanned_activities BEGIN
+		INSERT INTO activities_fts (activities_fts, rowid, description) VALUES ('delete', old.id, old.description);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS unplanned_activities_fts_update AFTER UPDATE OF description ON unplanned_activities BEGIN
+		INSERT INTO activities_fts (activities_fts, rowid, description) VALUES ('delete', old.id, old.description);
+		INSERT INTO activities_fts (rowid, description) VALUES (new.id, new.description);
+	END`,
+}
+
+// initActivitySearch создаёт полнотекстовый индекс FTS5 по описаниям внеплановой деятельности.
+// В mattn/go-sqlite3 FTS5 есть только при сборке с тегом sqlite_fts5; без него возвращает false,
+// и поиск выполняется перебором
+func initActivitySearch(db *sql.DB) (bool, error) {
+	available, err := fts5Available(db)
+	if err != nil {
+		return false, err
+	}
+	if !available {
+		// База могла быть создана драйвером с FTS5: таблица activities_fts тогда уже есть,
+		// но её триггеры сломали бы запись деятельности, поэтому удаляем их.
+		// Индекс перестроится при следующем запуске с FTS5
+		for _, trigger := range []string{"insert", "delete", "update"} {
+			if _, err := db.Exec("DROP TRIGGER IF EXISTS unplanned_activities_fts_" + trigger); err != nil {
+				return false, err
+			}
+		}
+		log.Println("FTS5 is not available (use DB_DRIVER=sqlite or build with -tags sqlite_fts5), activity search will scan descriptions")
+		return false, nil
+	}
+
+	_, err = db.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS activities_fts USING fts5(
+			description,
+			content = 'unplanned_activities',
+			content_rowid = 'id',
+			tokenize = 'unicode61 remove_diacritics 2'
+		)
+	`)
+	if err != nil {
+		return false, err
+	}
+
+	// Если триггеров не было, индекс новый или устарел - перестраиваем его
+	var triggers int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'trigger' AND name LIKE 'unplanned_activities_fts_%'
+	`).Scan(&triggers)
+	if err != nil {
+		return false, err
+	}
+
+	for _, trigger := range activitySearchTriggers {
+		if _, err := db.Exec(trigger); err != nil {
+			return false, err
+		}
+	}
+
+	if triggers < len(activitySearchTriggers) {
+		if _, err := db.Exec("INSERT INTO activities_fts (activities_fts) VALUES ('rebuild')"); err != nil {
+			return false, err
+		}
+		log.Println("Activity search index rebuilt")
+	}
+
+	return true, nil
+}
+
+// fts5Available проверяет, что в сборке SQLite есть модуль FTS5. Наличие таблицы activities_fts
+// этого не гарантирует: CREATE VIRTUAL TABLE IF NOT EXISTS для существующей таблицы проходит и без модуля
+func fts5Available(db *sql.DB) (bool, error) {
+	// Временная таблица видна только своему соединению, поэтому проверка идёт в одном соединении
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "CREATE VIRTUAL TABLE temp.fts5_probe USING fts5(text)"); err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			return false, nil
+		}
+		return false, err
+	}
+	_, err = conn.ExecContext(ctx, "DROP TABLE temp.fts5_probe")
+	return true, err
+}
+
+// SearchActivities ищет внеплановую деятельность по словам описания (все слова, по началу слова).
+// Нулевые границы периода означают поиск за всё время. Результаты - от новых к старым
+func (db *DB) SearchActivities(text string, from, to time.Time) ([]ActivityRecord, error) {
+	words := searchWords(text)
+	if len(words) == 0 {
+		return nil, fmt.Errorf("не указан текст для поиска")
+	}
+
+	query := `
+		SELECT s.id, s.last_name, s.first_name, s.middle_name,
+		       u.id, u.activity_time, u.description, u.created_at
+		FROM unplanned_activities u
+		JOIN subordinates s ON u.subordinate_id = s.id`
+	var args []interface{}
+	var conditions []string
+
+	if db.fullTextSearch {
+		// Каждое слово в кавычках с * - поиск по началу слова без синтаксиса FTS5 в запросе
+		var terms []string
+		for _, word := range words {
+			terms = append(terms, fmt.Sprintf(`"%s"*`, word))
+		}
+		query += " JOIN activities_fts f ON f.rowid = u.id"
+		conditions = append(conditions, "activities_fts MATCH ?")
+		args = append(args, strings.Join(terms, " "))
+	}
+
+	if !from.IsZero() && !to.IsZero() {
+		conditions = append(conditions, db.day("u.activity_time")+" BETWEEN ? AND ?")
+		args = append(args, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY u.activity_time DESC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ActivityRecord
+	for rows.Next() {
+		var item ActivityRecord
+		if err := rows.Scan(
+			&item.Subordinate.ID,
+			&item.Subordinate.LastName,
+			&item.Subordinate.FirstName,
+			&item.Subordinate.MiddleName,
+			&item.Activity.ID,
+			&item.Activity.ActivityTime,
+			&item.Activity.Description,
+			&item.Activity.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		item.Activity.SubordinateID = item.Subordinate.ID
+
+		if !db.fullTextSearch && !containsWords(item.Activity.Description, words) {
+			continue
+		}
+		result = append(result, item)
+	}
+
+	return result, nil
+}
+
+// searchWords разбивает запрос на нормализованные слова (буквы и цифры)
+func searchWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words = append(words, utils.NormalizeName(word))
+	}
+	return words
+}
+
+// containsWords проверяет, что в тексте есть слова, начинающиеся с каждого из искомых
+func containsWords(text string, words []string) bool {
+	textWords := searchWords(text)
+	for _, word := range words {
+		found := false
+		for _, textWord := range textWords {
+			if strings.HasPrefix(textWord, word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
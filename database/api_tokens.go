@@ -0,0 +1,104 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"time"
+)
+
+// CreateAPIToken создаёт токен доступа к API с областью действия scope (TokenScopeFull или TokenScopeCalendar)
+// и возвращает его значение. В базе хранится только хеш
+func (db *DB) CreateAPIToken(name, scope string, createdBy int64) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO api_tokens (name, scope, token_hash, created_by) VALUES (?, ?, ?, ?)",
+		name, scope, hashToken(token), createdBy,
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateAPIToken проверяет токен и запоминает время его использования
+func (db *DB) ValidateAPIToken(token string) (*APIToken, error) {
+	var apiToken APIToken
+	err := db.QueryRow(`
+		SELECT id, name, scope, created_by, created_at
+		FROM api_tokens
+		WHERE token_hash = ? AND revoked_at IS NULL
+	`, hashToken(token)).Scan(&apiToken.ID, &apiToken.Name, &apiToken.Scope, &apiToken.CreatedBy, &apiToken.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := db.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, apiToken.ID); err != nil {
+		return nil, err
+	}
+	apiToken.LastUsedAt = &now
+
+	return &apiToken, nil
+}
+
+// GetAPITokens возвращает действующие токены
+func (db *DB) GetAPITokens() ([]APIToken, error) {
+	rows, err := db.Query(`
+		SELECT id, name, scope, created_by, created_at, last_used_at
+		FROM api_tokens
+		WHERE revoked_at IS NULL
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		var token APIToken
+		if err := rows.Scan(&token.ID, &token.Name, &token.Scope, &token.CreatedBy, &token.CreatedAt, &token.LastUsedAt); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// RevokeAPIToken отзывает токен. Возвращает false, если действующего токена с таким id нет
+func (db *DB) RevokeAPIToken(id int) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now(), id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// newToken генерирует случайный токен из 32 байт в шестнадцатеричном виде
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrBackupUnsupported - резервные копии средствами бота делаются только для SQLite
+var ErrBackupUnsupported = errors.New("backups are supported only for SQLite, use pg_dump for PostgreSQL")
+
+// Таблицы и столбцы, без которых файл нельзя считать базой бота. Остальное
+// добавляют миграции при открытии, поэтому копии от прежних версий тоже подходят
+var backupSchema = map[string][]string{
+	"subordinates":         {"id", "last_name", "first_name", "middle_name"},
+	"leaves":               {"id", "subordinate_id", "leave_time"},
+	"unplanned_activities": {"id", "subordinate_id", "activity_time", "description"},
+}
+
+// Backup сохраняет согласованную копию базы в новый файл path, не останавливая работу бота.
+// VACUUM INTO пишет копию в одной транзакции чтения, поэтому параллельные записи в неё не попадают
+func (db *DB) Backup(path string) error {
+	if db.postgres() {
+		return ErrBackupUnsupported
+	}
+
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// RecoverJournal открывает файл базы и сразу закрывает его. SQLite при этом откатывает прерванную
+// транзакцию из горячего журнала (-journal) и переносит записанное в -wal в сам файл, после чего
+// файл содержит согласованные данные и его можно копировать отдельно от журналов
+func RecoverJournal(driver, path string) error {
+	db, err := sql.Open(sqliteDriver(driver), path)
+	if err != nil {
+		return err
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA schema_version").Scan(&version); err != nil {
+		db.Close()
+		return err
+	}
+	return db.Close()
+}
+
+// ValidateBackup проверяет, что файл path - целая база SQLite со схемой бота.
+// Файл открывается только на чтение драйвером driver (см. NewSQLiteDB)
+func ValidateBackup(driver, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	db, err := sql.Open(sqliteDriver(driver), "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var integrity string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return fmt.Errorf("%s is not a SQLite database: %v", path, err)
+	}
+	if integrity != "ok" {
+		return fmt.Errorf("%s is damaged: %s", path, integrity)
+	}
+
+	for table, columns := range backupSchema {
+		existing, err := tableColumns(db, table)
+		if err != nil {
+			return err
+		}
+		if len(existing) == 0 {
+			return fmt.Errorf("%s has no table %s", path, table)
+		}
+
+		var missing []string
+		for _, column := range columns {
+			if !existing[column] {
+				missing = append(missing, column)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s: table %s has no columns %s", path, table, strings.Join(missing, ", "))
+		}
+	}
+
+	return nil
+}
+
+// tableColumns возвращает множество столбцов таблицы (пустое, если таблицы нет)
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
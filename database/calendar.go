@@ -0,0 +1,79 @@
+package database
+
+import (
+	"sort"
+	"time"
+)
+
+// GetAbsencesBetween возвращает уходы и внеплановую деятельность за период (границы включительно)
+// в порядке времени. Если указан subordinateID или group, выбираются только записи этого
+// подчиненного или группы
+func (db *DB) GetAbsencesBetween(from, to time.Time, subordinateID int, group string) ([]Absence, error) {
+	filter := ""
+	args := []interface{}{from.Format("2006-01-02"), to.Format("2006-01-02")}
+	if subordinateID != 0 {
+		filter += " AND s.id = ?"
+		args = append(args, subordinateID)
+	}
+	if group != "" {
+		filter += " AND s.group_name = ?"
+		args = append(args, group)
+	}
+
+	var absences []Absence
+
+	rows, err := db.Query(`
+		SELECT l.id, l.leave_time, l.expected_return, l.returned_at, l.stays_out,
+		       s.id, s.last_name, s.first_name, s.middle_name, COALESCE(s.group_name, '')
+		FROM leaves l
+		JOIN subordinates s ON l.subordinate_id = s.id
+		WHERE `+db.day("l.leave_time")+` BETWEEN ? AND ?`+filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		absence := Absence{Kind: KindLeave}
+		if err := rows.Scan(&absence.ID, &absence.Start, &absence.ExpectedReturn, &absence.ReturnedAt, &absence.StaysOut,
+			&absence.Subordinate.ID, &absence.Subordinate.LastName, &absence.Subordinate.FirstName,
+			&absence.Subordinate.MiddleName, &absence.Subordinate.Group); err != nil {
+			return nil, err
+		}
+		absences = append(absences, absence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	activityRows, err := db.Query(`
+		SELECT u.id, u.activity_time, u.description, u.expected_return, u.returned_at, u.stays_out,
+		       s.id, s.last_name, s.first_name, s.middle_name, COALESCE(s.group_name, '')
+		FROM unplanned_activities u
+		JOIN subordinates s ON u.subordinate_id = s.id
+		WHERE `+db.day("u.activity_time")+` BETWEEN ? AND ?`+filter, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer activityRows.Close()
+
+	for activityRows.Next() {
+		absence := Absence{Kind: KindActivity}
+		if err := activityRows.Scan(&absence.ID, &absence.Start, &absence.Description, &absence.ExpectedReturn,
+			&absence.ReturnedAt, &absence.StaysOut,
+			&absence.Subordinate.ID, &absence.Subordinate.LastName, &absence.Subordinate.FirstName,
+			&absence.Subordinate.MiddleName, &absence.Subordinate.Group); err != nil {
+			return nil, err
+		}
+		absences = append(absences, absence)
+	}
+	if err := activityRows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(absences, func(i, j int) bool {
+		return absences[i].Start.Before(absences[j].Start)
+	})
+
+	return absences, nil
+}
@@ -0,0 +1,135 @@
+// Package dbtest создаёт пустую базу данных для тестов. По умолчанию это SQLite в памяти с драйвером
+// на чистом Go, как у бота; TEST_DB_DRIVER=sqlite3 выбирает драйвер с CGO, а TEST_DB_DRIVER=postgres
+// вместе с TEST_POSTGRES_URL - PostgreSQL (так весь набор тестов запускается в CI,
+// см. .github/workflows/test.yml)
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"whereismychildren/database"
+)
+
+// Переменные окружения, выбирающие базу для тестов
+const (
+	DriverEnv      = "TEST_DB_DRIVER"
+	PostgresURLEnv = "TEST_POSTGRES_URL"
+)
+
+var counter atomic.Int64
+
+// Driver возвращает драйвер, с которым запускаются тесты
+func Driver() string {
+	switch strings.ToLower(os.Getenv(DriverEnv)) {
+	case database.DriverPostgres, "postgresql", "pg":
+		return database.DriverPostgres
+	case database.DriverSQLite:
+		return database.DriverSQLite
+	default:
+		return database.DriverSQLitePure
+	}
+}
+
+// New возвращает пустую базу со всеми таблицами. База закрывается (а схема PostgreSQL удаляется)
+// по окончании теста
+func New(t testing.TB) *database.DB {
+	t.Helper()
+
+	var dsn string
+	if Driver() == database.DriverPostgres {
+		dsn = postgresSchema(t)
+	} else {
+		// Общий кеш нужен, чтобы все соединения пула видели одну базу; имя у каждого теста своё
+		name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+		dsn = fmt.Sprintf("file:%s_%d?mode=memory&cache=shared", name, counter.Add(1))
+	}
+
+	db, err := database.Open(Driver(), dsn)
+	if err != nil {
+		t.Fatalf("failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// Source возвращает драйвер и строку подключения к пустой базе для тестов, которые открывают
+// базу сами (например, через app.Run). Для SQLite это файл во временном каталоге теста
+func Source(t testing.TB) (driver, dsn string) {
+	t.Helper()
+
+	if Driver() == database.DriverPostgres {
+		return database.DriverPostgres, postgresSchema(t)
+	}
+	return Driver(), filepath.Join(t.TempDir(), "bot.db")
+}
+
+// postgresSchema создаёт для теста отдельную схему и возвращает строку подключения, в которой
+// она выбрана по умолчанию. Без TEST_POSTGRES_URL тест падает: иначе прогон с PostgreSQL
+// молча пропустил бы все тесты
+func postgresSchema(t testing.TB) string {
+	t.Helper()
+
+	baseURL := os.Getenv(PostgresURLEnv)
+	if baseURL == "" {
+		t.Fatalf("%s=%s requires %s", DriverEnv, database.DriverPostgres, PostgresURLEnv)
+	}
+
+	admin, err := sql.Open("pgx", baseURL)
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL: %v", err)
+	}
+
+	schema := fmt.Sprintf("wimc_test_%d_%d", os.Getpid(), counter.Add(1))
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		admin.Close()
+		t.Fatalf("failed to create schema %s: %v", schema, err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema %s: %v", schema, err)
+		}
+		admin.Close()
+	})
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		t.Fatalf("invalid %s: %v", PostgresURLEnv, err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// AddSubordinates добавляет подчиненных по ФИО ("Иванов Иван Иванович") и возвращает их в том же порядке
+func AddSubordinates(t testing.TB, db *database.DB, names ...string) []database.Subordinate {
+	t.Helper()
+
+	var subordinates []database.Subordinate
+	for _, name := range names {
+		parts := strings.Fields(name)
+		sub := database.Subordinate{LastName: parts[0]}
+		if len(parts) > 1 {
+			sub.FirstName = parts[1]
+		}
+		if len(parts) > 2 {
+			sub.MiddleName = parts[2]
+		}
+
+		created, err := db.CreateSubordinate(sub)
+		if err != nil {
+			t.Fatalf("failed to add subordinate %q: %v", name, err)
+		}
+		subordinates = append(subordinates, created)
+	}
+
+	return subordinates
+}
@@ -0,0 +1,32 @@
+package database
+
+import "testing"
+
+func TestRebindForPostgres(t *testing.T) {
+	db := &DB{driver: DriverPostgres}
+
+	tests := []struct{ query, want string }{
+		{"SELECT id FROM leaves WHERE subordinate_id = ? AND DATE(leave_time) = ?",
+			"SELECT id FROM leaves WHERE subordinate_id = $1 AND DATE(leave_time) = $2"},
+		{"UPDATE leaves SET subordinate_id = ?1 WHERE subordinate_id = ?2 AND id IN (SELECT id FROM leaves WHERE subordinate_id = ?1)",
+			"UPDATE leaves SET subordinate_id = $1 WHERE subordinate_id = $2 AND id IN (SELECT id FROM leaves WHERE subordinate_id = $1)"},
+		{"SELECT 'leave?', id FROM leaves WHERE id = ?",
+			"SELECT 'leave?', id FROM leaves WHERE id = $1"},
+		{"SELECT COUNT(*) FROM guardians", "SELECT COUNT(*) FROM guardians"},
+	}
+
+	for _, tt := range tests {
+		if got := db.rebind(tt.query); got != tt.want {
+			t.Errorf("rebind(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestRebindKeepsSQLiteQueries(t *testing.T) {
+	db := &DB{driver: DriverSQLite}
+
+	query := "UPDATE guardians SET subordinate_id = ?1 WHERE subordinate_id = ?2"
+	if got := db.rebind(query); got != query {
+		t.Errorf("rebind changed SQLite query: %q", got)
+	}
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"database/sql"
+
+	"whereismychildren/events"
+)
+
+// Events возвращает шину событий, на которую публикуется каждое изменение посещаемости и списка
+func (db *DB) Events() *events.Bus {
+	return db.events
+}
+
+// publish ставит событие в очередь отправки вебхуков в той же транзакции, что и само изменение:
+// вебхуки получат событие, только если изменение зафиксировано, и не потеряют его, если оно
+// зафиксировано. Подписчикам на шине событие рассылается после фиксации (см. inTx)
+func (tx *Tx) publish(event events.Event) error {
+	id, err := tx.nextEventID()
+	if err != nil {
+		return err
+	}
+	event.ID = id
+	event = tx.db.events.Stamp(event)
+	if err := enqueueWebhookDeliveries(tx, event); err != nil {
+		return err
+	}
+
+	tx.published = append(tx.published, event)
+	return nil
+}
+
+// nextEventID выдаёт номер события из базы: номера не повторяются после перезапуска бота
+// и общие для всех процессов, работающих с одной базой
+func (tx *Tx) nextEventID() (uint64, error) {
+	query := "UPDATE event_sequence SET last_id = last_id + 1 WHERE id = 1 RETURNING last_id"
+	if tx.db.postgres() {
+		query = "SELECT nextval('event_id_seq')"
+	}
+
+	var id uint64
+	err := tx.QueryRow(query).Scan(&id)
+	return id, err
+}
+
+// publishRecord публикует событие по уходу или деятельности, приложив запись после изменения.
+// Если записи нет, изменять было нечего, и событие не публикуется
+func (tx *Tx) publishRecord(eventType, kind string, id int) error {
+	event := events.Event{Type: eventType, Kind: kind, RecordID: id}
+
+	switch kind {
+	case KindLeave:
+		leave, err := getLeaveByID(tx, id)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		event.SubordinateID, event.Data = leave.SubordinateID, leave
+	case KindActivity:
+		activity, err := getActivityByID(tx, id)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		event.SubordinateID, event.Data = activity.SubordinateID, activity
+	}
+
+	return tx.publish(event)
+}
+
+// publishSubordinate публикует событие по подчиненному, приложив его текущие данные
+func (tx *Tx) publishSubordinate(eventType string, id int) error {
+	sub, err := getSubordinateByID(tx, id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return tx.publish(events.Event{Type: eventType, SubordinateID: id, Data: sub})
+}
+
+func getLeaveByID(q querier, id int) (Leave, error) {
+	var leave Leave
+	err := q.QueryRow(`
+		SELECT id, subordinate_id, leave_time, expected_return, returned_at, stays_out, created_at
+		FROM leaves WHERE id = ?
+	`, id).Scan(&leave.ID, &leave.SubordinateID, &leave.LeaveTime, &leave.ExpectedReturn,
+		&leave.ReturnedAt, &leave.StaysOut, &leave.CreatedAt)
+	return leave, err
+}
+
+func getActivityByID(q querier, id int) (UnplannedActivity, error) {
+	var activity UnplannedActivity
+	err := q.QueryRow(`
+		SELECT id, subordinate_id, activity_time, description, expected_return, returned_at, stays_out, created_at
+		FROM unplanned_activities WHERE id = ?
+	`, id).Scan(&activity.ID, &activity.SubordinateID, &activity.ActivityTime, &activity.Description,
+		&activity.ExpectedReturn, &activity.ReturnedAt, &activity.StaysOut, &activity.CreatedAt)
+	return activity, err
+}
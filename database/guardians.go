@@ -0,0 +1,77 @@
+package database
+
+const guardianColumns = `id, subordinate_id, full_name, COALESCE(relation, ''), phone, telegram_id, notify, created_at`
+
+// AddGuardian добавляет представителя или обновляет ФИО и степень родства,
+// если представитель с таким телефоном у подчиненного уже есть
+func (db *DB) AddGuardian(guardian Guardian) error {
+	_, err := db.Exec(`
+		INSERT INTO guardians (subordinate_id, full_name, relation, phone)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(subordinate_id, phone) DO UPDATE SET
+			full_name = excluded.full_name,
+			relation = excluded.relation
+	`, guardian.SubordinateID, guardian.FullName, guardian.Relation, guardian.Phone)
+	return err
+}
+
+func (db *DB) GetGuardiansBySubordinate(subordinateID int) ([]Guardian, error) {
+	return db.queryGuardians("SELECT "+guardianColumns+" FROM guardians WHERE subordinate_id = ? ORDER BY id", subordinateID)
+}
+
+func (db *DB) GetGuardiansByTelegramID(telegramID int64) ([]Guardian, error) {
+	return db.queryGuardians("SELECT "+guardianColumns+" FROM guardians WHERE telegram_id = ? ORDER BY id", telegramID)
+}
+
+// GetNotifiableGuardians возвращает представителей, подключивших Telegram и не отключивших уведомления
+func (db *DB) GetNotifiableGuardians(subordinateID int) ([]Guardian, error) {
+	return db.queryGuardians(`
+		SELECT `+guardianColumns+` FROM guardians
+		WHERE subordinate_id = ? AND telegram_id IS NOT NULL AND notify
+	`, subordinateID)
+}
+
+// LinkGuardiansByPhone привязывает Telegram аккаунт ко всем записям представителя с указанным телефоном
+func (db *DB) LinkGuardiansByPhone(phone string, telegramID int64) ([]Guardian, error) {
+	_, err := db.Exec(
+		"UPDATE guardians SET telegram_id = ?, notify = TRUE WHERE phone = ?",
+		telegramID, phone,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetGuardiansByTelegramID(telegramID)
+}
+
+// SetGuardianNotifications включает или отключает уведомления для представителя
+func (db *DB) SetGuardianNotifications(telegramID int64, enabled bool) error {
+	_, err := db.Exec("UPDATE guardians SET notify = ? WHERE telegram_id = ?", enabled, telegramID)
+	return err
+}
+
+func (db *DB) CountGuardians() (int, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM guardians").Scan(&count)
+	return count, err
+}
+
+func (db *DB) queryGuardians(query string, args ...interface{}) ([]Guardian, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var guardians []Guardian
+	for rows.Next() {
+		var g Guardian
+		if err := rows.Scan(&g.ID, &g.SubordinateID, &g.FullName, &g.Relation, &g.Phone,
+			&g.TelegramID, &g.Notify, &g.CreatedAt); err != nil {
+			return nil, err
+		}
+		guardians = append(guardians, g)
+	}
+
+	return guardians, nil
+}
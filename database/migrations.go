@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 )
 
@@ -35,10 +36,14 @@ func InitDB(db *sql.DB) error {
 		return err
 	}
 
-	// Создаем индекс для быстрого поиска уходов по дате и подчиненному
+	// Создаем индекс для быстрого поиска уходов по дате и подчиненному.
+	// Дата берётся из начала строки времени (см. DB.day), прежний индекс по DATE() не используется
+	if _, err := db.Exec("DROP INDEX IF EXISTS idx_leaves_subordinate_date"); err != nil {
+		return err
+	}
 	_, err = db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_leaves_subordinate_date 
-		ON leaves (subordinate_id, DATE(leave_time))
+		CREATE INDEX IF NOT EXISTS idx_leaves_subordinate_day
+		ON leaves (subordinate_id, SUBSTR(leave_time, 1, 10))
 	`)
 	if err != nil {
 		return err
@@ -60,14 +65,282 @@ func InitDB(db *sql.DB) error {
 	}
 
 	// Создаем индекс для быстрого поиска деятельности по дате и подчиненному
+	if _, err := db.Exec("DROP INDEX IF EXISTS idx_activities_subordinate_date"); err != nil {
+		return err
+	}
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_activities_subordinate_day
+		ON unplanned_activities (subordinate_id, SUBSTR(activity_time, 1, 10))
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Подписки руководителей на плановую рассылку отчётов
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS subscriptions (
+			chat_id INTEGER PRIMARY KEY,
+			report_status INTEGER NOT NULL DEFAULT 1,
+			report_statistics INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Законные представители подчиненных (родители, опекуны)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS guardians (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subordinate_id INTEGER NOT NULL,
+			full_name TEXT NOT NULL,
+			relation TEXT,
+			phone TEXT NOT NULL,
+			telegram_id INTEGER,
+			notify INTEGER NOT NULL DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (subordinate_id) REFERENCES subordinates (id),
+			UNIQUE(subordinate_id, phone)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE INDEX IF NOT EXISTS idx_guardians_telegram_id
+		ON guardians (telegram_id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Групповые чаты, в которых бот обслуживает ответственных
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS supervisor_chats (
+			chat_id INTEGER PRIMARY KEY,
+			title TEXT,
+			registered_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Группа подчиненного (класс, отделение) из списка Excel
+	if err := addColumnIfMissing(db, "subordinates", "group_name", "TEXT"); err != nil {
+		return err
+	}
+
+	// Архивные подчиненные скрыты из списков, но остаются в истории
+	if err := addColumnIfMissing(db, "subordinates", "archived_at", "DATETIME"); err != nil {
+		return err
+	}
+
+	// Токены доступа к HTTP API (хранится только SHA-256 токена)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_used_at DATETIME,
+			revoked_at DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	// Область действия токена: полный доступ или только подписка на календарь
+	if err := addColumnIfMissing(db, "api_tokens", "scope", "TEXT NOT NULL DEFAULT 'full'"); err != nil {
+		return err
+	}
+
+	// Одноразовые ссылки для входа в веб-панель и сессии браузеров (хранятся только SHA-256)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS web_login_links (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL UNIQUE,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL,
+			used_at DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS web_sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			token_hash TEXT NOT NULL UNIQUE,
+			user_id INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	// Вебхуки: адреса для уведомлений о событиях и очередь их отправки с повторами
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			created_by INTEGER NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			removed_at DATETIME
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			webhook_id INTEGER NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at DATETIME NOT NULL,
+			last_error TEXT,
+			delivered_at DATETIME,
+			failed_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (webhook_id) REFERENCES webhooks (id)
+		)
+	`)
+	if err != nil {
+		return err
+	}
+
 	_, err = db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_activities_subordinate_date 
-		ON unplanned_activities (subordinate_id, DATE(activity_time))
+		CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+		ON webhook_deliveries (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL
 	`)
 	if err != nil {
 		return err
 	}
 
+	// Последний выданный номер события (одна строка): номера событий не начинаются заново после перезапуска
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS event_sequence (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			last_id INTEGER NOT NULL
+		)
+	`)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("INSERT OR IGNORE INTO event_sequence (id, last_id) VALUES (1, 0)"); err != nil {
+		return err
+	}
+
+	// Отслеживание возвращения: ожидаемое время, фактическое время и состояние напоминаний
+	for _, table := range []string{"leaves", "unplanned_activities"} {
+		columns := []struct{ name, definition string }{
+			{"expected_return", "DATETIME"},
+			{"returned_at", "DATETIME"},
+			{"notify_chat_id", "INTEGER"},
+			{"alert_acknowledged", "INTEGER NOT NULL DEFAULT 0"},
+			{"last_alert_at", "DATETIME"},
+			{"stays_out", "INTEGER NOT NULL DEFAULT 0"},
+		}
+		for _, column := range columns {
+			if err := addColumnIfMissing(db, table, column.name, column.definition); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Дата события в часовом поясе, в котором оно записано
+	for _, table := range []string{"leaves", "unplanned_activities"} {
+		if err := addColumnIfMissing(db, table, "event_date", "TEXT"); err != nil {
+			return err
+		}
+	}
+
 	log.Println("Database initialized successfully")
 	return nil
 }
+
+// migrateRecordIndexes заполняет дату события в записях, созданных до её появления, и заменяет
+// уникальный индекс "одна запись в день" индексом "одна запись на минуту", по которому запись
+// обновляется через ON CONFLICT. Выполняется после initActivitySearch: удаление дубликатов
+// деятельности вызывает триггеры полнотекстового индекса
+func migrateRecordIndexes(db *sql.DB) error {
+	for _, table := range []struct{ name, timeColumn, index, dayIndex string }{
+		{"leaves", "leave_time", "idx_leaves_subordinate_leave_time", "idx_leaves_subordinate_event_date"},
+		{"unplanned_activities", "activity_time", "idx_activities_subordinate_activity_time", "idx_activities_subordinate_event_date"},
+	} {
+		if _, err := db.Exec("DROP INDEX IF EXISTS " + table.dayIndex); err != nil {
+			return err
+		}
+
+		var exists int
+		err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", table.index).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			continue
+		}
+
+		statements := []string{
+			fmt.Sprintf("UPDATE %s SET event_date = SUBSTR(%s, 1, 10) WHERE event_date IS NULL", table.name, table.timeColumn),
+			// Одинаковые записи могли остаться от повторных нажатий - сохраняется последняя
+			fmt.Sprintf("DELETE FROM %[1]s WHERE id NOT IN (SELECT MAX(id) FROM %[1]s GROUP BY subordinate_id, %[2]s)",
+				table.name, table.timeColumn),
+			fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (subordinate_id, %s)", table.index, table.name, table.timeColumn),
+		}
+		for _, statement := range statements {
+			if _, err := db.Exec(statement); err != nil {
+				return err
+			}
+		}
+		log.Printf("Created index %s", table.index)
+	}
+	return nil
+}
+
+// addColumnIfMissing добавляет колонку в существующую таблицу, если её ещё нет
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err == nil {
+		log.Printf("Added column %s.%s", table, column)
+	}
+	return err
+}
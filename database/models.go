@@ -3,23 +3,197 @@ package database
 import "time"
 
 type Subordinate struct {
-	ID         int    `json:"id"`
-	LastName   string `json:"last_name"`
-	FirstName  string `json:"first_name"`
-	MiddleName string `json:"middle_name"`
+	ID         int        `json:"id"`
+	LastName   string     `json:"last_name"`
+	FirstName  string     `json:"first_name"`
+	MiddleName string     `json:"middle_name"`
+	Group      string     `json:"group,omitempty"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
 
 type Leave struct {
-	ID            int       `json:"id"`
-	SubordinateID int       `json:"subordinate_id"`
-	LeaveTime     time.Time `json:"leave_time"`
-	CreatedAt     time.Time `json:"created_at"`
+	ID             int        `json:"id"`
+	SubordinateID  int        `json:"subordinate_id"`
+	LeaveTime      time.Time  `json:"leave_time"`
+	ExpectedReturn *time.Time `json:"expected_return,omitempty"`
+	ReturnedAt     *time.Time `json:"returned_at,omitempty"`
+	StaysOut       bool       `json:"stays_out,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
 }
 
 type UnplannedActivity struct {
+	ID             int        `json:"id"`
+	SubordinateID  int        `json:"subordinate_id"`
+	ActivityTime   time.Time  `json:"activity_time"`
+	Description    string     `json:"description"`
+	ExpectedReturn *time.Time `json:"expected_return,omitempty"`
+	ReturnedAt     *time.Time `json:"returned_at,omitempty"`
+	StaysOut       bool       `json:"stays_out,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Статусы подчиненного за день
+const (
+	StatusPresent  = "present"
+	StatusLeft     = "left"
+	StatusActivity = "activity"
+	StatusReturned = "returned"
+)
+
+// Области действия токенов API
+const (
+	// Полный доступ ко всем методам API, токен передаётся только в заголовке Authorization
+	TokenScopeFull = "full"
+	// Только чтение календаря: такой токен можно указать в адресе подписки ?token=
+	TokenScopeCalendar = "calendar"
+)
+
+// Виды отсутствия, для которых отслеживается возвращение
+const (
+	KindLeave    = "leave"
+	KindActivity = "activity"
+)
+
+// SubordinateStatus описывает положение подчиненного на конец указанного дня.
+// Kind и RecordID указывают на запись, определяющую статус
+type SubordinateStatus struct {
+	Subordinate    Subordinate        `json:"subordinate"`
+	Status         string             `json:"status"`
+	Kind           string             `json:"kind,omitempty"`
+	RecordID       int                `json:"record_id,omitempty"`
+	LeaveTime      *time.Time         `json:"leave_time,omitempty"`
+	Activity       *UnplannedActivity `json:"activity,omitempty"`
+	ReturnedAt     *time.Time         `json:"returned_at,omitempty"`
+	ExpectedReturn *time.Time         `json:"expected_return,omitempty"`
+	StaysOut       bool               `json:"stays_out,omitempty"`
+}
+
+// LeaveRecord - уход вместе с данными подчиненного
+type LeaveRecord struct {
+	Subordinate Subordinate `json:"subordinate"`
+	LeaveTime   time.Time   `json:"leave_time"`
+}
+
+// ActivityRecord - внеплановая деятельность вместе с данными подчиненного
+type ActivityRecord struct {
+	Subordinate Subordinate       `json:"subordinate"`
+	Activity    UnplannedActivity `json:"activity"`
+}
+
+// ExportRecord - строка выгрузки в Excel: уход (LeaveTime) или внеплановая деятельность
+// (ActivityTime и ActivityDesc) подчиненного
+type ExportRecord struct {
+	Subordinate  Subordinate
+	LeaveTime    *time.Time
+	ActivityTime *time.Time
+	ActivityDesc *string
+	Date         time.Time
+}
+
+// EventTime возвращает время ухода или деятельности
+func (r ExportRecord) EventTime() time.Time {
+	if r.LeaveTime != nil {
+		return *r.LeaveTime
+	}
+	if r.ActivityTime != nil {
+		return *r.ActivityTime
+	}
+	return time.Time{}
+}
+
+// Subscription - подписка чата на плановую рассылку отчётов
+type Subscription struct {
+	ChatID     int64     `json:"chat_id"`
+	Status     bool      `json:"status"`
+	Statistics bool      `json:"statistics"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PendingReturn - отсутствие с ожидаемым временем возвращения, которое ещё не закрыто
+type PendingReturn struct {
+	Kind           string      `json:"kind"`
+	ID             int         `json:"id"`
+	Subordinate    Subordinate `json:"subordinate"`
+	EventTime      time.Time   `json:"event_time"`
+	Description    string      `json:"description,omitempty"`
+	ExpectedReturn time.Time   `json:"expected_return"`
+	NotifyChatID   int64       `json:"notify_chat_id,omitempty"`
+	LastAlertAt    *time.Time  `json:"last_alert_at,omitempty"`
+}
+
+// Guardian - законный представитель подчиненного. TelegramID заполняется,
+// когда представитель подтверждает свой номер телефона в боте
+type Guardian struct {
 	ID            int       `json:"id"`
 	SubordinateID int       `json:"subordinate_id"`
-	ActivityTime  time.Time `json:"activity_time"`
-	Description   string    `json:"description"`
+	FullName      string    `json:"full_name"`
+	Relation      string    `json:"relation"`
+	Phone         string    `json:"phone"`
+	TelegramID    *int64    `json:"telegram_id,omitempty"`
+	Notify        bool      `json:"notify"`
 	CreatedAt     time.Time `json:"created_at"`
 }
+
+type SupervisorChat struct {
+	ChatID       int64     `json:"chat_id"`
+	Title        string    `json:"title"`
+	RegisteredBy int64     `json:"registered_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SubordinateProfile - сводка по подчиненному для карточки /who
+type SubordinateProfile struct {
+	Status           SubordinateStatus
+	Today            []Absence // уходы и деятельность за день в порядке времени
+	Leaves7          int
+	Activities7      int
+	Leaves30         int
+	Activities30     int
+	RecentActivities []UnplannedActivity
+	Guardians        []Guardian
+}
+
+// APIToken - токен доступа к HTTP API. Сам токен показывается только при создании
+type APIToken struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedBy  int64      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Webhook - адрес, на который отправляются события. Тело запроса подписывается HMAC-SHA256 с секретом
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	// Доставки, которые ещё ожидают отправки, и исчерпавшие попытки
+	Pending int `json:"pending"`
+	Failed  int `json:"failed"`
+}
+
+// WebhookDelivery - событие в очереди отправки на конкретный вебхук
+type WebhookDelivery struct {
+	ID        int
+	WebhookID int
+	URL       string
+	Secret    string
+	EventType string
+	Payload   string
+	Attempts  int
+}
+
+// Absence - уход или внеплановая деятельность с данными подчиненного, для календаря
+type Absence struct {
+	Kind           string
+	ID             int
+	Subordinate    Subordinate
+	Start          time.Time
+	Description    string
+	ExpectedReturn *time.Time
+	ReturnedAt     *time.Time
+	StaysOut       bool
+}
@@ -0,0 +1,247 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"whereismychildren/events"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// NewPostgresDB подключается к PostgreSQL по строке подключения и создаёт недостающие таблицы.
+// Полнотекстовый индекс FTS5 есть только в SQLite, поиск деятельности здесь выполняется перебором
+func NewPostgresDB(url string) (*DB, error) {
+	config, err := pgx.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	// DATE() в запросах считается в часовом поясе сессии - он должен совпадать с поясом бота
+	if _, ok := config.RuntimeParams["timezone"]; !ok {
+		config.RuntimeParams["timezone"] = localTimeZone()
+	}
+	db := stdlib.OpenDB(*config)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := InitPostgres(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{DB: db, driver: DriverPostgres, events: events.NewBus()}, nil
+}
+
+// localTimeZone возвращает часовой пояс процесса в виде, понятном PostgreSQL: имя из TZ
+// или /etc/localtime, а если его не определить - текущее смещение от UTC
+func localTimeZone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" {
+		return tz
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if i := strings.Index(target, "zoneinfo/"); i >= 0 {
+			return target[i+len("zoneinfo/"):]
+		}
+	}
+
+	// В формате POSIX знак смещения обратный: UTC+3 записывается как <+0300>-3:00
+	_, offset := time.Now().Zone()
+	nameSign, posixSign := "+", "-"
+	if offset < 0 {
+		nameSign, posixSign = "-", "+"
+		offset = -offset
+	}
+	hours, minutes := offset/3600, offset%3600/60
+	return fmt.Sprintf("<%s%02d%02d>%s%d:%02d", nameSign, hours, minutes, posixSign, hours, minutes)
+}
+
+// Схема PostgreSQL повторяет итоговую схему SQLite. Флаги хранятся в BOOLEAN, время - в TIMESTAMPTZ.
+// Индексы по DATE(время) здесь невозможны (функция зависит от часового пояса сессии),
+// поэтому индексируется само время
+var postgresSchema = []string{
+	`CREATE TABLE IF NOT EXISTS subordinates (
+		id BIGSERIAL PRIMARY KEY,
+		last_name TEXT NOT NULL,
+		first_name TEXT NOT NULL,
+		middle_name TEXT,
+		group_name TEXT,
+		archived_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(last_name, first_name, middle_name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS leaves (
+		id BIGSERIAL PRIMARY KEY,
+		subordinate_id BIGINT NOT NULL REFERENCES subordinates (id),
+		leave_time TIMESTAMPTZ NOT NULL,
+		expected_return TIMESTAMPTZ,
+		returned_at TIMESTAMPTZ,
+		notify_chat_id BIGINT,
+		alert_acknowledged BOOLEAN NOT NULL DEFAULT FALSE,
+		last_alert_at TIMESTAMPTZ,
+		stays_out BOOLEAN NOT NULL DEFAULT FALSE,
+		event_date DATE,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_leaves_time ON leaves (leave_time)`,
+	`CREATE TABLE IF NOT EXISTS unplanned_activities (
+		id BIGSERIAL PRIMARY KEY,
+		subordinate_id BIGINT NOT NULL REFERENCES subordinates (id),
+		activity_time TIMESTAMPTZ NOT NULL,
+		description TEXT NOT NULL,
+		expected_return TIMESTAMPTZ,
+		returned_at TIMESTAMPTZ,
+		notify_chat_id BIGINT,
+		alert_acknowledged BOOLEAN NOT NULL DEFAULT FALSE,
+		last_alert_at TIMESTAMPTZ,
+		stays_out BOOLEAN NOT NULL DEFAULT FALSE,
+		event_date DATE,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_activities_time ON unplanned_activities (activity_time)`,
+	// Дата события; уникальные индексы по времени записи создаёт migratePostgresRecordIndexes
+	`ALTER TABLE leaves ADD COLUMN IF NOT EXISTS event_date DATE`,
+	`ALTER TABLE unplanned_activities ADD COLUMN IF NOT EXISTS event_date DATE`,
+	`CREATE TABLE IF NOT EXISTS subscriptions (
+		chat_id BIGINT PRIMARY KEY,
+		report_status BOOLEAN NOT NULL DEFAULT TRUE,
+		report_statistics BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS guardians (
+		id BIGSERIAL PRIMARY KEY,
+		subordinate_id BIGINT NOT NULL REFERENCES subordinates (id),
+		full_name TEXT NOT NULL,
+		relation TEXT,
+		phone TEXT NOT NULL,
+		telegram_id BIGINT,
+		notify BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(subordinate_id, phone)
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_guardians_telegram_id ON guardians (telegram_id)`,
+	`CREATE TABLE IF NOT EXISTS supervisor_chats (
+		chat_id BIGINT PRIMARY KEY,
+		title TEXT,
+		registered_by BIGINT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS api_tokens (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		created_by BIGINT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		last_used_at TIMESTAMPTZ,
+		revoked_at TIMESTAMPTZ
+	)`,
+	`ALTER TABLE api_tokens ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT 'full'`,
+	`CREATE TABLE IF NOT EXISTS web_login_links (
+		id BIGSERIAL PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		user_id BIGINT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMPTZ NOT NULL,
+		used_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS web_sessions (
+		id BIGSERIAL PRIMARY KEY,
+		token_hash TEXT NOT NULL UNIQUE,
+		user_id BIGINT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		created_by BIGINT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		removed_at TIMESTAMPTZ
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		webhook_id BIGINT NOT NULL REFERENCES webhooks (id),
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		last_error TEXT,
+		delivered_at TIMESTAMPTZ,
+		failed_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending
+		ON webhook_deliveries (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL`,
+	// Номера событий, общие для всех процессов и не повторяющиеся после перезапуска
+	`CREATE SEQUENCE IF NOT EXISTS event_id_seq`,
+}
+
+// InitPostgres создаёт таблицы в базе PostgreSQL
+func InitPostgres(db *sql.DB) error {
+	for _, statement := range postgresSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return err
+		}
+	}
+
+	if err := migratePostgresRecordIndexes(db); err != nil {
+		return err
+	}
+
+	log.Println("PostgreSQL database initialized successfully")
+	return nil
+}
+
+// migratePostgresRecordIndexes один раз заполняет дату события и заменяет прежние индексы
+// ("одна запись в день" и неуникальный по времени) уникальным индексом "одна запись на минуту",
+// по которому запись обновляется через ON CONFLICT. Дубликаты удаляются только при создании индекса,
+// а не при каждом запуске; DDL в PostgreSQL транзакционный, поэтому шаг выполняется целиком или никак
+func migratePostgresRecordIndexes(db *sql.DB) error {
+	for _, table := range []struct{ name, timeColumn, index, dayIndex, timeIndex string }{
+		{"leaves", "leave_time", "idx_leaves_subordinate_leave_time",
+			"idx_leaves_subordinate_event_date", "idx_leaves_subordinate_time"},
+		{"unplanned_activities", "activity_time", "idx_activities_subordinate_activity_time",
+			"idx_activities_subordinate_event_date", "idx_activities_subordinate_time"},
+	} {
+		var exists bool
+		if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", table.index).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		statements := []string{
+			fmt.Sprintf("UPDATE %s SET event_date = DATE(%s) WHERE event_date IS NULL", table.name, table.timeColumn),
+			"DROP INDEX IF EXISTS " + table.dayIndex,
+			"DROP INDEX IF EXISTS " + table.timeIndex,
+			// Одинаковые записи могли остаться от повторных нажатий - сохраняется последняя
+			fmt.Sprintf("DELETE FROM %[1]s WHERE id NOT IN (SELECT MAX(id) FROM %[1]s GROUP BY subordinate_id, %[2]s)",
+				table.name, table.timeColumn),
+			fmt.Sprintf("CREATE UNIQUE INDEX %s ON %s (subordinate_id, %s)", table.index, table.name, table.timeColumn),
+		}
+		for _, statement := range statements {
+			if _, err := tx.Exec(statement); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Created index %s", table.index)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"time"
+)
+
+// Сколько последних описаний деятельности показывать в карточке
+const profileRecentActivities = 5
+
+// GetSubordinateProfile собирает карточку подчиненного: статус и события за день,
+// количество уходов и деятельности за 7 и 30 дней, последнюю деятельность и представителей
+func (db *DB) GetSubordinateProfile(sub Subordinate, date time.Time) (SubordinateProfile, error) {
+	var profile SubordinateProfile
+	var err error
+
+	if profile.Status, err = db.GetSubordinateStatus(sub, date); err != nil {
+		return profile, err
+	}
+
+	if profile.Today, err = db.GetAbsencesBetween(date, date, sub.ID, ""); err != nil {
+		return profile, err
+	}
+
+	if profile.Leaves7, profile.Activities7, err = db.CountEventsSince(sub.ID, date.AddDate(0, 0, -6)); err != nil {
+		return profile, err
+	}
+	if profile.Leaves30, profile.Activities30, err = db.CountEventsSince(sub.ID, date.AddDate(0, 0, -29)); err != nil {
+		return profile, err
+	}
+
+	if profile.RecentActivities, err = db.GetRecentActivities(sub.ID, profileRecentActivities); err != nil {
+		return profile, err
+	}
+
+	if profile.Guardians, err = db.GetGuardiansBySubordinate(sub.ID); err != nil {
+		return profile, err
+	}
+
+	return profile, nil
+}
+
+// CountEventsSince возвращает количество уходов и внеплановой деятельности подчиненного начиная с указанного дня
+func (db *DB) CountEventsSince(subordinateID int, since time.Time) (leaves, activities int, err error) {
+	sinceStr := since.Format("2006-01-02")
+
+	err = db.QueryRow(
+		"SELECT COUNT(*) FROM leaves WHERE subordinate_id = ? AND "+db.day("leave_time")+" >= ?",
+		subordinateID, sinceStr,
+	).Scan(&leaves)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	err = db.QueryRow(
+		"SELECT COUNT(*) FROM unplanned_activities WHERE subordinate_id = ? AND "+db.day("activity_time")+" >= ?",
+		subordinateID, sinceStr,
+	).Scan(&activities)
+	return leaves, activities, err
+}
+
+// GetRecentActivities возвращает последнюю внеплановую деятельность подчиненного, от новой к старой
+func (db *DB) GetRecentActivities(subordinateID int, limit int) ([]UnplannedActivity, error) {
+	rows, err := db.Query(`
+		SELECT id, subordinate_id, activity_time, description
+		FROM unplanned_activities
+		WHERE subordinate_id = ?
+		ORDER BY activity_time DESC
+		LIMIT ?
+	`, subordinateID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []UnplannedActivity
+	for rows.Next() {
+		var activity UnplannedActivity
+		if err := rows.Scan(&activity.ID, &activity.SubordinateID, &activity.ActivityTime, &activity.Description); err != nil {
+			return nil, err
+		}
+		activities = append(activities, activity)
+	}
+
+	return activities, nil
+}
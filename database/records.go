@@ -0,0 +1,181 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"whereismychildren/events"
+)
+
+// Максимальная длина описания внеплановой деятельности (в символах)
+const MaxDescriptionLength = 1000
+
+// Ошибки проверки данных, общие для бота и API
+var (
+	ErrSubordinateNotFound = errors.New("subordinate not found")
+	ErrSubordinateArchived = errors.New("subordinate is archived")
+	ErrInvalidName         = errors.New("last name and first name are required")
+	ErrEmptyDescription    = errors.New("activity description is empty")
+)
+
+// NormalizeDescription убирает пробелы по краям и ограничивает длину описания
+func NormalizeDescription(description string) (string, error) {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		return "", ErrEmptyDescription
+	}
+
+	if runes := []rune(description); len(runes) > MaxDescriptionLength {
+		description = string(runes[:MaxDescriptionLength])
+	}
+	return description, nil
+}
+
+// CreateSubordinate проверяет ФИО и добавляет подчиненного.
+// Возвращает ErrSubordinateExists, если такой подчиненный уже есть (в том числе в архиве)
+func (db *DB) CreateSubordinate(sub Subordinate) (Subordinate, error) {
+	sub.LastName = strings.TrimSpace(sub.LastName)
+	sub.FirstName = strings.TrimSpace(sub.FirstName)
+	sub.MiddleName = strings.TrimSpace(sub.MiddleName)
+	sub.Group = strings.TrimSpace(sub.Group)
+	if sub.LastName == "" || sub.FirstName == "" {
+		return sub, ErrInvalidName
+	}
+
+	existing, err := db.FindSubordinateByFullName(sub.LastName, sub.FirstName, sub.MiddleName)
+	if err != nil {
+		return sub, err
+	}
+	if existing != nil {
+		return *existing, ErrSubordinateExists
+	}
+
+	sub.ID, err = db.AddSubordinate(sub)
+	return sub, err
+}
+
+// ConflictError возвращается RecordLeave и RecordActivity без замены, если у подчиненного
+// уже есть запись того же вида на то же время (с точностью до минуты)
+type ConflictError struct {
+	Kind        string // KindLeave или KindActivity
+	ID          int
+	Time        time.Time
+	Description string // только для внеплановой деятельности
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s %d already recorded at %s", e.Kind, e.ID, e.Time.Format("15:04"))
+}
+
+// RecordLeave фиксирует уход по общим правилам бота и API: подчиненный должен быть в списке
+// и не в архиве. За день может быть несколько уходов и внеплановой деятельности; уход
+// на то же время, что и уже записанный, заменяет его. Без replace существующая запись
+// не заменяется, а возвращается *ConflictError
+func (db *DB) RecordLeave(subordinateID int, leaveTime time.Time, replace bool) (Leave, error) {
+	if err := db.checkRecordable(subordinateID); err != nil {
+		return Leave{}, err
+	}
+
+	leaveTime = leaveTime.Truncate(time.Minute)
+
+	var id int
+	err := db.inTx(func(tx *Tx) error {
+		if !replace {
+			conflict := ConflictError{Kind: KindLeave, Time: leaveTime}
+			err := tx.QueryRow("SELECT id FROM leaves WHERE subordinate_id = ? AND leave_time = ?",
+				subordinateID, leaveTime).Scan(&conflict.ID)
+			if err == nil {
+				return &conflict
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+
+		// Одинаковые уходы не допускает уникальный индекс; повторный уход начинает отслеживание возвращения заново
+		err := tx.QueryRow(`
+			INSERT INTO leaves (subordinate_id, leave_time, event_date) VALUES (?, ?, ?)
+			ON CONFLICT (subordinate_id, leave_time) DO UPDATE SET
+				expected_return = NULL, returned_at = NULL, notify_chat_id = NULL,
+				alert_acknowledged = FALSE, last_alert_at = NULL, stays_out = FALSE
+			RETURNING id`,
+			subordinateID, leaveTime, leaveTime.Format("2006-01-02"),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return tx.publishRecord(events.LeaveRecorded, KindLeave, id)
+	})
+	if err != nil {
+		return Leave{}, err
+	}
+
+	log.Printf("Recorded leave %d for subordinate %d at %s", id, subordinateID, leaveTime.Format("15:04"))
+	return getLeaveByID(db, id)
+}
+
+// RecordActivity фиксирует внеплановую деятельность по тем же правилам, что и RecordLeave.
+// Описание обязательно и обрезается до MaxDescriptionLength символов
+func (db *DB) RecordActivity(subordinateID int, activityTime time.Time, description string, replace bool) (UnplannedActivity, error) {
+	description, err := NormalizeDescription(description)
+	if err != nil {
+		return UnplannedActivity{}, err
+	}
+
+	if err := db.checkRecordable(subordinateID); err != nil {
+		return UnplannedActivity{}, err
+	}
+
+	activityTime = activityTime.Truncate(time.Minute)
+
+	var id int
+	err = db.inTx(func(tx *Tx) error {
+		if !replace {
+			conflict := ConflictError{Kind: KindActivity, Time: activityTime}
+			err := tx.QueryRow(
+				"SELECT id, description FROM unplanned_activities WHERE subordinate_id = ? AND activity_time = ?",
+				subordinateID, activityTime,
+			).Scan(&conflict.ID, &conflict.Description)
+			if err == nil {
+				return &conflict
+			}
+			if err != sql.ErrNoRows {
+				return err
+			}
+		}
+
+		err := tx.QueryRow(`
+			INSERT INTO unplanned_activities (subordinate_id, activity_time, description, event_date) VALUES (?, ?, ?, ?)
+			ON CONFLICT (subordinate_id, activity_time) DO UPDATE SET
+				description = excluded.description, expected_return = NULL, returned_at = NULL,
+				notify_chat_id = NULL, alert_acknowledged = FALSE, last_alert_at = NULL, stays_out = FALSE
+			RETURNING id`,
+			subordinateID, activityTime, description, activityTime.Format("2006-01-02"),
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return tx.publishRecord(events.ActivityRecorded, KindActivity, id)
+	})
+	if err != nil {
+		return UnplannedActivity{}, err
+	}
+
+	return getActivityByID(db, id)
+}
+
+// checkRecordable проверяет, что для подчиненного можно зафиксировать событие
+func (db *DB) checkRecordable(subordinateID int) error {
+	sub, err := db.GetSubordinateByID(subordinateID)
+	if err != nil {
+		return ErrSubordinateNotFound
+	}
+	if sub.ArchivedAt != nil {
+		return ErrSubordinateArchived
+	}
+	return nil
+}
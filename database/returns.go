@@ -0,0 +1,300 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"whereismychildren/events"
+)
+
+// tableForKind возвращает таблицу, в которой хранится отсутствие указанного вида
+func tableForKind(kind string) (string, error) {
+	switch kind {
+	case KindLeave:
+		return "leaves", nil
+	case KindActivity:
+		return "unplanned_activities", nil
+	default:
+		return "", fmt.Errorf("unknown absence kind: %s", kind)
+	}
+}
+
+// GetLeaveDetailsForDate возвращает последний уход каждого подчиненного за день вместе с данными о возвращении
+func (db *DB) GetLeaveDetailsForDate(date time.Time) (map[int]Leave, error) {
+	rows, err := db.Query(`
+		SELECT id, subordinate_id, leave_time, expected_return, returned_at, stays_out
+		FROM leaves
+		WHERE `+db.day("leave_time")+` = ?
+		ORDER BY leave_time, id
+	`, date.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	leaves := make(map[int]Leave)
+	for rows.Next() {
+		var leave Leave
+		if err := rows.Scan(&leave.ID, &leave.SubordinateID, &leave.LeaveTime,
+			&leave.ExpectedReturn, &leave.ReturnedAt, &leave.StaysOut); err != nil {
+			return nil, err
+		}
+		leave.LeaveTime = leave.LeaveTime.Truncate(time.Minute)
+		leaves[leave.SubordinateID] = leave
+	}
+
+	return leaves, nil
+}
+
+// GetLeaveForDate возвращает последний уход подчиненного за указанный день
+func (db *DB) GetLeaveForDate(subordinateID int, date time.Time) (Leave, error) {
+	var leave Leave
+	err := db.QueryRow(`
+		SELECT id, subordinate_id, leave_time, expected_return, returned_at, created_at
+		FROM leaves
+		WHERE subordinate_id = ? AND `+db.day("leave_time")+` = ?
+		ORDER BY leave_time DESC, id DESC
+		LIMIT 1
+	`, subordinateID, date.Format("2006-01-02")).Scan(
+		&leave.ID, &leave.SubordinateID, &leave.LeaveTime, &leave.ExpectedReturn, &leave.ReturnedAt, &leave.CreatedAt)
+	return leave, err
+}
+
+// GetActivityForDate возвращает последнюю внеплановую деятельность подчиненного за указанный день
+func (db *DB) GetActivityForDate(subordinateID int, date time.Time) (UnplannedActivity, error) {
+	var activity UnplannedActivity
+	err := db.QueryRow(`
+		SELECT id, subordinate_id, activity_time, description, expected_return, returned_at, created_at
+		FROM unplanned_activities
+		WHERE subordinate_id = ? AND `+db.day("activity_time")+` = ?
+		ORDER BY activity_time DESC, id DESC
+		LIMIT 1
+	`, subordinateID, date.Format("2006-01-02")).Scan(
+		&activity.ID, &activity.SubordinateID, &activity.ActivityTime, &activity.Description,
+		&activity.ExpectedReturn, &activity.ReturnedAt, &activity.CreatedAt)
+	return activity, err
+}
+
+// SetExpectedReturn задаёт ожидаемое время возвращения и чат, который получит напоминание
+func (db *DB) SetExpectedReturn(kind string, id int, expected time.Time, notifyChatID int64) error {
+	table, err := tableForKind(kind)
+	if err != nil {
+		return err
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		_, err := tx.Exec(fmt.Sprintf(`
+			UPDATE %s SET expected_return = ?, notify_chat_id = ?, alert_acknowledged = FALSE, last_alert_at = NULL
+			WHERE id = ?`, table),
+			expected, notifyChatID, id,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.publishRecord(events.ExpectedReturnSet, kind, id)
+	})
+}
+
+// MarkReturned фиксирует возвращение по конкретной записи
+func (db *DB) MarkReturned(kind string, id int, returnedAt time.Time) error {
+	table, err := tableForKind(kind)
+	if err != nil {
+		return err
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET returned_at = ? WHERE id = ?", table), returnedAt, id); err != nil {
+			return err
+		}
+		return tx.publishRecord(events.Returned, kind, id)
+	})
+}
+
+// MarkReturnedForDate фиксирует возвращение подчиненного по всем незакрытым отсутствиям за день.
+// Возвращает количество закрытых записей
+func (db *DB) MarkReturnedForDate(subordinateID int, returnedAt time.Time) (int64, error) {
+	dateStr := returnedAt.Format("2006-01-02")
+
+	// За день может быть несколько записей - событие публикуется по каждой закрытой
+	var total int64
+	err := db.inTx(func(tx *Tx) error {
+		for _, update := range []struct{ kind, query string }{
+			{KindLeave, "UPDATE leaves SET returned_at = ? WHERE subordinate_id = ? AND " + db.day("leave_time") + " = ? AND returned_at IS NULL RETURNING id"},
+			{KindActivity, "UPDATE unplanned_activities SET returned_at = ? WHERE subordinate_id = ? AND " + db.day("activity_time") + " = ? AND returned_at IS NULL RETURNING id"},
+		} {
+			rows, err := tx.Query(update.query, returnedAt, subordinateID, dateStr)
+			if err != nil {
+				return err
+			}
+			var ids []int
+			for rows.Next() {
+				var id int
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return err
+				}
+				ids = append(ids, id)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+
+			for _, id := range ids {
+				if err := tx.publishRecord(events.Returned, update.kind, id); err != nil {
+					return err
+				}
+			}
+			total += int64(len(ids))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// SetStaysOut отмечает, что подчиненный остаётся вне до следующего дня. Напоминания по записи отключаются
+func (db *DB) SetStaysOut(kind string, id int) error {
+	table, err := tableForKind(kind)
+	if err != nil {
+		return err
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		if _, err := tx.Exec(fmt.Sprintf("UPDATE %s SET stays_out = TRUE, alert_acknowledged = TRUE WHERE id = ?", table), id); err != nil {
+			return err
+		}
+		return tx.publishRecord(events.StaysOut, kind, id)
+	})
+}
+
+// AcknowledgeAlert отключает повторные напоминания по записи
+func (db *DB) AcknowledgeAlert(kind string, id int) error {
+	table, err := tableForKind(kind)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("UPDATE %s SET alert_acknowledged = TRUE WHERE id = ?", table), id)
+	return err
+}
+
+// GetNotifyChat возвращает чат, который получает напоминания по записи (0, если он не задан)
+func (db *DB) GetNotifyChat(kind string, id int) (int64, error) {
+	table, err := tableForKind(kind)
+	if err != nil {
+		return 0, err
+	}
+
+	var chatID int64
+	err = db.QueryRow(fmt.Sprintf("SELECT COALESCE(notify_chat_id, 0) FROM %s WHERE id = ?", table), id).Scan(&chatID)
+	return chatID, err
+}
+
+// MarkAlerted запоминает время последнего напоминания по записи
+func (db *DB) MarkAlerted(kind string, id int, alertedAt time.Time) error {
+	table, err := tableForKind(kind)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("UPDATE %s SET last_alert_at = ? WHERE id = ?", table), alertedAt, id)
+	return err
+}
+
+// GetPendingReturns возвращает отсутствия с ожидаемым временем возвращения,
+// по которым возвращение не зафиксировано и напоминания не отключены. Учитываются только записи,
+// возвращение по которым ожидается в день date или позже (вчерашние незакрытые записи остаются
+// в отчёте об отбое), и только действующие подчиненные
+func (db *DB) GetPendingReturns(date time.Time) ([]PendingReturn, error) {
+	day := date.Format("2006-01-02")
+	rows, err := db.Query(`
+		SELECT 'leave', l.id, s.id, s.last_name, s.first_name, s.middle_name,
+		       l.leave_time, '', l.expected_return, COALESCE(l.notify_chat_id, 0), l.last_alert_at
+		FROM leaves l
+		JOIN subordinates s ON l.subordinate_id = s.id
+		WHERE l.expected_return IS NOT NULL AND l.returned_at IS NULL AND NOT l.alert_acknowledged
+		  AND `+db.day("l.expected_return")+` >= ? AND s.archived_at IS NULL
+		UNION ALL
+		SELECT 'activity', u.id, s.id, s.last_name, s.first_name, s.middle_name,
+		       u.activity_time, u.description, u.expected_return, COALESCE(u.notify_chat_id, 0), u.last_alert_at
+		FROM unplanned_activities u
+		JOIN subordinates s ON u.subordinate_id = s.id
+		WHERE u.expected_return IS NOT NULL AND u.returned_at IS NULL AND NOT u.alert_acknowledged
+		  AND `+db.day("u.expected_return")+` >= ? AND s.archived_at IS NULL
+	`, day, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pending []PendingReturn
+	for rows.Next() {
+		var item PendingReturn
+		var eventTime, expectedReturn, lastAlertAt interface{}
+		if err := rows.Scan(
+			&item.Kind, &item.ID,
+			&item.Subordinate.ID, &item.Subordinate.LastName, &item.Subordinate.FirstName, &item.Subordinate.MiddleName,
+			&eventTime, &item.Description, &expectedReturn, &item.NotifyChatID, &lastAlertAt,
+		); err != nil {
+			return nil, err
+		}
+
+		// В UNION колонки теряют объявленный тип DATETIME, поэтому время разбираем вручную
+		if item.EventTime, err = parseStoredTime(eventTime); err != nil {
+			return nil, err
+		}
+		if item.ExpectedReturn, err = parseStoredTime(expectedReturn); err != nil {
+			return nil, err
+		}
+		if lastAlertAt != nil {
+			t, err := parseStoredTime(lastAlertAt)
+			if err != nil {
+				return nil, err
+			}
+			item.LastAlertAt = &t
+		}
+
+		pending = append(pending, item)
+	}
+
+	return pending, nil
+}
+
+// Форматы, в которых драйвер SQLite сохраняет время
+var storedTimeFormats = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// parseStoredTime разбирает значение времени, прочитанное из колонки без объявленного типа
+func parseStoredTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return parseTimeString(v)
+	case []byte:
+		return parseTimeString(string(v))
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value %T", value)
+	}
+}
+
+func parseTimeString(s string) (time.Time, error) {
+	for _, format := range storedTimeFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse time %q", s)
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"whereismychildren/events"
+)
+
+// ErrSubordinateExists возвращается, если подчиненный с таким ФИО уже есть
+var ErrSubordinateExists = errors.New("subordinate with this name already exists")
+
+// GetArchivedSubordinates возвращает подчиненных, перенесённых в архив
+func (db *DB) GetArchivedSubordinates() ([]Subordinate, error) {
+	return db.querySubordinates("WHERE archived_at IS NOT NULL")
+}
+
+// FindSubordinateByFullName ищет подчиненного (в том числе архивного) с точно совпадающим ФИО
+func (db *DB) FindSubordinateByFullName(lastName, firstName, middleName string) (*Subordinate, error) {
+	subordinates, err := db.querySubordinates(
+		"WHERE last_name = ? AND first_name = ? AND COALESCE(middle_name, '') = ?",
+		lastName, firstName, middleName,
+	)
+	if err != nil || len(subordinates) == 0 {
+		return nil, err
+	}
+	return &subordinates[0], nil
+}
+
+// RenameSubordinate меняет ФИО подчиненного. Возвращает ErrSubordinateExists, если такое ФИО уже занято
+func (db *DB) RenameSubordinate(id int, lastName, firstName, middleName string) error {
+	existing, err := db.FindSubordinateByFullName(lastName, firstName, middleName)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != id {
+		return ErrSubordinateExists
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		_, err := tx.Exec(
+			"UPDATE subordinates SET last_name = ?, first_name = ?, middle_name = ? WHERE id = ?",
+			lastName, firstName, middleName, id,
+		)
+		if err != nil {
+			return err
+		}
+		return tx.publishSubordinate(events.SubordinateUpdated, id)
+	})
+}
+
+// ArchiveSubordinate переносит подчиненного в архив. Возвращает false, если он уже в архиве
+func (db *DB) ArchiveSubordinate(id int) (bool, error) {
+	return db.updateSubordinate(events.SubordinateArchived, id,
+		"UPDATE subordinates SET archived_at = ? WHERE id = ? AND archived_at IS NULL",
+		time.Now(), id,
+	)
+}
+
+// RestoreSubordinate возвращает подчиненного из архива. Возвращает false, если он не был в архиве
+func (db *DB) RestoreSubordinate(id int) (bool, error) {
+	return db.updateSubordinate(events.SubordinateRestored, id,
+		"UPDATE subordinates SET archived_at = NULL WHERE id = ? AND archived_at IS NOT NULL",
+		id,
+	)
+}
+
+// updateSubordinate выполняет UPDATE подчиненного и, если запись изменилась, публикует событие eventType
+func (db *DB) updateSubordinate(eventType string, id int, query string, args ...interface{}) (bool, error) {
+	var updated bool
+	err := db.inTx(func(tx *Tx) error {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil || affected == 0 {
+			return err
+		}
+
+		updated = true
+		return tx.publishSubordinate(eventType, id)
+	})
+	return updated, err
+}
+
+// MergeSubordinates объединяет дубликат с основной записью: уходы, деятельность и представители
+// переходят к основной записи, дубликат удаляется. Если у обоих есть запись на одно и то же время,
+// остаётся запись основной
+func (db *DB) MergeSubordinates(keepID, duplicateID int) error {
+	if keepID == duplicateID {
+		return errors.New("cannot merge subordinate with itself")
+	}
+
+	// Каждому запросу передаются только те аргументы, на которые он ссылается: PostgreSQL
+	// не может определить тип параметра $1, если в запросе есть только $2
+	both := []interface{}{keepID, duplicateID}
+	duplicate := []interface{}{duplicateID}
+	statements := []struct {
+		query string
+		args  []interface{}
+		// Вид удаляемых записей: о каждой удалённой записи публикуется событие
+		deletedKind string
+	}{
+		// Одинаковые записи (то же время) у основной записи уже есть
+		{`DELETE FROM leaves WHERE subordinate_id = ?2
+			AND leave_time IN (SELECT leave_time FROM leaves WHERE subordinate_id = ?1)`, both, KindLeave},
+		{`UPDATE leaves SET subordinate_id = ?1 WHERE subordinate_id = ?2`, both, ""},
+		{`DELETE FROM unplanned_activities WHERE subordinate_id = ?2
+			AND activity_time IN (SELECT activity_time FROM unplanned_activities WHERE subordinate_id = ?1)`, both, KindActivity},
+		{`UPDATE unplanned_activities SET subordinate_id = ?1 WHERE subordinate_id = ?2`, both, ""},
+		// Представители с тем же телефоном у основной записи уже есть
+		{`UPDATE guardians SET subordinate_id = ?1 WHERE subordinate_id = ?2
+			AND phone NOT IN (SELECT phone FROM guardians WHERE subordinate_id = ?1)`, both, ""},
+		{`DELETE FROM guardians WHERE subordinate_id = ?`, duplicate, ""},
+		{`DELETE FROM subordinates WHERE id = ?`, duplicate, ""},
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		for _, statement := range statements {
+			if statement.deletedKind == "" {
+				if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+					return err
+				}
+				continue
+			}
+
+			ids, err := deletedIDs(tx, statement.query, statement.args...)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				err := tx.publish(events.Event{
+					Type:          events.RecordDeleted,
+					SubordinateID: duplicateID,
+					Kind:          statement.deletedKind,
+					RecordID:      id,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		sub, err := getSubordinateByID(tx, keepID)
+		if err != nil {
+			return err
+		}
+		return tx.publish(events.Event{
+			Type:          events.SubordinatesMerged,
+			SubordinateID: keepID,
+			Data: struct {
+				Subordinate
+				MergedID int `json:"merged_id"`
+			}{sub, duplicateID},
+		})
+	})
+}
+
+// deletedIDs выполняет DELETE и возвращает id удалённых записей
+func deletedIDs(tx *Tx, query string, args ...interface{}) ([]int, error) {
+	rows, err := tx.Query(query+" RETURNING id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
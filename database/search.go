@@ -0,0 +1,151 @@
+package database
+
+import (
+	"log"
+	"sort"
+	"strings"
+
+	"whereismychildren/utils"
+)
+
+// SubordinateMatch - подчиненный, найденный по запросу, с оценкой совпадения
+type SubordinateMatch struct {
+	Subordinate
+	Score int
+	// Все слова запроса совпали с ФИО точно, без опечаток, транслитерации и сокращений
+	Exact bool
+}
+
+// SearchSubordinates ищет подчиненных по ФИО без учёта регистра и ё/е, с опечатками,
+// латиницей ("Ivanov") и инициалами ("Иванов И."). Результаты отсортированы по убыванию оценки.
+// Сравнение выполняется в Go: LOWER() в SQLite не работает с кириллицей
+func (db *DB) SearchSubordinates(query string) ([]SubordinateMatch, error) {
+	tokens := utils.NameTokens(query)
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	subordinates, err := db.GetAllSubordinates()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := RankSubordinates(subordinates, query)
+	log.Printf("Search '%s': %d matches", query, len(matches))
+	return matches, nil
+}
+
+// RankSubordinates оценивает совпадение каждого подчиненного из списка с запросом
+// и возвращает подходящих по убыванию оценки
+func RankSubordinates(subordinates []Subordinate, query string) []SubordinateMatch {
+	tokens := utils.NameTokens(query)
+	exact := utils.ExactNameScore(tokens)
+
+	var matches []SubordinateMatch
+	for _, sub := range subordinates {
+		if score := utils.ScoreName(tokens, sub.LastName, sub.FirstName, sub.MiddleName); score > 0 {
+			matches = append(matches, SubordinateMatch{Subordinate: sub, Score: score, Exact: score >= exact})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return lessByName(matches[i].Subordinate, matches[j].Subordinate)
+	})
+
+	return matches
+}
+
+// BestMatches оставляет подчиненных с наивысшей оценкой
+func BestMatches(matches []SubordinateMatch) []Subordinate {
+	var best []Subordinate
+	for _, match := range matches {
+		if match.Score < matches[0].Score {
+			break
+		}
+		best = append(best, match.Subordinate)
+	}
+	return best
+}
+
+// FindSubordinatesByName ищет подчиненных, у которых фамилия или имя содержит указанный текст
+func (db *DB) FindSubordinatesByName(lastName, firstName string) ([]Subordinate, error) {
+	log.Printf("Partial search: lastName='%s', firstName='%s'", lastName, firstName)
+
+	lastName, firstName = utils.NormalizeName(lastName), utils.NormalizeName(firstName)
+	return db.filterSubordinates(func(sub Subordinate) bool {
+		return containsName(sub.LastName, lastName) || containsName(sub.FirstName, firstName)
+	})
+}
+
+// FindSubordinatesByExactName ищет подчиненных с совпадающей фамилией или именем
+func (db *DB) FindSubordinatesByExactName(lastName, firstName string) ([]Subordinate, error) {
+	log.Printf("Exact search: lastName='%s', firstName='%s'", lastName, firstName)
+
+	lastName, firstName = utils.NormalizeName(lastName), utils.NormalizeName(firstName)
+	return db.filterSubordinates(func(sub Subordinate) bool {
+		return equalName(sub.LastName, lastName) || equalName(sub.FirstName, firstName)
+	})
+}
+
+// FindSubordinatesByFullName ищет по фамилии и имени; одно из них может совпадать частично
+func (db *DB) FindSubordinatesByFullName(lastName, firstName string) ([]Subordinate, error) {
+	log.Printf("Full name search: lastName='%s', firstName='%s'", lastName, firstName)
+
+	lastName, firstName = utils.NormalizeName(lastName), utils.NormalizeName(firstName)
+	return db.filterSubordinates(func(sub Subordinate) bool {
+		return (equalName(sub.LastName, lastName) && containsName(sub.FirstName, firstName)) ||
+			(containsName(sub.LastName, lastName) && equalName(sub.FirstName, firstName))
+	})
+}
+
+// FindSubordinatesBySingleTerm ищет подчиненных, у которых фамилия или имя совпадает с термином
+func (db *DB) FindSubordinatesBySingleTerm(term string) ([]Subordinate, error) {
+	log.Printf("Single term search: '%s'", term)
+
+	term = utils.NormalizeName(term)
+	return db.filterSubordinates(func(sub Subordinate) bool {
+		return equalName(sub.LastName, term) || equalName(sub.FirstName, term)
+	})
+}
+
+// filterSubordinates возвращает подчиненных, подходящих под условие, в алфавитном порядке
+func (db *DB) filterSubordinates(match func(Subordinate) bool) ([]Subordinate, error) {
+	subordinates, err := db.GetAllSubordinates()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []Subordinate
+	for _, sub := range subordinates {
+		if match(sub) {
+			result = append(result, sub)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return lessByName(result[i], result[j])
+	})
+
+	log.Printf("Total subordinates found: %d", len(result))
+	return result, nil
+}
+
+// equalName сравнивает имя с уже нормализованным термином
+func equalName(name, term string) bool {
+	return term != "" && utils.NormalizeName(name) == term
+}
+
+// containsName проверяет, что имя содержит уже нормализованный термин
+func containsName(name, term string) bool {
+	return term != "" && strings.Contains(utils.NormalizeName(name), term)
+}
+
+func lessByName(a, b Subordinate) bool {
+	if a.LastName != b.LastName {
+		return a.LastName < b.LastName
+	}
+	return a.FirstName < b.FirstName
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"whereismychildren/events"
+
+	_ "modernc.org/sqlite"
+)
+
+// NewSQLiteDB открывает файл SQLite указанным драйвером: DriverSQLite (mattn/go-sqlite3)
+// или DriverSQLitePure (modernc.org/sqlite). Оба драйвера пишут время в одном формате,
+// поэтому базу можно открывать любым из них
+func NewSQLiteDB(driver, dbPath string) (*DB, error) {
+	driver = sqliteDriver(driver)
+
+	dsn := dbPath
+	if driver == DriverSQLitePure {
+		// По умолчанию modernc.org/sqlite пишет время в формате time.Time.String(),
+		// который не понимают функции даты SQLite и драйвер mattn/go-sqlite3
+		if !strings.Contains(dsn, "_time_format=") {
+			dsn = addDSNParam(dsn, "_time_format=sqlite")
+		}
+		// Транзакции записи ждут друг друга, как в mattn/go-sqlite3 (там ожидание 5 секунд по умолчанию)
+		if !strings.Contains(dsn, "busy_timeout") {
+			dsn = addDSNParam(dsn, "_pragma=busy_timeout(5000)")
+		}
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := InitDB(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	fullTextSearch, err := initActivitySearch(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := migrateRecordIndexes(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{DB: db, driver: driver, fullTextSearch: fullTextSearch, events: events.NewBus()}, nil
+}
+
+// sqliteDriver возвращает драйвер, которым будет открыт файл (названия те же, что в Open):
+// без CGO вместо mattn/go-sqlite3 используется драйвер на чистом Go
+func sqliteDriver(driver string) string {
+	switch strings.ToLower(driver) {
+	case "", DriverSQLitePure, "purego":
+		return DriverSQLitePure
+	}
+	if !cgoSQLite {
+		log.Println("Bot is built without CGO, using pure Go SQLite driver")
+		return DriverSQLitePure
+	}
+	return DriverSQLite
+}
+
+// addDSNParam добавляет параметр к строке подключения SQLite
+func addDSNParam(dsn, param string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + param
+	}
+	return dsn + "?" + param
+}
@@ -0,0 +1,8 @@
+//go:build cgo
+
+package database
+
+import _ "github.com/mattn/go-sqlite3"
+
+// cgoSQLite - бот собран с CGO и может использовать mattn/go-sqlite3
+const cgoSQLite = true
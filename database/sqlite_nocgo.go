@@ -0,0 +1,6 @@
+//go:build !cgo
+
+package database
+
+// cgoSQLite - без CGO драйвер mattn/go-sqlite3 недоступен, SQLite открывается драйвером на чистом Go
+const cgoSQLite = false
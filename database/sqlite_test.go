@@ -0,0 +1,165 @@
+package database_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/database/dbtest"
+)
+
+// sqliteDrivers возвращает драйверы SQLite, вошедшие в сборку (mattn/go-sqlite3 - только с CGO)
+func sqliteDrivers() []string {
+	var drivers []string
+	for _, driver := range []string{database.DriverSQLite, database.DriverSQLitePure} {
+		if slices.Contains(sql.Drivers(), driver) {
+			drivers = append(drivers, driver)
+		}
+	}
+	return drivers
+}
+
+func openSQLite(t *testing.T, driver, path string) *database.DB {
+	t.Helper()
+
+	db, err := database.Open(driver, path)
+	if err != nil {
+		t.Fatalf("failed to open %s with %s: %v", path, driver, err)
+	}
+	return db
+}
+
+// Уход в 01:30 по Москве должен относиться к тому же дню, а не к предыдущему (как дал бы DATE() в UTC)
+func TestSQLiteDriversKeepLocalDate(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	leaveTime := time.Date(2026, 3, 10, 1, 30, 0, 0, moscow)
+
+	for _, driver := range sqliteDrivers() {
+		t.Run(driver, func(t *testing.T) {
+			db := openSQLite(t, driver, filepath.Join(t.TempDir(), "bot.db"))
+			defer db.Close()
+			sub := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")[0]
+
+			leave, err := db.RecordLeave(sub.ID, leaveTime, true)
+			if err != nil {
+				t.Fatalf("RecordLeave: %v", err)
+			}
+			if !leave.LeaveTime.Equal(leaveTime) || leave.LeaveTime.Format("15:04") != "01:30" {
+				t.Fatalf("expected leave at %v, got %v", leaveTime, leave.LeaveTime)
+			}
+
+			statuses, err := db.GetStatusesForDate(leaveTime)
+			if err != nil {
+				t.Fatalf("GetStatusesForDate: %v", err)
+			}
+			if len(statuses) != 1 || statuses[0].Status != database.StatusLeft {
+				t.Fatalf("leave is not counted for its day: %+v", statuses)
+			}
+
+			previousDay := leaveTime.AddDate(0, 0, -1)
+			if leaves, err := db.GetLeavesBetween(previousDay, previousDay); err != nil || len(leaves) != 0 {
+				t.Fatalf("leave leaked into the previous day: %+v (%v)", leaves, err)
+			}
+		})
+	}
+}
+
+// База, записанная одним драйвером, читается и дописывается другим без изменений.
+// Деятельность проверяет индекс FTS5: у mattn/go-sqlite3 без тега sqlite_fts5 модуля нет,
+// и триггеры индекса, созданные другим драйвером, не должны ломать запись
+func TestSQLiteDriversShareDatabaseFile(t *testing.T) {
+	drivers := sqliteDrivers()
+	if len(drivers) < 2 {
+		t.Skip("built without CGO, only the pure Go SQLite driver is available")
+	}
+
+	leaveTime := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	expected := leaveTime.Add(3 * time.Hour)
+
+	for _, pair := range [][2]string{{drivers[0], drivers[1]}, {drivers[1], drivers[0]}} {
+		writer, reader := pair[0], pair[1]
+		t.Run(writer+"->"+reader, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bot.db")
+
+			db := openSQLite(t, writer, path)
+			sub := dbtest.AddSubordinates(t, db, "Петров Петр Петрович")[0]
+			leave, err := db.RecordLeave(sub.ID, leaveTime, true)
+			if err != nil {
+				t.Fatalf("RecordLeave: %v", err)
+			}
+			if err := db.SetExpectedReturn(database.KindLeave, leave.ID, expected, 0); err != nil {
+				t.Fatalf("SetExpectedReturn: %v", err)
+			}
+			if _, err := db.RecordActivity(sub.ID, leaveTime.Add(-time.Hour), "Олимпиада по физике", true); err != nil {
+				t.Fatalf("RecordActivity with %s: %v", writer, err)
+			}
+			db.Close()
+
+			db = openSQLite(t, reader, path)
+
+			got, err := db.GetLeaveForDate(sub.ID, leaveTime)
+			if err != nil {
+				t.Fatalf("GetLeaveForDate: %v", err)
+			}
+			if !got.LeaveTime.Equal(leaveTime) {
+				t.Fatalf("expected leave at %v, got %v", leaveTime, got.LeaveTime)
+			}
+			if got.ExpectedReturn == nil || !got.ExpectedReturn.Equal(expected) {
+				t.Fatalf("expected return %v, got %v", expected, got.ExpectedReturn)
+			}
+			if got.CreatedAt.IsZero() {
+				t.Fatal("created_at was not read")
+			}
+
+			pending, err := db.GetPendingReturns(expected)
+			if err != nil || len(pending) != 1 || !pending[0].EventTime.Equal(leaveTime) {
+				t.Fatalf("unexpected pending returns: %+v (%v)", pending, err)
+			}
+
+			if _, err := db.RecordActivity(sub.ID, leaveTime.Add(-2*time.Hour), "Олимпиада по химии", true); err != nil {
+				t.Fatalf("RecordActivity with %s: %v", reader, err)
+			}
+			found, err := db.SearchActivities("олимпиада", time.Time{}, time.Time{})
+			if err != nil {
+				t.Fatalf("SearchActivities with %s: %v", reader, err)
+			}
+			if len(found) != 2 {
+				t.Fatalf("expected both activities to be found, got %+v", found)
+			}
+			db.Close()
+
+			// Вернувшись к первому драйверу, поиск находит и запись второго
+			db = openSQLite(t, writer, path)
+			defer db.Close()
+			found, err = db.SearchActivities("химии", time.Time{}, time.Time{})
+			if err != nil || len(found) != 1 {
+				t.Fatalf("expected activity written by %s to be found with %s: %+v (%v)", reader, writer, found, err)
+			}
+		})
+	}
+}
+
+// Номер события выдаёт база, поэтому после перезапуска нумерация продолжается, а не начинается с 1
+func TestEventIDsContinueAfterReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+
+	addAndGetEventID := func(name string) uint64 {
+		t.Helper()
+		db := openSQLite(t, database.DriverSQLitePure, path)
+		defer db.Close()
+
+		published, unsubscribe := db.Events().Subscribe()
+		defer unsubscribe()
+		dbtest.AddSubordinates(t, db, name)
+		return (<-published).ID
+	}
+
+	first := addAndGetEventID("Иванов Иван Иванович")
+	second := addAndGetEventID("Петров Петр Петрович")
+	if first == 0 || second <= first {
+		t.Fatalf("expected event ids to continue after reopening, got %d and then %d", first, second)
+	}
+}
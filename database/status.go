@@ -0,0 +1,109 @@
+package database
+
+import (
+	"log"
+	"sort"
+	"time"
+)
+
+// GetStatusesForDate собирает статус каждого подчиненного за указанный день.
+// Статус определяется последней записью за день - уходом или внеплановой деятельностью.
+func (db *DB) GetStatusesForDate(date time.Time) ([]SubordinateStatus, error) {
+	subordinates, err := db.GetAllSubordinates()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(subordinates, func(i, j int) bool {
+		if subordinates[i].LastName != subordinates[j].LastName {
+			return subordinates[i].LastName < subordinates[j].LastName
+		}
+		return subordinates[i].FirstName < subordinates[j].FirstName
+	})
+
+	leaves, err := db.GetLeaveDetailsForDate(date)
+	if err != nil {
+		log.Printf("Error getting leaves for %s: %v", date.Format("2006-01-02"), err)
+		leaves = make(map[int]Leave)
+	}
+
+	activities, err := db.GetUnplannedActivitiesForDate(date)
+	if err != nil {
+		log.Printf("Error getting activities for %s: %v", date.Format("2006-01-02"), err)
+		activities = make(map[int]UnplannedActivity)
+	}
+
+	statuses := make([]SubordinateStatus, 0, len(subordinates))
+	for _, sub := range subordinates {
+		statuses = append(statuses, buildStatus(sub, leaves, activities))
+	}
+
+	return statuses, nil
+}
+
+// GetSubordinateStatus возвращает статус одного подчиненного за указанный день
+func (db *DB) GetSubordinateStatus(sub Subordinate, date time.Time) (SubordinateStatus, error) {
+	leaves, err := db.GetLeaveDetailsForDate(date)
+	if err != nil {
+		return SubordinateStatus{}, err
+	}
+
+	activities, err := db.GetUnplannedActivitiesForDate(date)
+	if err != nil {
+		return SubordinateStatus{}, err
+	}
+
+	return buildStatus(sub, leaves, activities), nil
+}
+
+// buildStatus определяет статус подчиненного по последнему уходу и последней деятельности за день:
+// действует более поздняя запись, при одинаковом времени - деятельность
+func buildStatus(sub Subordinate, leaves map[int]Leave, activities map[int]UnplannedActivity) SubordinateStatus {
+	status := SubordinateStatus{Subordinate: sub, Status: StatusPresent}
+
+	leave, hasLeave := leaves[sub.ID]
+	activity, hasActivity := activities[sub.ID]
+	if hasLeave && hasActivity && leave.LeaveTime.After(activity.ActivityTime) {
+		hasActivity = false
+	}
+
+	if hasLeave && !hasActivity {
+		status.LeaveTime = &leave.LeaveTime
+		status.Status = StatusLeft
+		status.Kind, status.RecordID = KindLeave, leave.ID
+		status.ReturnedAt = leave.ReturnedAt
+		status.ExpectedReturn = leave.ExpectedReturn
+		status.StaysOut = leave.StaysOut
+	}
+
+	if hasActivity {
+		status.Activity = &activity
+		status.Status = StatusActivity
+		status.Kind, status.RecordID = KindActivity, activity.ID
+		status.ReturnedAt = activity.ReturnedAt
+		status.ExpectedReturn = activity.ExpectedReturn
+		status.StaysOut = activity.StaysOut
+	}
+
+	if status.ReturnedAt != nil {
+		status.Status = StatusReturned
+	}
+
+	return status
+}
+
+// CountStatuses подсчитывает количество подчиненных на месте, ушедших и на внеплановой деятельности.
+// Вернувшиеся считаются находящимися на месте
+func CountStatuses(statuses []SubordinateStatus) (present, left, activity int) {
+	for _, status := range statuses {
+		switch status.Status {
+		case StatusActivity:
+			activity++
+		case StatusLeft:
+			left++
+		default:
+			present++
+		}
+	}
+	return present, left, activity
+}
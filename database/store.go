@@ -0,0 +1,94 @@
+package database
+
+import (
+	"time"
+
+	"whereismychildren/events"
+)
+
+// Store - операции с данными, которые используют бот, импорт Excel, HTTP API, веб-панель и вебхуки.
+// Реализуется *DB поверх SQLite или PostgreSQL (см. Open)
+type Store interface {
+	Close() error
+	Driver() string
+	Events() *events.Bus
+	Backup(path string) error
+
+	// Подчиненные
+	AddSubordinate(sub Subordinate) (int, error)
+	CreateSubordinate(sub Subordinate) (Subordinate, error)
+	SetSubordinateGroup(id int, group string) error
+	GetSubordinateByID(id int) (Subordinate, error)
+	GetAllSubordinates() ([]Subordinate, error)
+	GetSubordinatesIncludingArchived() ([]Subordinate, error)
+	GetArchivedSubordinates() ([]Subordinate, error)
+	FindSubordinatesByName(lastName, firstName string) ([]Subordinate, error)
+	SearchSubordinates(query string) ([]SubordinateMatch, error)
+	GetSubordinateProfile(sub Subordinate, date time.Time) (SubordinateProfile, error)
+	RenameSubordinate(id int, lastName, firstName, middleName string) error
+	ArchiveSubordinate(id int) (bool, error)
+	RestoreSubordinate(id int) (bool, error)
+	MergeSubordinates(keepID, duplicateID int) error
+
+	// Уходы и внеплановая деятельность
+	RecordLeave(subordinateID int, leaveTime time.Time, replace bool) (Leave, error)
+	RecordActivity(subordinateID int, activityTime time.Time, description string, replace bool) (UnplannedActivity, error)
+	GetLeaveForDate(subordinateID int, date time.Time) (Leave, error)
+	GetActivityForDate(subordinateID int, date time.Time) (UnplannedActivity, error)
+	GetStatusesForDate(date time.Time) ([]SubordinateStatus, error)
+	GetLeavesBetween(from, to time.Time) ([]LeaveRecord, error)
+	GetUnplannedActivitiesBetween(from, to time.Time) ([]ActivityRecord, error)
+	GetAbsencesBetween(from, to time.Time, subordinateID int, group string) ([]Absence, error)
+	SearchActivities(text string, from, to time.Time) ([]ActivityRecord, error)
+	GetAllDataForExport() ([]ExportRecord, error)
+
+	// Возвращение
+	SetExpectedReturn(kind string, id int, expected time.Time, notifyChatID int64) error
+	MarkReturned(kind string, id int, returnedAt time.Time) error
+	MarkReturnedForDate(subordinateID int, returnedAt time.Time) (int64, error)
+	SetStaysOut(kind string, id int) error
+	AcknowledgeAlert(kind string, id int) error
+	GetNotifyChat(kind string, id int) (int64, error)
+	MarkAlerted(kind string, id int, alertedAt time.Time) error
+	GetPendingReturns(date time.Time) ([]PendingReturn, error)
+
+	// Представители
+	AddGuardian(guardian Guardian) error
+	GetGuardiansByTelegramID(telegramID int64) ([]Guardian, error)
+	GetNotifiableGuardians(subordinateID int) ([]Guardian, error)
+	LinkGuardiansByPhone(phone string, telegramID int64) ([]Guardian, error)
+	SetGuardianNotifications(telegramID int64, enabled bool) error
+	CountGuardians() (int, error)
+
+	// Чаты: подписки на отчёты и групповые чаты ответственных
+	Subscribe(chatID int64, status, statistics bool) error
+	Unsubscribe(chatID int64) (bool, error)
+	GetSubscriptions() ([]Subscription, error)
+	RegisterSupervisorChat(chatID int64, title string, registeredBy int64) error
+	UnregisterSupervisorChat(chatID int64) (bool, error)
+	IsSupervisorChat(chatID int64) (bool, error)
+	GetSupervisorChats() ([]SupervisorChat, error)
+
+	// Доступ к HTTP API и веб-панели
+	CreateAPIToken(name, scope string, createdBy int64) (string, error)
+	ValidateAPIToken(token string) (*APIToken, error)
+	GetAPITokens() ([]APIToken, error)
+	RevokeAPIToken(id int) (bool, error)
+	CreateLoginLink(userID int64, ttl time.Duration) (string, error)
+	UseLoginLink(token string) (int64, bool, error)
+	CreateWebSession(userID int64, ttl time.Duration) (string, error)
+	ValidateWebSession(token string) (bool, error)
+	DeleteWebSession(token string) error
+	DeleteExpiredWebLogins() error
+
+	// Вебхуки
+	AddWebhook(url string, createdBy int64) (Webhook, error)
+	GetWebhooks() ([]Webhook, error)
+	RemoveWebhook(id int) (bool, error)
+	GetDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error)
+	MarkWebhookDelivered(id int, deliveredAt time.Time) error
+	RetryWebhookDelivery(id int, deliveryErr string, nextAttempt time.Time) error
+	FailWebhookDelivery(id int, deliveryErr string, failedAt time.Time) error
+}
+
+var _ Store = (*DB)(nil)
@@ -0,0 +1,412 @@
+package database_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/database/dbtest"
+	"whereismychildren/events"
+)
+
+// Тесты хранилища запускаются с той базой, которую выбирает dbtest (SQLite или PostgreSQL)
+
+func TestLeavesAndActivitiesCoexistForDay(t *testing.T) {
+	db := dbtest.New(t)
+	sub := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")[0]
+	day := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+
+	if _, err := db.RecordActivity(sub.ID, day, "Олимпиада", false); err != nil {
+		t.Fatalf("RecordActivity: %v", err)
+	}
+	leave, err := db.RecordLeave(sub.ID, day.Add(9*time.Hour), false)
+	if err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+
+	// Статус показывает последнюю запись за день
+	status, err := db.GetSubordinateStatus(sub, day)
+	if err != nil {
+		t.Fatalf("GetSubordinateStatus: %v", err)
+	}
+	if status.Status != database.StatusLeft || status.RecordID != leave.ID {
+		t.Fatalf("expected the evening leave to be the status, got %+v", status)
+	}
+
+	if _, err := db.RecordActivity(sub.ID, day.Add(11*time.Hour), "Секция", false); err != nil {
+		t.Fatalf("RecordActivity: %v", err)
+	}
+	status, err = db.GetSubordinateStatus(sub, day)
+	if err != nil {
+		t.Fatalf("GetSubordinateStatus: %v", err)
+	}
+	if status.Status != database.StatusActivity || status.Activity.Description != "Секция" {
+		t.Fatalf("expected the latest activity to be the status, got %+v", status)
+	}
+
+	absences, err := db.GetAbsencesBetween(day, day, sub.ID, "")
+	if err != nil {
+		t.Fatalf("GetAbsencesBetween: %v", err)
+	}
+	var got []string
+	for _, absence := range absences {
+		got = append(got, absence.Kind+" "+absence.Start.Format("15:04"))
+	}
+	if want := "activity 09:00,leave 18:00,activity 20:00"; strings.Join(got, ",") != want {
+		t.Fatalf("expected records %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+func TestRecordLeaveAtTheSameTimeAsksToReplace(t *testing.T) {
+	db := dbtest.New(t)
+	sub := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")[0]
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+
+	first, err := db.RecordLeave(sub.ID, day, false)
+	if err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	if err := db.SetExpectedReturn(database.KindLeave, first.ID, day.Add(time.Hour), 42); err != nil {
+		t.Fatalf("SetExpectedReturn: %v", err)
+	}
+
+	_, err = db.RecordLeave(sub.ID, day.Add(15*time.Second), false)
+	var conflict *database.ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected ConflictError, got %v", err)
+	}
+	if conflict.Kind != database.KindLeave || conflict.ID != first.ID || conflict.Time.Format("15:04") != "12:00" {
+		t.Fatalf("unexpected conflict: %+v", conflict)
+	}
+
+	second, err := db.RecordLeave(sub.ID, day.Add(15*time.Second), true)
+	if err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Fatalf("leave should be updated in place, got new id %d (was %d)", second.ID, first.ID)
+	}
+	if second.ExpectedReturn != nil {
+		t.Fatalf("repeated leave should reset expected return, got %v", second.ExpectedReturn)
+	}
+
+	// Уход в другое время - новая запись без вопросов
+	if _, err := db.RecordLeave(sub.ID, day.Add(time.Hour), false); err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	absences, err := db.GetAbsencesBetween(day, day, sub.ID, "")
+	if err != nil || len(absences) != 2 {
+		t.Fatalf("expected 2 leaves, got %+v (%v)", absences, err)
+	}
+}
+
+func TestExportListsEveryRecord(t *testing.T) {
+	db := dbtest.New(t)
+	subs := dbtest.AddSubordinates(t, db, "Петров Петр Петрович", "Иванов Иван Иванович")
+	day := time.Date(2026, 3, 10, 9, 0, 0, 0, time.Local)
+
+	if _, err := db.RecordLeave(subs[0].ID, day.Add(time.Hour), false); err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	if _, err := db.RecordActivity(subs[1].ID, day, "Олимпиада", false); err != nil {
+		t.Fatalf("RecordActivity: %v", err)
+	}
+	if _, err := db.RecordLeave(subs[1].ID, day.Add(8*time.Hour), false); err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+
+	records, err := db.GetAllDataForExport()
+	if err != nil {
+		t.Fatalf("GetAllDataForExport: %v", err)
+	}
+	var got []string
+	for _, record := range records {
+		got = append(got, fmt.Sprintf("%s %s %s", record.Date.Format("02.01"), record.Subordinate.LastName,
+			record.EventTime().Format("15:04")))
+	}
+	if want := "10.03 Иванов 09:00,10.03 Иванов 17:00,10.03 Петров 10:00"; strings.Join(got, ",") != want {
+		t.Fatalf("expected export rows %s, got %s", want, strings.Join(got, ","))
+	}
+	if records[0].ActivityDesc == nil || *records[0].ActivityDesc != "Олимпиада" || records[0].LeaveTime != nil {
+		t.Fatalf("first row should be the activity, got %+v", records[0])
+	}
+}
+
+func TestPendingReturnsAndStaysOut(t *testing.T) {
+	db := dbtest.New(t)
+	sub := dbtest.AddSubordinates(t, db, "Петров Петр Петрович")[0]
+	leaveTime := time.Now().Add(-2 * time.Hour).Truncate(time.Minute)
+
+	leave, err := db.RecordLeave(sub.ID, leaveTime, true)
+	if err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	if err := db.SetExpectedReturn(database.KindLeave, leave.ID, leaveTime.Add(time.Hour), 42); err != nil {
+		t.Fatalf("SetExpectedReturn: %v", err)
+	}
+
+	expected := leaveTime.Add(time.Hour)
+	pending, err := db.GetPendingReturns(expected)
+	if err != nil {
+		t.Fatalf("GetPendingReturns: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != leave.ID || pending[0].NotifyChatID != 42 {
+		t.Fatalf("unexpected pending returns: %+v", pending)
+	}
+	if !pending[0].EventTime.Equal(leaveTime) {
+		t.Fatalf("expected event time %v, got %v", leaveTime, pending[0].EventTime)
+	}
+
+	if err := db.SetStaysOut(database.KindLeave, leave.ID); err != nil {
+		t.Fatalf("SetStaysOut: %v", err)
+	}
+	if pending, err = db.GetPendingReturns(expected); err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending returns after stays out, got %+v (%v)", pending, err)
+	}
+}
+
+func TestPendingReturnsSkipPastDaysAndArchived(t *testing.T) {
+	db := dbtest.New(t)
+	subs := dbtest.AddSubordinates(t, db, "Петров Петр Петрович", "Сидоров Сидор Сидорович")
+	today := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+
+	expectReturn := func(subordinateID int, leaveTime time.Time) {
+		t.Helper()
+		leave, err := db.RecordLeave(subordinateID, leaveTime, true)
+		if err != nil {
+			t.Fatalf("RecordLeave: %v", err)
+		}
+		if err := db.SetExpectedReturn(database.KindLeave, leave.ID, leaveTime.Add(time.Hour), 0); err != nil {
+			t.Fatalf("SetExpectedReturn: %v", err)
+		}
+	}
+
+	// Вчерашний уход без отметки о возвращении и сегодняшний уход подчиненного, которого перевели в архив
+	expectReturn(subs[0].ID, today.AddDate(0, 0, -1))
+	expectReturn(subs[1].ID, today)
+	if _, err := db.ArchiveSubordinate(subs[1].ID); err != nil {
+		t.Fatalf("ArchiveSubordinate: %v", err)
+	}
+
+	pending, err := db.GetPendingReturns(today)
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("expected no pending returns, got %+v (%v)", pending, err)
+	}
+
+	// Вчерашний уход всё ещё в списке вчера
+	if pending, err = db.GetPendingReturns(today.AddDate(0, 0, -1)); err != nil || len(pending) != 1 || pending[0].Subordinate.ID != subs[0].ID {
+		t.Fatalf("expected yesterday's leave on its day, got %+v (%v)", pending, err)
+	}
+}
+
+func TestMergeSubordinatesMovesRecordsAndGuardians(t *testing.T) {
+	db := dbtest.New(t)
+	subs := dbtest.AddSubordinates(t, db, "Сидоров Олег Петрович", "Сидоров Олег")
+	keep, duplicate := subs[0], subs[1]
+
+	for _, guardian := range []database.Guardian{
+		{SubordinateID: keep.ID, FullName: "Сидорова Анна", Phone: "+79000000001"},
+		{SubordinateID: duplicate.ID, FullName: "Сидорова Анна Ивановна", Phone: "+79000000001"},
+		{SubordinateID: duplicate.ID, FullName: "Сидоров Петр", Phone: "+79000000002"},
+	} {
+		if err := db.AddGuardian(guardian); err != nil {
+			t.Fatalf("AddGuardian: %v", err)
+		}
+	}
+	if _, err := db.RecordLeave(duplicate.ID, time.Now(), true); err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	// Такая же деятельность есть у основной записи - у дубликата она удаляется
+	activityTime := time.Now().Add(-time.Hour)
+	if _, err := db.RecordActivity(keep.ID, activityTime, "Олимпиада", true); err != nil {
+		t.Fatalf("RecordActivity: %v", err)
+	}
+	duplicateActivity, err := db.RecordActivity(duplicate.ID, activityTime, "Олимпиада", true)
+	if err != nil {
+		t.Fatalf("RecordActivity: %v", err)
+	}
+
+	received, unsubscribe := db.Events().Subscribe()
+	defer unsubscribe()
+
+	if err := db.MergeSubordinates(keep.ID, duplicate.ID); err != nil {
+		t.Fatalf("MergeSubordinates: %v", err)
+	}
+
+	deleted := <-received
+	if deleted.Type != events.RecordDeleted || deleted.Kind != database.KindActivity || deleted.RecordID != duplicateActivity.ID {
+		t.Fatalf("expected deletion of activity %d to be published, got %+v", duplicateActivity.ID, deleted)
+	}
+	if merged := <-received; merged.Type != events.SubordinatesMerged {
+		t.Fatalf("expected merge event after deletion, got %+v", merged)
+	}
+
+	if _, err := db.GetLeaveForDate(keep.ID, time.Now()); err != nil {
+		t.Fatalf("leave was not moved to the kept subordinate: %v", err)
+	}
+	if count, err := db.CountGuardians(); err != nil || count != 2 {
+		t.Fatalf("expected 2 guardians after merge, got %d (%v)", count, err)
+	}
+	if _, err := db.GetSubordinateByID(duplicate.ID); err == nil {
+		t.Fatal("duplicate subordinate should be deleted")
+	}
+}
+
+func TestGuardianNotificationsAndSubscriptions(t *testing.T) {
+	db := dbtest.New(t)
+	sub := dbtest.AddSubordinates(t, db, "Козлов Илья Андреевич")[0]
+
+	if err := db.AddGuardian(database.Guardian{SubordinateID: sub.ID, FullName: "Козлова Мария", Phone: "+79000000003"}); err != nil {
+		t.Fatalf("AddGuardian: %v", err)
+	}
+	if _, err := db.LinkGuardiansByPhone("+79000000003", 555); err != nil {
+		t.Fatalf("LinkGuardiansByPhone: %v", err)
+	}
+	if guardians, err := db.GetNotifiableGuardians(sub.ID); err != nil || len(guardians) != 1 {
+		t.Fatalf("expected 1 notifiable guardian, got %d (%v)", len(guardians), err)
+	}
+	if err := db.SetGuardianNotifications(555, false); err != nil {
+		t.Fatalf("SetGuardianNotifications: %v", err)
+	}
+	if guardians, err := db.GetNotifiableGuardians(sub.ID); err != nil || len(guardians) != 0 {
+		t.Fatalf("expected no notifiable guardians, got %d (%v)", len(guardians), err)
+	}
+
+	if err := db.Subscribe(100, true, false); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	subscriptions, err := db.GetSubscriptions()
+	if err != nil {
+		t.Fatalf("GetSubscriptions: %v", err)
+	}
+	if len(subscriptions) != 1 || !subscriptions[0].Status || subscriptions[0].Statistics {
+		t.Fatalf("unexpected subscriptions: %+v", subscriptions)
+	}
+}
+
+func TestWebhookDeliveriesAreQueuedForEvents(t *testing.T) {
+	db := dbtest.New(t)
+
+	webhook, err := db.AddWebhook("https://example.com/hook", 1)
+	if err != nil {
+		t.Fatalf("AddWebhook: %v", err)
+	}
+	if webhook.ID == 0 || webhook.Secret == "" {
+		t.Fatalf("webhook was not created: %+v", webhook)
+	}
+
+	dbtest.AddSubordinates(t, db, "Морозов Антон Сергеевич")
+
+	deliveries, err := db.GetDueWebhookDeliveries(time.Now().Add(time.Second), 10)
+	if err != nil {
+		t.Fatalf("GetDueWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 || deliveries[0].EventType != events.SubordinateAdded {
+		t.Fatalf("expected one subordinate.added delivery, got %+v", deliveries)
+	}
+
+	if err := db.MarkWebhookDelivered(deliveries[0].ID, time.Now()); err != nil {
+		t.Fatalf("MarkWebhookDelivered: %v", err)
+	}
+	webhooks, err := db.GetWebhooks()
+	if err != nil {
+		t.Fatalf("GetWebhooks: %v", err)
+	}
+	if len(webhooks) != 1 || webhooks[0].Pending != 0 {
+		t.Fatalf("expected no pending deliveries, got %+v", webhooks)
+	}
+}
+
+func TestWebhookDeliveriesAreQueuedInTheWriteTransaction(t *testing.T) {
+	db := dbtest.New(t)
+	subs := dbtest.AddSubordinates(t, db, "Морозов Антон Сергеевич")
+	if _, err := db.AddWebhook("https://example.com/hook", 1); err != nil {
+		t.Fatalf("AddWebhook: %v", err)
+	}
+
+	received, unsubscribe := db.Events().Subscribe()
+	defer unsubscribe()
+
+	if _, err := db.RecordLeave(subs[0].ID, time.Now(), false); err != nil {
+		t.Fatalf("RecordLeave: %v", err)
+	}
+	published := <-received
+
+	// Вебхук получает то же событие, что и подписчики на шине, с тем же номером
+	deliveries, err := db.GetDueWebhookDeliveries(time.Now().Add(time.Second), 10)
+	if err != nil {
+		t.Fatalf("GetDueWebhookDeliveries: %v", err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("expected one delivery, got %+v", deliveries)
+	}
+	var queued events.Event
+	if err := json.Unmarshal([]byte(deliveries[0].Payload), &queued); err != nil {
+		t.Fatalf("invalid payload %q: %v", deliveries[0].Payload, err)
+	}
+	if queued.ID != published.ID || queued.Type != events.LeaveRecorded {
+		t.Fatalf("expected queued event #%d %s, got #%d %s", published.ID, events.LeaveRecorded, queued.ID, queued.Type)
+	}
+
+	// Если событие не удалось поставить в очередь, запись тоже не сохраняется
+	if _, err := db.Exec("DROP TABLE webhook_deliveries"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RecordActivity(subs[0].ID, time.Now(), "Олимпиада", false); err == nil {
+		t.Fatal("expected RecordActivity to fail without the webhook queue")
+	}
+	if activity, err := db.GetActivityForDate(subs[0].ID, time.Now()); err == nil {
+		t.Fatalf("activity must be rolled back with its event, got %+v", activity)
+	}
+	select {
+	case event := <-received:
+		t.Fatalf("rolled back change must not be published, got %+v", event)
+	default:
+	}
+}
+
+func TestDeleteWebSessionEndsSession(t *testing.T) {
+	db := dbtest.New(t)
+
+	token, err := db.CreateWebSession(1001, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateWebSession: %v", err)
+	}
+	if valid, err := db.ValidateWebSession(token); err != nil || !valid {
+		t.Fatalf("expected new session to be valid, got %v (%v)", valid, err)
+	}
+
+	if err := db.DeleteWebSession(token); err != nil {
+		t.Fatalf("DeleteWebSession: %v", err)
+	}
+	if valid, err := db.ValidateWebSession(token); err != nil || valid {
+		t.Fatalf("expected session to end after logout, got %v (%v)", valid, err)
+	}
+}
+
+func TestAPITokenKeepsScope(t *testing.T) {
+	db := dbtest.New(t)
+
+	full, err := db.CreateAPIToken("журнал", database.TokenScopeFull, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	calendar, err := db.CreateAPIToken("календарь", database.TokenScopeCalendar, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for token, want := range map[string]string{full: database.TokenScopeFull, calendar: database.TokenScopeCalendar} {
+		apiToken, err := db.ValidateAPIToken(token)
+		if err != nil || apiToken == nil {
+			t.Fatalf("ValidateAPIToken: %v, %v", apiToken, err)
+		}
+		if apiToken.Scope != want {
+			t.Errorf("token %q: expected scope %q, got %q", apiToken.Name, want, apiToken.Scope)
+		}
+	}
+}
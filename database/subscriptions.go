@@ -0,0 +1,47 @@
+package database
+
+// Subscribe оформляет или обновляет подписку чата на рассылку
+func (db *DB) Subscribe(chatID int64, status, statistics bool) error {
+	_, err := db.Exec(`
+		INSERT INTO subscriptions (chat_id, report_status, report_statistics)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET
+			report_status = excluded.report_status,
+			report_statistics = excluded.report_statistics
+	`, chatID, status, statistics)
+	return err
+}
+
+// Unsubscribe удаляет подписку чата. Возвращает false, если подписки не было
+func (db *DB) Unsubscribe(chatID int64) (bool, error) {
+	result, err := db.Exec("DELETE FROM subscriptions WHERE chat_id = ?", chatID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *DB) GetSubscriptions() ([]Subscription, error) {
+	rows, err := db.Query(`
+		SELECT chat_id, report_status, report_statistics, created_at
+		FROM subscriptions
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscriptions []Subscription
+	for rows.Next() {
+		var sub Subscription
+		if err := rows.Scan(&sub.ChatID, &sub.Status, &sub.Statistics, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, sub)
+	}
+
+	return subscriptions, nil
+}
@@ -0,0 +1,51 @@
+package database
+
+// RegisterSupervisorChat разрешает работу бота в групповом чате
+func (db *DB) RegisterSupervisorChat(chatID int64, title string, registeredBy int64) error {
+	_, err := db.Exec(`
+		INSERT INTO supervisor_chats (chat_id, title, registered_by)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET title = excluded.title
+	`, chatID, title, registeredBy)
+	return err
+}
+
+// UnregisterSupervisorChat запрещает работу бота в групповом чате. Возвращает false, если чат не был зарегистрирован
+func (db *DB) UnregisterSupervisorChat(chatID int64) (bool, error) {
+	result, err := db.Exec("DELETE FROM supervisor_chats WHERE chat_id = ?", chatID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (db *DB) IsSupervisorChat(chatID int64) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM supervisor_chats WHERE chat_id = ?", chatID).Scan(&count)
+	return count > 0, err
+}
+
+func (db *DB) GetSupervisorChats() ([]SupervisorChat, error) {
+	rows, err := db.Query(`
+		SELECT chat_id, COALESCE(title, ''), registered_by, created_at
+		FROM supervisor_chats
+		ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chats []SupervisorChat
+	for rows.Next() {
+		var chat SupervisorChat
+		if err := rows.Scan(&chat.ChatID, &chat.Title, &chat.RegisteredBy, &chat.CreatedAt); err != nil {
+			return nil, err
+		}
+		chats = append(chats, chat)
+	}
+
+	return chats, nil
+}
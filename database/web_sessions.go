@@ -0,0 +1,99 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CreateLoginLink создаёт одноразовый токен для входа в веб-панель, действующий ttl
+func (db *DB) CreateLoginLink(userID int64, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO web_login_links (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		hashToken(token), userID, time.Now().Add(ttl),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UseLoginLink гасит одноразовый токен и возвращает пользователя, которому он выдан.
+// Если токен неизвестен, просрочен или уже использован, возвращает false
+func (db *DB) UseLoginLink(token string) (int64, bool, error) {
+	now := time.Now()
+	hash := hashToken(token)
+
+	// Отметка об использовании ставится одним запросом, поэтому ссылку нельзя открыть дважды
+	result, err := db.Exec(
+		"UPDATE web_login_links SET used_at = ? WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?",
+		now, hash, now,
+	)
+	if err != nil {
+		return 0, false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return 0, false, err
+	}
+
+	var userID int64
+	err = db.QueryRow("SELECT user_id FROM web_login_links WHERE token_hash = ?", hash).Scan(&userID)
+	if err != nil {
+		return 0, false, err
+	}
+	return userID, true, nil
+}
+
+// CreateWebSession создаёт сессию браузера для пользователя и возвращает её токен
+func (db *DB) CreateWebSession(userID int64, ttl time.Duration) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO web_sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)",
+		hashToken(token), userID, time.Now().Add(ttl),
+	)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateWebSession проверяет токен сессии браузера. Возвращает false, если сессия неизвестна или истекла
+func (db *DB) ValidateWebSession(token string) (bool, error) {
+	var userID int64
+	err := db.QueryRow(
+		"SELECT user_id FROM web_sessions WHERE token_hash = ? AND expires_at > ?",
+		hashToken(token), time.Now(),
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteWebSession завершает сессию браузера: после выхода токен из cookie больше не действует
+func (db *DB) DeleteWebSession(token string) error {
+	_, err := db.Exec("DELETE FROM web_sessions WHERE token_hash = ?", hashToken(token))
+	return err
+}
+
+// DeleteExpiredWebLogins удаляет просроченные ссылки и сессии
+func (db *DB) DeleteExpiredWebLogins() error {
+	now := time.Now()
+	if _, err := db.Exec("DELETE FROM web_login_links WHERE expires_at <= ?", now); err != nil {
+		return err
+	}
+	_, err := db.Exec("DELETE FROM web_sessions WHERE expires_at <= ?", now)
+	return err
+}
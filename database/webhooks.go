@@ -0,0 +1,173 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"whereismychildren/events"
+)
+
+// AddWebhook регистрирует адрес для получения событий и создаёт для него секрет подписи
+func (db *DB) AddWebhook(url string, createdBy int64) (Webhook, error) {
+	secret, err := newToken()
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	id, err := db.insertID(
+		"INSERT INTO webhooks (url, secret, created_by) VALUES (?, ?, ?)",
+		url, secret, createdBy,
+	)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	return Webhook{ID: id, URL: url, Secret: secret, CreatedBy: createdBy, CreatedAt: time.Now()}, nil
+}
+
+// GetWebhooks возвращает действующие вебхуки с количеством ожидающих и неудавшихся доставок
+func (db *DB) GetWebhooks() ([]Webhook, error) {
+	rows, err := db.Query(`
+		SELECT w.id, w.url, w.secret, w.created_by, w.created_at,
+		       COUNT(CASE WHEN d.delivered_at IS NULL AND d.failed_at IS NULL THEN d.id END),
+		       COUNT(d.failed_at)
+		FROM webhooks w
+		LEFT JOIN webhook_deliveries d ON d.webhook_id = w.id
+		WHERE w.removed_at IS NULL
+		GROUP BY w.id
+		ORDER BY w.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []Webhook
+	for rows.Next() {
+		var webhook Webhook
+		if err := rows.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &webhook.CreatedBy, &webhook.CreatedAt,
+			&webhook.Pending, &webhook.Failed); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// RemoveWebhook отключает вебхук; недоставленные события для него больше не отправляются.
+// Возвращает false, если действующего вебхука с таким id нет
+func (db *DB) RemoveWebhook(id int) (bool, error) {
+	result, err := db.Exec(
+		"UPDATE webhooks SET removed_at = ? WHERE id = ? AND removed_at IS NULL",
+		time.Now(), id,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// enqueueWebhookDeliveries ставит событие в очередь отправки на каждый действующий вебхук.
+// Вызывается в транзакции изменения, о котором событие (см. Tx.publish)
+func enqueueWebhookDeliveries(tx *Tx, event events.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	// Параметры в списке SELECT не получают тип колонки в PostgreSQL, поэтому вставляем по одной строке
+	rows, err := tx.Query("SELECT id FROM webhooks WHERE removed_at IS NULL")
+	if err != nil {
+		return err
+	}
+	var webhookIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		webhookIDs = append(webhookIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, id := range webhookIDs {
+		_, err := tx.Exec(
+			"INSERT INTO webhook_deliveries (webhook_id, event_type, payload, next_attempt_at) VALUES (?, ?, ?, ?)",
+			id, event.Type, string(payload), now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDueWebhookDeliveries возвращает доставки, время попытки которых наступило, в порядке появления событий.
+// Доставки, стоящие в очереди вебхука за ожидающим повтора событием, ждут его: получатель видит события по порядку
+func (db *DB) GetDueWebhookDeliveries(now time.Time, limit int) ([]WebhookDelivery, error) {
+	rows, err := db.Query(`
+		SELECT d.id, d.webhook_id, w.url, w.secret, d.event_type, d.payload, d.attempts
+		FROM webhook_deliveries d
+		JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.delivered_at IS NULL AND d.failed_at IS NULL AND w.removed_at IS NULL
+		  AND d.next_attempt_at <= ?
+		  AND NOT EXISTS (
+		      SELECT 1 FROM webhook_deliveries p
+		      WHERE p.webhook_id = d.webhook_id AND p.id < d.id
+		        AND p.delivered_at IS NULL AND p.failed_at IS NULL AND p.next_attempt_at > ?
+		  )
+		ORDER BY d.id
+		LIMIT ?
+	`, now, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []WebhookDelivery
+	for rows.Next() {
+		var delivery WebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.URL, &delivery.Secret,
+			&delivery.EventType, &delivery.Payload, &delivery.Attempts); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// MarkWebhookDelivered отмечает успешную доставку
+func (db *DB) MarkWebhookDelivered(id int, deliveredAt time.Time) error {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, delivered_at = ?, last_error = NULL WHERE id = ?",
+		deliveredAt, id,
+	)
+	return err
+}
+
+// RetryWebhookDelivery запоминает неудачную попытку и время следующей
+func (db *DB) RetryWebhookDelivery(id int, deliveryErr string, nextAttempt time.Time) error {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?",
+		deliveryErr, nextAttempt, id,
+	)
+	return err
+}
+
+// FailWebhookDelivery прекращает попытки доставки после последней неудачи
+func (db *DB) FailWebhookDelivery(id int, deliveryErr string, failedAt time.Time) error {
+	_, err := db.Exec(
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, last_error = ?, failed_at = ? WHERE id = ?",
+		deliveryErr, failedAt, id,
+	)
+	return err
+}
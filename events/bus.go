@@ -0,0 +1,118 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Типы событий об изменениях посещаемости и списка подчиненных
+const (
+	LeaveRecorded       = "leave.recorded"
+	ActivityRecorded    = "activity.recorded"
+	ExpectedReturnSet   = "return.expected"
+	Returned            = "return.recorded"
+	StaysOut            = "return.stays_out"
+	SubordinateAdded    = "subordinate.added"
+	SubordinateUpdated  = "subordinate.updated"
+	SubordinateArchived = "subordinate.archived"
+	SubordinateRestored = "subordinate.restored"
+	SubordinatesMerged  = "subordinate.merged"
+	// Уход или деятельность удалены (например, одинаковая запись дубликата при объединении)
+	RecordDeleted = "record.deleted"
+)
+
+// Types - все типы событий, например для подписки в браузере через addEventListener
+var Types = []string{
+	LeaveRecorded, ActivityRecorded, ExpectedReturnSet, Returned, StaysOut,
+	SubordinateAdded, SubordinateUpdated, SubordinateArchived, SubordinateRestored, SubordinatesMerged,
+	RecordDeleted,
+}
+
+// subscriberBuffer - сколько событий может накопиться у медленного подписчика
+const subscriberBuffer = 64
+
+// Event - изменение, зафиксированное в базе. Data содержит запись после изменения.
+// ID выдаёт база (см. database.Tx.publish): номера растут и не повторяются после перезапуска,
+// поэтому по ним получатель вебхуков и потока событий может отбрасывать уже полученные
+type Event struct {
+	ID            uint64      `json:"id"`
+	Type          string      `json:"type"`
+	Time          time.Time   `json:"time"`
+	SubordinateID int         `json:"subordinate_id,omitempty"`
+	Kind          string      `json:"kind,omitempty"`
+	RecordID      int         `json:"record_id,omitempty"`
+	Data          interface{} `json:"data,omitempty"`
+}
+
+// Bus рассылает события всем подписчикам внутри процесса
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	subscribers map[chan Event]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[chan Event]struct{})}
+}
+
+// Stamp присваивает событию время и, если номера ещё нет, номер, не отправляя его. Так событие
+// можно сохранить до публикации (например, в очередь вебхуков), и подписчики получат его с тем же номером.
+// Номера, которые выдаёт сама шина, уникальны только внутри процесса; события базы приходят с номером из неё
+func (b *Bus) Stamp(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stamp(event)
+}
+
+// Publish отправляет событие подписчикам, не дожидаясь их. Если подписчик не успевает
+// забирать события, новые для него отбрасываются, чтобы запись в базу не блокировалась.
+// Событию без номера присваиваются номер и время; возвращается отправленное событие
+func (b *Bus) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if event.ID == 0 {
+		event = b.stamp(event)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Event subscriber is too slow, dropping %s #%d", event.Type, event.ID)
+		}
+	}
+	return event
+}
+
+func (b *Bus) stamp(event Event) Event {
+	if event.ID == 0 {
+		b.nextID++
+		event.ID = b.nextID
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	return event
+}
+
+// Subscribe возвращает канал событий и функцию отписки, после которой канал закрывается
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
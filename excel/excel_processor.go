@@ -9,16 +9,17 @@ import (
 	"time"
 
 	"whereismychildren/database"
+	"whereismychildren/utils"
 
 	"github.com/tealeg/xlsx"
 	"github.com/xuri/excelize/v2"
 )
 
 type ExcelProcessor struct {
-	db *database.DB
+	db database.Store
 }
 
-func NewExcelProcessor(db *database.DB) *ExcelProcessor {
+func NewExcelProcessor(db database.Store) *ExcelProcessor {
 	return &ExcelProcessor{db: db}
 }
 
@@ -42,16 +43,17 @@ func (ep *ExcelProcessor) ProcessExcelFile(filePath string) ([]database.Subordin
 	}
 
 	var newSubordinates []database.Subordinate
-	existingSubs, err := ep.db.GetAllSubordinates()
+	// Архивные тоже учитываем: повторная загрузка не должна создавать их заново
+	existingSubs, err := ep.db.GetSubordinatesIncludingArchived()
 	if err != nil {
 		return nil, err
 	}
 
 	// Создаем мапу для быстрой проверки существующих подчиненных
-	existingMap := make(map[string]bool)
+	existingMap := make(map[string]int)
 	for _, sub := range existingSubs {
 		key := fmt.Sprintf("%s|%s|%s", sub.LastName, sub.FirstName, sub.MiddleName)
-		existingMap[key] = true
+		existingMap[key] = sub.ID
 	}
 
 	for rowIndex, row := range rows {
@@ -77,8 +79,20 @@ func (ep *ExcelProcessor) ProcessExcelFile(filePath string) ([]database.Subordin
 
 		// Проверяем, существует ли уже такой подчиненный
 		key := fmt.Sprintf("%s|%s|%s", lastName, firstName, middleName)
-		if existingMap[key] {
+		group := ""
+		if len(row) > groupColumn {
+			group = strings.TrimSpace(row[groupColumn])
+		}
+
+		if id, exists := existingMap[key]; exists {
 			log.Printf("Subordinate already exists: %s %s %s", lastName, firstName, middleName)
+			// Группу и представителей обновляем и для уже добавленных подчиненных
+			if group != "" {
+				if err := ep.db.SetSubordinateGroup(id, group); err != nil {
+					log.Printf("Failed to set group for subordinate %s: %v", key, err)
+				}
+			}
+			ep.importGuardians(id, row)
 			continue
 		}
 
@@ -86,26 +100,58 @@ func (ep *ExcelProcessor) ProcessExcelFile(filePath string) ([]database.Subordin
 			LastName:   lastName,
 			FirstName:  firstName,
 			MiddleName: middleName,
+			Group:      group,
 		}
 
-		if _, err := ep.db.AddSubordinate(sub); err != nil {
+		id, err := ep.db.AddSubordinate(sub)
+		if err != nil {
 			log.Printf("Failed to add subordinate %s: %v", key, err)
 			continue
 		}
+		sub.ID = id
 
 		newSubordinates = append(newSubordinates, sub)
-		existingMap[key] = true // Добавляем в мапу, чтобы избежать дубликатов в этом файле
+		existingMap[key] = id // Добавляем в мапу, чтобы избежать дубликатов в этом файле
+		ep.importGuardians(id, row)
 	}
 
 	return newSubordinates, nil
 }
-func (ep *ExcelProcessor) ExportToExcel(data []struct {
-	Subordinate  database.Subordinate
-	LeaveTime    *time.Time
-	ActivityTime *time.Time
-	ActivityDesc *string
-	Date         time.Time
-}) (string, error) {
+
+// Колонка J - группа (класс, отделение) подчиненного
+const groupColumn = 9
+
+// Колонки представителей в списке: ФИО, кем приходится, телефон.
+// Первый представитель в колонках D-F, второй - в G-I
+var guardianColumns = [][3]int{{3, 4, 5}, {6, 7, 8}}
+
+// importGuardians добавляет представителей подчиненного из строки списка
+func (ep *ExcelProcessor) importGuardians(subordinateID int, row []string) {
+	cell := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	for _, columns := range guardianColumns {
+		fullName, relation, phone := cell(columns[0]), cell(columns[1]), utils.NormalizePhone(cell(columns[2]))
+		if fullName == "" || phone == "" {
+			continue
+		}
+
+		guardian := database.Guardian{
+			SubordinateID: subordinateID,
+			FullName:      fullName,
+			Relation:      relation,
+			Phone:         phone,
+		}
+		if err := ep.db.AddGuardian(guardian); err != nil {
+			log.Printf("Failed to add guardian %s for subordinate %d: %v", fullName, subordinateID, err)
+		}
+	}
+}
+func (ep *ExcelProcessor) ExportToExcel(data []database.ExportRecord) (string, error) {
 	file := xlsx.NewFile()
 	sheet, err := file.AddSheet("Статистика")
 	if err != nil {
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"whereismychildren/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleAPITokenCommand управляет токенами HTTP API: /api_token new <название> | calendar <название> | list | revoke <id>
+func (h *BotHandler) handleAPITokenCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	usage := "Использование: /api_token new <название> | /api_token calendar <название> | /api_token list | /api_token revoke <id>"
+	args := strings.Fields(strings.TrimPrefix(text, "/api_token"))
+	if len(args) == 0 {
+		h.sendError(chatID, usage)
+		return
+	}
+
+	switch args[0] {
+	case "new", "calendar":
+		// Токен открывает данные подчиненных, поэтому не показываем его в группах
+		if chatID != key.UserID {
+			h.sendError(chatID, "Создавайте токены в личном чате с ботом")
+			return
+		}
+
+		name := strings.Join(args[1:], " ")
+		if name == "" {
+			h.sendError(chatID, fmt.Sprintf("Укажите название токена, например: /api_token %s журнал", args[0]))
+			return
+		}
+
+		scope, usageNote := database.TokenScopeFull, "Передавайте в заголовке: Authorization: Bearer <токен>"
+		if args[0] == "calendar" {
+			scope, usageNote = database.TokenScopeCalendar,
+				"Токен открывает только календарь, его можно указать в адресе подписки: /api/calendar.ics?token=<токен>"
+		}
+
+		token, err := h.db.CreateAPIToken(name, scope, key.UserID)
+		if err != nil {
+			h.sendError(chatID, "Ошибка создания токена: "+err.Error())
+			return
+		}
+
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"🔑 Токен «%s» создан. Сохраните его - повторно он показан не будет:\n\n`%s`\n\n%s",
+			tgbotapi.EscapeText(tgbotapi.ModeMarkdown, name), token, tgbotapi.EscapeText(tgbotapi.ModeMarkdown, usageNote)))
+		msg.ParseMode = "Markdown"
+		h.bot.Send(msg)
+
+	case "list":
+		tokens, err := h.db.GetAPITokens()
+		if err != nil {
+			h.sendError(chatID, "Ошибка получения токенов: "+err.Error())
+			return
+		}
+		if len(tokens) == 0 {
+			h.sendError(chatID, "Действующих токенов нет")
+			return
+		}
+
+		message := "🔑 Токены API:\n\n"
+		for _, token := range tokens {
+			lastUsed := "не использовался"
+			if token.LastUsedAt != nil {
+				lastUsed = "использован " + token.LastUsedAt.Format("02.01.2006 15:04")
+			}
+			scope := ""
+			if token.Scope == database.TokenScopeCalendar {
+				scope = " (только календарь)"
+			}
+			message += fmt.Sprintf("%d. %s%s - создан %s, %s\n",
+				token.ID, token.Name, scope, token.CreatedAt.Format("02.01.2006"), lastUsed)
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, message))
+
+	case "revoke":
+		if len(args) < 2 {
+			h.sendError(chatID, "Укажите номер токена: /api_token revoke <id>")
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			h.sendError(chatID, "Неверный номер токена")
+			return
+		}
+
+		revoked, err := h.db.RevokeAPIToken(id)
+		if err != nil {
+			h.sendError(chatID, "Ошибка отзыва токена: "+err.Error())
+			return
+		}
+		if !revoked {
+			h.sendError(chatID, "Действующего токена с таким номером нет")
+			return
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Токен %d отозван", id)))
+
+	default:
+		h.sendError(chatID, usage)
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"whereismychildren/backup"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Больше этого Bot API не принимает файлы от ботов
+const maxDocumentSize = 50 << 20
+
+// RunBackup создаёт плановую резервную копию базы. Вызывается планировщиком
+func (h *BotHandler) RunBackup() {
+	if h.backups != nil {
+		h.backups.Run()
+	}
+}
+
+// handleBackupCommand отправляет администратору последнюю резервную копию базы: /backup [сейчас]
+func (h *BotHandler) handleBackupCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	// В копии все данные подчиненных и представителей, поэтому не отправляем её в группы
+	if chatID != key.UserID {
+		h.sendError(chatID, "Запрашивайте резервную копию в личном чате с ботом")
+		return
+	}
+	if h.backups == nil {
+		h.sendError(chatID, "Резервное копирование отключено: укажите каталог BACKUP_DIR")
+		return
+	}
+
+	arg := strings.TrimSpace(strings.TrimPrefix(text, "/backup"))
+	if arg != "" && arg != "сейчас" && arg != "now" {
+		h.sendError(chatID, "Использование: /backup - последняя копия, /backup сейчас - сделать новую копию")
+		return
+	}
+
+	file, err := h.backups.Latest()
+	if arg != "" || errors.Is(err, backup.ErrNoBackups) {
+		file, err = h.backups.Create()
+	}
+	if err != nil {
+		h.sendError(chatID, "Ошибка резервного копирования: "+err.Error())
+		return
+	}
+
+	if file.Size > maxDocumentSize {
+		h.sendError(chatID, fmt.Sprintf("Копия %s слишком большая для Telegram (%.1f МБ), заберите её с сервера",
+			file.Path, float64(file.Size)/(1<<20)))
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(file.Path))
+	doc.Caption = fmt.Sprintf("💾 Резервная копия базы от %s (%.1f МБ). Восстановление: остановите бота и выполните restore <файл>",
+		file.CreatedAt.Format("02.01.2006 15:04"), float64(file.Size)/(1<<20))
+
+	if _, err := h.bot.Send(doc); err != nil {
+		h.sendError(chatID, "Ошибка отправки файла: "+err.Error())
+	}
+}
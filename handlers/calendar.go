@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"whereismychildren/calendar"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleCalendarCommand выгружает уходы и деятельность в формате iCalendar:
+// /calendar [неделя|месяц|ДД.ММ.ГГГГ-ДД.ММ.ГГГГ] [группа <название> | Фамилия [Имя]]
+func (h *BotHandler) handleCalendarCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+	words := strings.Fields(strings.TrimPrefix(text, "/calendar"))
+
+	// Период необязателен: по умолчанию выгружаем последний месяц
+	from, to, _ := utils.ParsePeriod("месяц")
+	if len(words) > 0 {
+		if periodFrom, periodTo, err := utils.ParsePeriod(words[0]); err == nil {
+			from, to = periodFrom, periodTo
+			words = words[1:]
+		}
+	}
+
+	switch {
+	case len(words) == 0:
+		h.sendCalendar(chatID, from, to, 0, "", "все подчиненные")
+
+	case strings.ToLower(words[0]) == "группа":
+		group := strings.Join(words[1:], " ")
+		if group == "" {
+			h.sendError(chatID, "Укажите группу: /calendar месяц группа 5А")
+			return
+		}
+		h.sendCalendar(chatID, from, to, 0, group, "группа "+group)
+
+	default:
+		subordinates, exact, err := h.findExactSubordinate(strings.Join(words, " "), "")
+		if err != nil {
+			h.sendError(chatID, "Ошибка поиска подчиненных: "+err.Error())
+			return
+		}
+
+		switch len(subordinates) {
+		case 0:
+			h.sendError(chatID, "Сотрудник не найден. Использование: /calendar [неделя|месяц|ДД.ММ.ГГГГ-ДД.ММ.ГГГГ] [группа <название> | Фамилия [Имя]]")
+		case 1:
+			if !exact {
+				h.confirmMatch(key, subordinates[0], map[string]interface{}{"action": "calendar", "from": from, "to": to})
+				return
+			}
+			h.sendSubordinateCalendar(chatID, subordinates[0].ID, from, to)
+		default:
+			h.userStates[key] = "waiting_calendar_selection"
+			h.userData[key] = map[string]interface{}{
+				"sub_list": subordinates,
+				"from":     from,
+				"to":       to,
+			}
+			h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
+		}
+	}
+}
+
+// sendSubordinateCalendar выгружает календарь одного подчиненного
+func (h *BotHandler) sendSubordinateCalendar(chatID int64, subordinateID int, from, to time.Time) {
+	sub, err := h.db.GetSubordinateByID(subordinateID)
+	if err != nil {
+		h.sendError(chatID, "Сотрудник не найден")
+		return
+	}
+	h.sendCalendar(chatID, from, to, sub.ID, "", sub.LastName+" "+sub.FirstName)
+}
+
+// sendCalendar отправляет файл .ics за период для подчиненного, группы или всех
+func (h *BotHandler) sendCalendar(chatID int64, from, to time.Time, subordinateID int, group, title string) {
+	absences, err := h.db.GetAbsencesBetween(from, to, subordinateID, group)
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения данных: "+err.Error())
+		return
+	}
+
+	period := fmt.Sprintf("%s - %s", from.Format("02.01.2006"), to.Format("02.01.2006"))
+	if len(absences) == 0 {
+		h.sendError(chatID, fmt.Sprintf("За %s уходов и внеплановой деятельности нет (%s)", period, title))
+		return
+	}
+
+	data := calendar.Generate(calendar.Calendar{
+		Name:        "Где подчинённые: " + title,
+		Absences:    absences,
+		GeneratedAt: time.Now(),
+	})
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("calendar_%s_%s.ics", from.Format("2006-01-02"), to.Format("2006-01-02")),
+		Bytes: data,
+	})
+	doc.Caption = fmt.Sprintf("📅 Календарь за %s (%s): событий - %d. Откройте файл, чтобы добавить события в свой календарь",
+		period, title, len(absences))
+
+	if _, err := h.bot.Send(doc); err != nil {
+		h.sendError(chatID, "Ошибка отправки файла: "+err.Error())
+	}
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"whereismychildren/charts"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleStatisticsCharts отправляет графики уходов и внеплановой деятельности за период
+func (h *BotHandler) handleStatisticsCharts(chatID int64, period string) {
+	from, to, err := utils.ParsePeriod(period)
+	if err != nil {
+		h.sendError(chatID, "Неверный период. Используйте: неделя, месяц, ДД.ММ.ГГГГ или ДД.ММ.ГГГГ-ДД.ММ.ГГГГ")
+		return
+	}
+
+	if chartDays(from, to) > 366 {
+		h.sendError(chatID, "Период для графиков не должен превышать год")
+		return
+	}
+
+	leaves, err := h.db.GetLeavesBetween(from, to)
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения статистики: "+err.Error())
+		return
+	}
+
+	activities, err := h.db.GetUnplannedActivitiesBetween(from, to)
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения статистики: "+err.Error())
+		return
+	}
+
+	periodTitle := fmt.Sprintf("%s - %s", from.Format("02.01.2006"), to.Format("02.01.2006"))
+	if len(leaves) == 0 && len(activities) == 0 {
+		h.sendError(chatID, "Нет данных за период "+periodTitle)
+		return
+	}
+
+	// Количество событий по дням
+	var dayLabels []string
+	dayIndex := make(map[string]int)
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		dayIndex[day.Format("2006-01-02")] = len(dayLabels)
+		dayLabels = append(dayLabels, day.Format("02.01"))
+	}
+	leavesByDay := make([]float64, len(dayLabels))
+	activitiesByDay := make([]float64, len(dayLabels))
+
+	// Распределение уходов по часам
+	var hourLabels []string
+	for hour := 0; hour < 24; hour++ {
+		hourLabels = append(hourLabels, fmt.Sprintf("%02d", hour))
+	}
+	leavesByHour := make([]float64, 24)
+
+	// Количество событий по подчиненным
+	type subordinateCounts struct {
+		name       string
+		leaves     float64
+		activities float64
+	}
+	bySubordinate := make(map[int]*subordinateCounts)
+	counts := func(id int, name string) *subordinateCounts {
+		if bySubordinate[id] == nil {
+			bySubordinate[id] = &subordinateCounts{name: name}
+		}
+		return bySubordinate[id]
+	}
+
+	for _, item := range leaves {
+		if i, ok := dayIndex[item.LeaveTime.Format("2006-01-02")]; ok {
+			leavesByDay[i]++
+		}
+		leavesByHour[item.LeaveTime.Hour()]++
+		counts(item.Subordinate.ID, item.Subordinate.LastName+" "+item.Subordinate.FirstName).leaves++
+	}
+
+	for _, item := range activities {
+		if i, ok := dayIndex[item.Activity.ActivityTime.Format("2006-01-02")]; ok {
+			activitiesByDay[i]++
+		}
+		counts(item.Subordinate.ID, item.Subordinate.LastName+" "+item.Subordinate.FirstName).activities++
+	}
+
+	subordinateList := make([]*subordinateCounts, 0, len(bySubordinate))
+	for _, item := range bySubordinate {
+		subordinateList = append(subordinateList, item)
+	}
+	sort.Slice(subordinateList, func(i, j int) bool {
+		ti := subordinateList[i].leaves + subordinateList[i].activities
+		tj := subordinateList[j].leaves + subordinateList[j].activities
+		if ti != tj {
+			return ti > tj
+		}
+		return subordinateList[i].name < subordinateList[j].name
+	})
+
+	var subLabels []string
+	var subLeaves, subActivities []float64
+	for _, item := range subordinateList {
+		subLabels = append(subLabels, item.name)
+		subLeaves = append(subLeaves, item.leaves)
+		subActivities = append(subActivities, item.activities)
+	}
+
+	daily, err := charts.RenderBars(charts.BarChart{
+		Title:  "События по дням, " + periodTitle,
+		Labels: dayLabels,
+		Series: []charts.Series{
+			{Name: "Уходы", Values: leavesByDay, Color: charts.ColorLeaves},
+			{Name: "Внеплановая деятельность", Values: activitiesByDay, Color: charts.ColorActivities},
+		},
+	})
+	if err != nil {
+		h.sendError(chatID, "Ошибка построения графика: "+err.Error())
+		return
+	}
+
+	hourly, err := charts.RenderBars(charts.BarChart{
+		Title:  "Время уходов по часам, " + periodTitle,
+		Labels: hourLabels,
+		Series: []charts.Series{
+			{Values: leavesByHour, Color: charts.ColorLeaves},
+		},
+	})
+	if err != nil {
+		h.sendError(chatID, "Ошибка построения графика: "+err.Error())
+		return
+	}
+
+	perSubordinate, err := charts.RenderHorizontalBars(charts.BarChart{
+		Title:  "События по подчиненным, " + periodTitle,
+		Labels: subLabels,
+		Series: []charts.Series{
+			{Name: "Уходы", Values: subLeaves, Color: charts.ColorLeaves},
+			{Name: "Внеплановая деятельность", Values: subActivities, Color: charts.ColorActivities},
+		},
+	})
+	if err != nil {
+		h.sendError(chatID, "Ошибка построения графика: "+err.Error())
+		return
+	}
+
+	h.sendChart(chatID, "daily.png", daily,
+		fmt.Sprintf("📊 Уходов: %d, внеплановой деятельности: %d", len(leaves), len(activities)))
+	h.sendChart(chatID, "hourly.png", hourly, "🕒 Гистограмма времени уходов")
+	h.sendChart(chatID, "subordinates.png", perSubordinate, "👥 События по подчиненным")
+}
+
+func (h *BotHandler) sendChart(chatID int64, name string, data []byte, caption string) {
+	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	photo.Caption = caption
+
+	if _, err := h.bot.Send(photo); err != nil {
+		h.sendError(chatID, "Ошибка отправки графика: "+err.Error())
+	}
+}
+
+// chartDays возвращает количество дней в периоде включительно
+func chartDays(from, to time.Time) int {
+	return int(to.Sub(from).Hours()/24) + 1
+}
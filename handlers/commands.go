@@ -11,9 +11,11 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
-func (h *BotHandler) handleAddExcel(chatID int64, document *tgbotapi.Document) {
+func (h *BotHandler) handleAddExcel(key sessionKey, document *tgbotapi.Document) {
+	chatID := key.ChatID
+
 	// Проверка прав
-	if !h.isAdmin(chatID) {
+	if !h.isAdmin(key.UserID) {
 		h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
 		return
 	}
@@ -80,6 +82,11 @@ func (h *BotHandler) handleAddExcel(chatID int64, document *tgbotapi.Document) {
 
 	h.bot.Send(msg)
 
+	if count, err := h.db.CountGuardians(); err == nil && count > 0 {
+		msg = tgbotapi.NewMessage(chatID, fmt.Sprintf("👪 Контактов представителей в базе: %d", count))
+		h.bot.Send(msg)
+	}
+
 	// Показываем общий список
 	h.showAllSubordinates(chatID)
 }
@@ -109,9 +116,11 @@ func (h *BotHandler) showAllSubordinates(chatID int64) {
 func (h *BotHandler) handleStatistics(chatID int64) {
 	// Реализация статистики
 }
-func (h *BotHandler) handleExcelExport(chatID int64) {
+func (h *BotHandler) handleExcelExport(key sessionKey) {
+	chatID := key.ChatID
+
 	// Проверка прав
-	if !h.isAdmin(chatID) {
+	if !h.isAdmin(key.UserID) {
 		h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
 		return
 	}
@@ -139,11 +148,13 @@ func (h *BotHandler) handleExcelExport(chatID int64) {
 		h.sendError(chatID, "Ошибка отправки файла: "+err.Error())
 	}
 }
-func (h *BotHandler) handleLeaveTimeInput(chatID int64, text string) {
+func (h *BotHandler) handleLeaveTimeInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Реализация ввода времени для ухода
-	delete(h.userStates, chatID)
+	delete(h.userStates, key)
 
-	userData, exists := h.userData[chatID]
+	userData, exists := h.userData[key]
 	if !exists {
 		h.sendError(chatID, "Данные сессии устарели")
 		return
@@ -155,15 +166,21 @@ func (h *BotHandler) handleLeaveTimeInput(chatID int64, text string) {
 		return
 	}
 
-	subordinateID := userData["subordinate_id"].(int)
-	h.recordLeave(chatID, subordinateID, leaveTime)
-	delete(h.userData, chatID)
+	subordinateID, ok := userData["subordinate_id"].(int)
+	delete(h.userData, key)
+	if !ok {
+		h.sendError(chatID, "Данные сессии устарели")
+		return
+	}
+	h.recordLeave(key, subordinateID, leaveTime, nil, false)
 }
-func (h *BotHandler) handleUnplannedDetailsInput(chatID int64, text string) {
+func (h *BotHandler) handleUnplannedDetailsInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Реализация ввода описания для внеплановой деятельности
-	delete(h.userStates, chatID)
+	delete(h.userStates, key)
 
-	userData, exists := h.userData[chatID]
+	userData, exists := h.userData[key]
 	if !exists {
 		h.sendError(chatID, "Данные сессии устарели")
 		return
@@ -174,9 +191,13 @@ func (h *BotHandler) handleUnplannedDetailsInput(chatID int64, text string) {
 		return
 	}
 
-	subordinateID := userData["subordinate_id"].(int)
-	activityTime := userData["activity_time"].(time.Time)
+	subordinateID, hasSubordinate := userData["subordinate_id"].(int)
+	activityTime, hasTime := userData["activity_time"].(time.Time)
 
-	h.recordUnplannedActivity(chatID, subordinateID, activityTime, text)
-	delete(h.userData, chatID)
+	delete(h.userData, key)
+	if !hasSubordinate || !hasTime {
+		h.sendError(chatID, "Данные сессии устарели")
+		return
+	}
+	h.recordUnplannedActivity(key, subordinateID, activityTime, text, nil, false)
 }
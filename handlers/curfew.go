@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"whereismychildren/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// RunCurfewCheck отправляет администраторам и группам ответственных список тех, кто на момент отбоя не вернулся.
+// Вызывается планировщиком
+func (h *BotHandler) RunCurfewCheck() {
+	date := time.Now().In(h.config.Location)
+
+	message, keyboard, err := h.buildCurfewReport(date, 0)
+	if err != nil {
+		log.Printf("Error building curfew report: %v", err)
+		return
+	}
+
+	for _, chatID := range h.supervisorRecipients() {
+		msg := tgbotapi.NewMessage(chatID, message)
+		if keyboard != nil {
+			msg.ReplyMarkup = keyboard
+		}
+		if _, err := h.bot.Send(msg); err != nil {
+			log.Printf("Error sending curfew report to %d: %v", chatID, err)
+		}
+	}
+}
+
+// Количество не вернувшихся на одной странице отчёта об отбое: по две кнопки на человека,
+// а Telegram не принимает клавиатуры больше чем из 100 кнопок
+const curfewPageSize = 20
+
+// buildCurfewReport формирует список незакрытых отсутствий за день с кнопками для каждого подчиненного.
+// Длинный список разбит на страницы по curfewPageSize человек
+func (h *BotHandler) buildCurfewReport(date time.Time, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	statuses, err := h.db.GetStatusesForDate(date)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var unresolved, staysOut []database.SubordinateStatus
+	for _, item := range statuses {
+		if item.Status != database.StatusLeft && item.Status != database.StatusActivity {
+			continue
+		}
+		if item.StaysOut {
+			staysOut = append(staysOut, item)
+		} else {
+			unresolved = append(unresolved, item)
+		}
+	}
+
+	pages := (len(unresolved) + curfewPageSize - 1) / curfewPageSize
+	if page >= pages {
+		page = pages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	pageItems := unresolved
+	if pages > 1 {
+		end := (page + 1) * curfewPageSize
+		if end > len(unresolved) {
+			end = len(unresolved)
+		}
+		pageItems = unresolved[page*curfewPageSize : end]
+	}
+
+	message := fmt.Sprintf("🌙 Отбой, %s\n\n", date.Format("02.01.2006"))
+
+	if len(unresolved) == 0 {
+		message += "✅ Все отметки закрыты, не вернувшихся нет."
+	} else {
+		header := fmt.Sprintf("Не отмечено возвращение (%d)", len(unresolved))
+		if pages > 1 {
+			header += fmt.Sprintf(", страница %d из %d", page+1, pages)
+		}
+		message += header + ":\n"
+		for _, item := range pageItems {
+			message += fmt.Sprintf("• %s %s %s - %s\n",
+				item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName,
+				formatStatus(item))
+		}
+	}
+
+	if len(staysOut) > 0 {
+		message += fmt.Sprintf("\nОстаются вне (%d):\n", len(staysOut))
+		for _, item := range staysOut {
+			message += fmt.Sprintf("• %s %s %s\n",
+				item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName)
+		}
+	}
+
+	if len(unresolved) == 0 {
+		return message, nil, nil
+	}
+
+	day := date.Format("20060102")
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, item := range pageItems {
+		name := fmt.Sprintf("%s %s", item.Subordinate.LastName, item.Subordinate.FirstName)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ "+name+" - Вернулся",
+				fmt.Sprintf("curfewret_%s_%d_%s_%d", item.Kind, item.RecordID, day, page)),
+			tgbotapi.NewInlineKeyboardButtonData("🌙 Остаётся вне",
+				fmt.Sprintf("curfewout_%s_%d_%s_%d", item.Kind, item.RecordID, day, page)),
+		))
+	}
+
+	if pages > 1 {
+		// Переключение страниц, как в клавиатуре выбора
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀", fmt.Sprintf("curfewpage_%s_%d", day, page-1)))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d / %d", page+1, pages), "subnoop"))
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶", fmt.Sprintf("curfewpage_%s_%d", day, page+1)))
+		}
+		rows = append(rows, nav)
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return message, &keyboard, nil
+}
+
+// handleCurfewCallback обрабатывает кнопки "Вернулся" / "Остаётся вне" и обновляет список
+// на той же странице (кнопки без номера страницы отправлены до разбиения на страницы)
+func (h *BotHandler) handleCurfewCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	var page int
+	if parts := strings.Split(callback.Data, "_"); len(parts) > 4 {
+		page, _ = strconv.Atoi(parts[4])
+	}
+
+	kind, id, day, err := parseRecordCallback(callback.Data)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+	if !h.canHandleReturnButton(callback, kind, id) {
+		h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
+		return
+	}
+
+	date, err := time.ParseInLocation("20060102", fmt.Sprintf("%d", day), h.config.Location)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+
+	if strings.HasPrefix(callback.Data, "curfewret_") {
+		err = h.db.MarkReturned(kind, id, time.Now())
+	} else {
+		err = h.db.SetStaysOut(kind, id)
+	}
+	if err != nil {
+		h.sendError(chatID, "Ошибка сохранения отметки: "+err.Error())
+		return
+	}
+
+	h.showCurfewPage(chatID, callback.Message.MessageID, date, page)
+}
+
+// handleCurfewPageCallback переключает страницу отчёта об отбое: curfewpage_<ГГГГММДД>_<страница>
+func (h *BotHandler) handleCurfewPageCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	parts := strings.Split(callback.Data, "_")
+	if len(parts) != 3 {
+		h.sendError(chatID, "Ошибка обработки кнопки: неверные данные кнопки: "+callback.Data)
+		return
+	}
+
+	date, err := time.ParseInLocation("20060102", parts[1], h.config.Location)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+	page, err := strconv.Atoi(parts[2])
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+
+	h.showCurfewPage(chatID, callback.Message.MessageID, date, page)
+}
+
+// showCurfewPage заменяет отчёт об отбое в сообщении на актуальный список с указанной страницей
+func (h *BotHandler) showCurfewPage(chatID int64, messageID int, date time.Time, page int) {
+	message, keyboard, err := h.buildCurfewReport(date, page)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обновления списка: "+err.Error())
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, message)
+	if keyboard != nil {
+		edit.ReplyMarkup = keyboard
+	}
+	h.bot.Send(edit)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"fmt"
+
+	"whereismychildren/web"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleDashboardCommand присылает одноразовую ссылку для входа в веб-панель
+func (h *BotHandler) handleDashboardCommand(key sessionKey) {
+	chatID := key.ChatID
+	if h.config.WebURL == "" {
+		h.sendError(chatID, "Веб-панель не настроена: укажите WEB_ADDR в .env")
+		return
+	}
+	// Ссылка открывает доступ без пароля, поэтому не показываем её в группах
+	if chatID != key.UserID {
+		h.sendError(chatID, "Запросите ссылку в личном чате с ботом")
+		return
+	}
+
+	token, err := h.db.CreateLoginLink(key.UserID, web.LoginLinkTTL)
+	if err != nil {
+		h.sendError(chatID, "Ошибка создания ссылки: "+err.Error())
+		return
+	}
+
+	link := fmt.Sprintf("%s/login?token=%s", h.config.WebURL, token)
+	h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"🖥 Ссылка для входа в панель \"Где подчинённые\":\n%s\n\n"+
+			"Ссылка одноразовая и действует %d минут. После входа панель обновляется сама.",
+		link, int(web.LoginLinkTTL.Minutes()))))
+}
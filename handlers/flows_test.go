@@ -0,0 +1,523 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"whereismychildren/backup"
+	"whereismychildren/bottest"
+	"whereismychildren/database"
+	"whereismychildren/database/dbtest"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestRecordLeaveViaButton(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Зафиксировать уход"))
+	selection := sender.lastMessage(t)
+	if !strings.Contains(selection.Text, "Выберите подчиненного") {
+		t.Fatalf("expected selection prompt, got %q", selection.Text)
+	}
+
+	sender.reset()
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, selection.ReplyMarkup, "Петров Петр")))
+
+	sender.requireMessage(t, "✅ Петров Петр ушёл в")
+	if got := countRecords(t, db, "leaves", subs[1].ID); got != 1 {
+		t.Fatalf("expected 1 leave for Петров, got %d", got)
+	}
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 0 {
+		t.Fatalf("expected no leaves for Иванов, got %d", got)
+	}
+}
+
+func TestRecordLeaveFromFreeText(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+
+	sender.requireMessage(t, "✅ Иванов Иван ушёл в 14:30")
+	leave, err := db.GetLeaveForDate(subs[0].ID, utils.GetMoscowTime())
+	if err != nil {
+		t.Fatalf("leave was not recorded: %v", err)
+	}
+	if got := leave.LeaveTime.Format("15:04"); got != "14:30" {
+		t.Fatalf("expected leave at 14:30, got %s", got)
+	}
+}
+
+func TestFreeTextWithAmbiguousNameAsksToChoose(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Иванов Пётр Сергеевич")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+	selection := sender.lastMessage(t)
+	if got := countRecords(t, db, "leaves", subs[0].ID) + countRecords(t, db, "leaves", subs[1].ID); got != 0 {
+		t.Fatalf("leave must not be recorded before selection, got %d", got)
+	}
+
+	sender.reset()
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, selection.ReplyMarkup, "Иванов Пётр")))
+
+	sender.requireMessage(t, "Иванов Пётр ушёл в 14:30")
+	if got := countRecords(t, db, "leaves", subs[1].ID); got != 1 {
+		t.Fatalf("expected 1 leave for selected subordinate, got %d", got)
+	}
+}
+
+func TestFreeTextWithTypoAsksToConfirm(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Ивонов 14:30"))
+	sender.requireMessage(t, "Вы имели в виду Иванов Иван Иванович?")
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 0 {
+		t.Fatalf("leave must not be recorded before confirmation, got %d", got)
+	}
+
+	// Отказ ничего не записывает
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, sender.lastMessage(t).ReplyMarkup, "Нет")))
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 0 {
+		t.Fatalf("declined match must not be recorded, got %d", got)
+	}
+
+	sender.reset()
+	h.HandleMessage(textUpdate(bottest.UserID, "Ивонов 14:30"))
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, sender.lastMessage(t).ReplyMarkup, "Да")))
+
+	sender.requireMessage(t, "✅ Иванов Иван ушёл в 14:30")
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 1 {
+		t.Fatalf("expected 1 leave after confirmation, got %d", got)
+	}
+}
+
+func TestStaleConfirmationButtonIsRejected(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Ивонов 14:30"))
+	prompt := sender.lastMessage(t)
+
+	// Пользователь начал другое действие, а потом нажал "Да" под старым вопросом
+	h.HandleMessage(textUpdate(bottest.UserID, "Зафиксировать уход"))
+	sender.reset()
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, prompt.ReplyMarkup, "Да")))
+
+	sender.requireMessage(t, "Данные сессии устарели")
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 0 {
+		t.Fatalf("stale confirmation must not record a leave, got %d", got)
+	}
+
+	// Начатое действие продолжается: выбор из списка по-прежнему работает
+	h.HandleCallback(callbackUpdate(bottest.UserID, "select_sub_"+strconv.Itoa(subs[1].ID)))
+	if got := countRecords(t, db, "leaves", subs[1].ID); got != 1 {
+		t.Fatalf("expected the current flow to record a leave, got %d", got)
+	}
+
+	// Кнопки без действия (отправленные до обновления бота) тоже ничего не подтверждают
+	sender.reset()
+	h.HandleCallback(callbackUpdate(bottest.UserID, "confirm_yes"))
+	sender.requireMessage(t, "Данные сессии устарели")
+}
+
+func TestGroupMemberCannotPressAnotherMembersKeyboard(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+	if err := db.RegisterSupervisorChat(-100, "Учителя", bottest.AdminID); err != nil {
+		t.Fatal(err)
+	}
+
+	inGroup := func(update tgbotapi.Update) tgbotapi.Update {
+		chat := &tgbotapi.Chat{ID: -100, Type: "supergroup"}
+		if update.Message != nil {
+			update.Message.Chat = chat
+		} else {
+			update.CallbackQuery.Message.Chat = chat
+		}
+		return update
+	}
+	press := func(userID int64, data string, messageID int) {
+		update := inGroup(callbackUpdate(userID, data))
+		update.CallbackQuery.Message.MessageID = messageID
+		h.HandleCallback(update)
+	}
+
+	h.HandleMessage(inGroup(textUpdate(bottest.AdminID, "Зафиксировать уход")))
+	keyboardID, _ := h.userData[sessionKey{ChatID: -100, UserID: bottest.AdminID}]["keyboard_message_id"].(int)
+	choice := "select_sub_" + strconv.Itoa(subs[1].ID)
+
+	// Коллега нажимает кнопку под чужим списком - и когда у него нет своего сценария, и когда он есть
+	sender.reset()
+	press(bottest.UserID, choice, keyboardID)
+	h.HandleMessage(inGroup(textUpdate(bottest.UserID, "Внеплановая деятельность")))
+	press(bottest.UserID, choice, keyboardID)
+
+	sender.requireMessage(t, "относятся к запросу другого сотрудника")
+	for _, c := range sender.sent {
+		if _, ok := c.(tgbotapi.DeleteMessageConfig); ok {
+			t.Fatal("another member's press must not delete the keyboard")
+		}
+	}
+	if got := countRecords(t, db, "leaves", subs[1].ID); got != 0 {
+		t.Fatalf("another member's press must not record a leave, got %d", got)
+	}
+
+	// Владелец списка продолжает свой сценарий
+	press(bottest.AdminID, choice, keyboardID)
+	sender.requireMessage(t, "✅ Петров Петр ушёл в")
+	if got := countRecords(t, db, "leaves", subs[1].ID); got != 1 {
+		t.Fatalf("expected the owner's press to record a leave, got %d", got)
+	}
+}
+
+func TestReturnButtonsRequireStaff(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+
+	now := time.Now()
+	leave, err := db.RecordLeave(subs[0].ID, now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Напоминания по записи приходят тому, кто её сделал
+	if err := db.SetExpectedReturn(database.KindLeave, leave.ID, now, bottest.UserID); err != nil {
+		t.Fatal(err)
+	}
+	returned := "returned_leave_" + strconv.Itoa(leave.ID)
+
+	isReturned := func() bool {
+		t.Helper()
+		record, err := db.GetLeaveForDate(subs[0].ID, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record.ReturnedAt != nil
+	}
+
+	// Посторонний пользователь в личном чате
+	h.HandleCallback(callbackUpdate(3003, returned))
+	sender.requireMessage(t, "нет прав")
+
+	// Группа, которую не регистрировали
+	sender.reset()
+	update := callbackUpdate(3003, "curfewret_leave_"+strconv.Itoa(leave.ID)+"_"+now.Format("20060102"))
+	update.CallbackQuery.Message.Chat = &tgbotapi.Chat{ID: -200, Type: "supergroup"}
+	h.HandleCallback(update)
+	if texts := sender.messages(); len(texts) != 0 {
+		t.Fatalf("bot must ignore buttons in an unregistered group, got %q", texts)
+	}
+	if isReturned() {
+		t.Fatal("return must not be marked by an outsider")
+	}
+
+	// Получатель напоминаний отмечает возвращение
+	h.HandleCallback(callbackUpdate(bottest.UserID, returned))
+	if !isReturned() {
+		t.Fatal("expected the recipient of the alert to mark the return")
+	}
+}
+
+func TestCurfewReportIsPaginated(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	h.config.Location = time.Local
+
+	var names []string
+	for i := 1; i <= 45; i++ {
+		names = append(names, fmt.Sprintf("Ученик%02d Иван", i))
+	}
+	now := time.Now()
+	for _, sub := range dbtest.AddSubordinates(t, db, names...) {
+		if _, err := db.RecordLeave(sub.ID, now, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buttons := func(keyboard interface{}) []tgbotapi.InlineKeyboardButton {
+		t.Helper()
+		markup, ok := keyboard.(*tgbotapi.InlineKeyboardMarkup)
+		if !ok {
+			t.Fatalf("expected an inline keyboard, got %T", keyboard)
+		}
+		var all []tgbotapi.InlineKeyboardButton
+		for _, row := range markup.InlineKeyboard {
+			all = append(all, row...)
+		}
+		return all
+	}
+
+	h.RunCurfewCheck()
+	report := sender.lastMessage(t)
+	if !strings.Contains(report.Text, "Не отмечено возвращение (45), страница 1 из 3") {
+		t.Fatalf("expected the first page of the report, got %q", report.Text)
+	}
+	if got := len(buttons(report.ReplyMarkup)); got > 100 {
+		t.Fatalf("Telegram rejects keyboards with more than 100 buttons, got %d", got)
+	}
+
+	// Последняя страница: отметка возвращения обновляет список на той же странице
+	sender.reset()
+	h.HandleCallback(callbackUpdate(bottest.AdminID, "curfewpage_"+now.Format("20060102")+"_2"))
+	h.HandleCallback(callbackUpdate(bottest.AdminID, bottest.ButtonData(t, sender.lastEdit(t).ReplyMarkup, "✅ Ученик45 Иван - Вернулся")))
+
+	edit := sender.lastEdit(t)
+	if !strings.Contains(edit.Text, "Не отмечено возвращение (44), страница 3 из 3") || strings.Contains(edit.Text, "Ученик45") {
+		t.Fatalf("expected the last page without the returned subordinate, got %q", edit.Text)
+	}
+}
+
+func TestRecordUnplannedActivity(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Внеплановая деятельность"))
+	selection := sender.lastMessage(t)
+
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, selection.ReplyMarkup, "Иванов Иван")))
+	if prompt := sender.lastMessage(t); !strings.Contains(prompt.Text, "Введите описание") {
+		t.Fatalf("expected description prompt, got %q", prompt.Text)
+	}
+
+	sender.reset()
+	h.HandleMessage(textUpdate(bottest.UserID, "Олимпиада по математике"))
+
+	sender.requireMessage(t, "Олимпиада по математике")
+	activity, err := db.GetActivityForDate(subs[0].ID, utils.GetMoscowTime())
+	if err != nil {
+		t.Fatalf("activity was not recorded: %v", err)
+	}
+	if activity.Description != "Олимпиада по математике" {
+		t.Fatalf("unexpected description %q", activity.Description)
+	}
+}
+
+func TestActivityAndLeaveOnTheSameDay(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+	h.HandleMessage(textUpdate(bottest.UserID, "Внеплановая деятельность"))
+	h.HandleCallback(callbackUpdate(bottest.UserID, "select_sub_"+strconv.Itoa(subs[0].ID)))
+	sender.reset()
+	h.HandleMessage(textUpdate(bottest.UserID, "Кружок"))
+
+	sender.requireMessage(t, "зафиксирована деятельность")
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 1 {
+		t.Fatalf("leave should be kept, got %d leaves", got)
+	}
+	if got := countRecords(t, db, "unplanned_activities", subs[0].ID); got != 1 {
+		t.Fatalf("expected 1 activity, got %d", got)
+	}
+}
+
+func TestRepeatedLeaveAsksToReplace(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+	sender.reset()
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+
+	prompt := sender.lastMessage(t)
+	if !strings.Contains(prompt.Text, "Иванов Иван: уже есть уход в 14:30 — заменить уходом в 14:30?") {
+		t.Fatalf("expected replacement prompt, got %q", prompt.Text)
+	}
+
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, prompt.ReplyMarkup, "Нет")))
+	sender.requireMessage(t, "Действие отменено")
+
+	sender.reset()
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+	h.HandleCallback(callbackUpdate(bottest.UserID, bottest.ButtonData(t, sender.lastMessage(t).ReplyMarkup, "Да")))
+
+	sender.requireMessage(t, "✅ Иванов Иван ушёл в 14:30")
+	if got := countRecords(t, db, "leaves", subs[0].ID); got != 1 {
+		t.Fatalf("repeated leave must not be duplicated, got %d", got)
+	}
+}
+
+func TestStatisticsForDay(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+
+	h.HandleMessage(textUpdate(bottest.UserID, "Иванов 14:30"))
+	h.HandleMessage(textUpdate(bottest.UserID, "Внеплановая деятельность"))
+	h.HandleCallback(callbackUpdate(bottest.UserID, "select_sub_"+strconv.Itoa(subs[0].ID)))
+	h.HandleMessage(textUpdate(bottest.UserID, "Олимпиада"))
+	sender.reset()
+
+	today := utils.GetMoscowTime().Format("02.01.2006")
+	h.HandleMessage(textUpdate(bottest.UserID, "/stat "+today))
+
+	msg := sender.lastMessage(t)
+	if !strings.Contains(msg.Text, "Статистика за "+today) {
+		t.Fatalf("unexpected statistics header: %q", msg.Text)
+	}
+	if !strings.Contains(msg.Text, "Иванов Иван Иванович** - ушел в 14:30") {
+		t.Fatalf("statistics should list the leave: %q", msg.Text)
+	}
+	if !strings.Contains(msg.Text, "Иванов Иван Иванович** - внеплановая деятельность в") ||
+		!strings.Contains(msg.Text, "Олимпиада") {
+		t.Fatalf("statistics should list the activity: %q", msg.Text)
+	}
+	if strings.Contains(msg.Text, "Петров") {
+		t.Fatalf("statistics should not list subordinates without leaves: %q", msg.Text)
+	}
+}
+
+func TestStatisticsRejectsInvalidDate(t *testing.T) {
+	h, sender, _ := newTestHandler(t)
+
+	h.HandleMessage(textUpdate(bottest.UserID, "/stat 31.13.2025"))
+
+	sender.requireMessage(t, "Неверный формат даты")
+}
+
+func TestWhoShowsGuardianPhonesOnlyToAdmins(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+	guardian := database.Guardian{SubordinateID: subs[0].ID, FullName: "Иванова Мария", Relation: "мама", Phone: "+79001234567"}
+	if err := db.AddGuardian(guardian); err != nil {
+		t.Fatal(err)
+	}
+
+	h.HandleMessage(textUpdate(bottest.UserID, "/who Иванов"))
+	if msg := sender.lastMessage(t); !strings.Contains(msg.Text, "Иванова Мария (мама)") || strings.Contains(msg.Text, guardian.Phone) {
+		t.Fatalf("expected guardian without phone for a user, got %q", msg.Text)
+	}
+
+	h.HandleMessage(textUpdate(bottest.AdminID, "/who Иванов"))
+	if msg := sender.lastMessage(t); !strings.Contains(msg.Text, "Иванова Мария (мама), "+guardian.Phone) {
+		t.Fatalf("expected guardian phone for an admin, got %q", msg.Text)
+	}
+}
+
+func TestGuardianWhoIsStaffWorksAsStaffInGroups(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	subs := dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+	guardian := database.Guardian{SubordinateID: subs[0].ID, FullName: "Иванова Мария", Phone: "+79001234567"}
+	if err := db.AddGuardian(guardian); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.LinkGuardiansByPhone(guardian.Phone, bottest.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RegisterSupervisorChat(-100, "Учителя", bottest.AdminID); err != nil {
+		t.Fatal(err)
+	}
+
+	groupUpdate := func(chatID int64, text string) tgbotapi.Update {
+		update := textUpdate(bottest.UserID, text)
+		update.Message.Chat = &tgbotapi.Chat{ID: chatID, Type: "supergroup"}
+		return update
+	}
+
+	// В личном чате - меню представителя
+	h.HandleMessage(textUpdate(bottest.UserID, "Где мой ребёнок"))
+	sender.requireMessage(t, "Статус на сегодня")
+
+	// В незарегистрированной группе бот молчит и не раскрывает статус ребёнка
+	sender.reset()
+	h.HandleMessage(groupUpdate(-200, "Где мой ребёнок"))
+	if texts := sender.messages(); len(texts) != 0 {
+		t.Fatalf("bot must stay silent in an unregistered group, got %q", texts)
+	}
+
+	// В группе сотрудников тот же человек работает как сотрудник
+	h.HandleMessage(groupUpdate(-100, "/who Иванов"))
+	sender.requireMessage(t, "👤 Иванов Иван Иванович")
+}
+
+func TestAddExcel(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+	dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+
+	path := bottest.WriteSubordinatesExcel(t,
+		[]string{"Фамилия", "Имя", "Отчество"},
+		[]string{"Иванов", "Иван", "Иванович"},
+		[]string{"Сидорова", "Анна", "Павловна"},
+		[]string{"Кузнецов", "Олег", "Игоревич"},
+	)
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, path)
+	}))
+	defer files.Close()
+	sender.files["excel-file"] = files.URL + "/list.xlsx"
+
+	update := textUpdate(bottest.AdminID, "")
+	update.Message.Caption = "/add_excel"
+	update.Message.Document = &tgbotapi.Document{FileID: "excel-file", FileName: "list.xlsx"}
+	h.HandleMessage(update)
+
+	sender.requireMessage(t, "Добавлено 2 новых подчиненных")
+	all, err := db.GetAllSubordinates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 subordinates after import, got %d", len(all))
+	}
+}
+
+func TestAddExcelRequiresAdmin(t *testing.T) {
+	h, sender, db := newTestHandler(t)
+
+	update := textUpdate(bottest.UserID, "")
+	update.Message.Caption = "/add_excel"
+	update.Message.Document = &tgbotapi.Document{FileID: "excel-file", FileName: "list.xlsx"}
+	h.HandleMessage(update)
+
+	sender.requireMessage(t, "нет прав")
+	if all, _ := db.GetAllSubordinates(); len(all) != 0 {
+		t.Fatalf("non-admin import must not add subordinates, got %d", len(all))
+	}
+}
+
+func TestBackupSendsLatestCopy(t *testing.T) {
+	if dbtest.Driver() == database.DriverPostgres {
+		t.Skip("backups are SQLite only")
+	}
+	h, sender, db := newTestHandler(t)
+	dir := t.TempDir()
+	h.backups = backup.New(db, dir, backup.Retention{Daily: 7}, time.UTC)
+
+	// В группе копия не отправляется, даже администратору
+	if err := db.RegisterSupervisorChat(-100, "Дежурные", bottest.AdminID); err != nil {
+		t.Fatal(err)
+	}
+	update := textUpdate(bottest.AdminID, "/backup")
+	update.Message.Chat = &tgbotapi.Chat{ID: -100, Type: "supergroup"}
+	h.HandleMessage(update)
+	sender.requireMessage(t, "в личном чате")
+
+	// Копий ещё нет - бот делает её сразу
+	sender.reset()
+	h.HandleMessage(textUpdate(bottest.AdminID, "/backup"))
+	sender.requireMessage(t, "💾 Резервная копия базы")
+
+	sender.mu.Lock()
+	doc, ok := sender.sent[len(sender.sent)-1].(tgbotapi.DocumentConfig)
+	sender.mu.Unlock()
+	if !ok {
+		t.Fatal("expected backup to be sent as a document")
+	}
+	path := string(doc.File.(tgbotapi.FilePath))
+	if filepath.Dir(path) != dir {
+		t.Fatalf("expected backup from %s, got %s", dir, path)
+	}
+	copied, err := database.Open(dbtest.Driver(), path)
+	if err != nil {
+		t.Fatalf("backup must open as a database: %v", err)
+	}
+	copied.Close()
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleRegisterGroup разрешает работу бота в текущей группе. Доступно только администраторам
+func (h *BotHandler) handleRegisterGroup(key sessionKey, title string) {
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	if err := h.db.RegisterSupervisorChat(key.ChatID, title, key.UserID); err != nil {
+		h.sendError(key.ChatID, "Ошибка регистрации группы: "+err.Error())
+		return
+	}
+
+	msg := tgbotapi.NewMessage(key.ChatID, "✅ Группа зарегистрирована. Участники могут фиксировать уходы и внеплановую деятельность, "+
+		"сюда же будут приходить напоминания и отчёты об отбое.\n\n"+
+		"Команды: /leave, /activity, /where, /statistics")
+	msg.ReplyMarkup = GetMainKeyboard()
+	h.bot.Send(msg)
+}
+
+func (h *BotHandler) handleUnregisterGroup(key sessionKey) {
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	removed, err := h.db.UnregisterSupervisorChat(key.ChatID)
+	if err != nil {
+		h.sendError(key.ChatID, "Ошибка отмены регистрации группы: "+err.Error())
+		return
+	}
+
+	text := "Группа не была зарегистрирована"
+	if removed {
+		text = "✅ Регистрация группы отменена"
+	}
+	msg := tgbotapi.NewMessage(key.ChatID, text)
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(false)
+	h.bot.Send(msg)
+}
+
+func (h *BotHandler) isSupervisorChat(chatID int64) bool {
+	registered, err := h.db.IsSupervisorChat(chatID)
+	if err != nil {
+		log.Printf("Error checking supervisor chat %d: %v", chatID, err)
+		return false
+	}
+	return registered
+}
+
+// supervisorRecipients возвращает чаты для служебных уведомлений: администраторов и зарегистрированные группы
+func (h *BotHandler) supervisorRecipients() []int64 {
+	recipients := append([]int64{}, h.config.AdminIDs...)
+
+	chats, err := h.db.GetSupervisorChats()
+	if err != nil {
+		log.Printf("Error getting supervisor chats: %v", err)
+		return recipients
+	}
+
+	for _, chat := range chats {
+		recipients = append(recipients, chat.ChatID)
+	}
+	return recipients
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleGuardianStart предлагает представителю подтвердить номер телефона для подключения уведомлений
+func (h *BotHandler) handleGuardianStart(chatID int64) {
+	msg := tgbotapi.NewMessage(chatID,
+		"👪 Чтобы получать уведомления о ребёнке, отправьте свой номер телефона кнопкой ниже.\n"+
+			"Номер должен совпадать с указанным в списке у администратора.")
+	msg.ReplyMarkup = GetContactKeyboard()
+	h.bot.Send(msg)
+}
+
+// handleGuardianContact привязывает Telegram аккаунт к представителю по подтверждённому номеру телефона
+func (h *BotHandler) handleGuardianContact(message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	contact := message.Contact
+
+	// Принимаем только собственный номер, отправленный кнопкой, а не чужой контакт
+	if message.From == nil || contact.UserID != message.From.ID {
+		h.sendError(chatID, "Отправьте свой номер с помощью кнопки \"📱 Отправить номер телефона\"")
+		return
+	}
+
+	phone := utils.NormalizePhone(contact.PhoneNumber)
+	guardians, err := h.db.LinkGuardiansByPhone(phone, message.From.ID)
+	if err != nil {
+		h.sendError(chatID, "Ошибка подключения уведомлений: "+err.Error())
+		return
+	}
+
+	if len(guardians) == 0 {
+		msg := tgbotapi.NewMessage(chatID, "❌ Номер не найден в списке представителей. Обратитесь к администратору.")
+		msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+		h.bot.Send(msg)
+		return
+	}
+
+	log.Printf("Guardian %d linked to %d records", message.From.ID, len(guardians))
+
+	reply := "✅ Уведомления подключены. Вы будете получать сообщения об уходах и внеплановой деятельности:\n"
+	for _, g := range guardians {
+		sub, err := h.db.GetSubordinateByID(g.SubordinateID)
+		if err != nil {
+			continue
+		}
+		reply += fmt.Sprintf("• %s %s\n", sub.LastName, sub.FirstName)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, reply)
+	msg.ReplyMarkup = GetGuardianKeyboard()
+	h.bot.Send(msg)
+}
+
+// handleGuardianMessage обрабатывает сообщения представителя. Представителю доступна
+// только информация о его детях, команды сотрудников не выполняются
+func (h *BotHandler) handleGuardianMessage(chatID int64, telegramID int64, text string, guardians []database.Guardian) {
+	switch text {
+	case "/start", "/parent":
+		msg := tgbotapi.NewMessage(chatID, "👪 Здесь вы можете узнать, где ваш ребёнок, и управлять уведомлениями.")
+		msg.ReplyMarkup = GetGuardianKeyboard()
+		h.bot.Send(msg)
+	case "Где мой ребёнок":
+		h.showGuardianChildren(chatID, guardians)
+	case "🔔 Включить уведомления", "🔕 Отключить уведомления":
+		enabled := strings.HasPrefix(text, "🔔")
+		if err := h.db.SetGuardianNotifications(telegramID, enabled); err != nil {
+			h.sendError(chatID, "Ошибка изменения настроек: "+err.Error())
+			return
+		}
+		reply := "🔕 Уведомления отключены"
+		if enabled {
+			reply = "🔔 Уведомления включены"
+		}
+		msg := tgbotapi.NewMessage(chatID, reply)
+		h.bot.Send(msg)
+	default:
+		msg := tgbotapi.NewMessage(chatID, "Используйте кнопки ниже.")
+		msg.ReplyMarkup = GetGuardianKeyboard()
+		h.bot.Send(msg)
+	}
+}
+
+// showGuardianChildren показывает представителю текущий статус только его детей
+func (h *BotHandler) showGuardianChildren(chatID int64, guardians []database.Guardian) {
+	statuses, err := h.db.GetStatusesForDate(time.Now())
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения данных: "+err.Error())
+		return
+	}
+
+	children := make(map[int]bool)
+	for _, g := range guardians {
+		children[g.SubordinateID] = true
+	}
+
+	message := "📍 Статус на сегодня:\n\n"
+	for _, item := range statuses {
+		if !children[item.Subordinate.ID] {
+			continue
+		}
+		message += fmt.Sprintf("%s %s - %s\n", item.Subordinate.LastName, item.Subordinate.FirstName, formatStatus(item))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	h.bot.Send(msg)
+}
+
+// guardianFor возвращает записи представителя, если пользователь - представитель, а не администратор
+func (h *BotHandler) guardianFor(userID int64) []database.Guardian {
+	if h.isAdmin(userID) {
+		return nil
+	}
+
+	guardians, err := h.db.GetGuardiansByTelegramID(userID)
+	if err != nil {
+		log.Printf("Error checking guardian %d: %v", userID, err)
+		return nil
+	}
+	return guardians
+}
+
+// notifyGuardians отправляет сообщение представителям подчиненного, подключившим уведомления
+func (h *BotHandler) notifyGuardians(subordinateID int, message string) {
+	guardians, err := h.db.GetNotifiableGuardians(subordinateID)
+	if err != nil {
+		log.Printf("Error getting guardians for %d: %v", subordinateID, err)
+		return
+	}
+
+	for _, g := range guardians {
+		msg := tgbotapi.NewMessage(*g.TelegramID, "👪 "+message)
+		if _, err := h.bot.Send(msg); err != nil {
+			log.Printf("Error notifying guardian %d: %v", g.ID, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"whereismychildren/backup"
 	"whereismychildren/config"
 	"whereismychildren/database"
 	"whereismychildren/excel"
@@ -17,22 +19,54 @@ import (
 )
 
 type BotHandler struct {
-	bot            *tgbotapi.BotAPI
-	db             *database.DB
+	bot            Sender
+	botUserName    string // имя бота для команд с упоминанием (/stat@имя_бота)
+	db             database.Store
 	excelProcessor *excel.ExcelProcessor
-	userStates     map[int64]string
-	userData       map[int64]map[string]interface{}
+	userStates     map[sessionKey]string
+	userData       map[sessionKey]map[string]interface{}
 	config         *config.Config
+	backups        *backup.Manager // nil, если резервное копирование отключено
 }
 
-func NewBotHandler(bot *tgbotapi.BotAPI, db *database.DB, cfg *config.Config) *BotHandler {
+// sessionKey определяет сессию пользователя в конкретном чате: в группе у каждого
+// сотрудника свой ввод, а права проверяются по пользователю, а не по чату
+type sessionKey struct {
+	ChatID int64
+	UserID int64
+}
+
+func newSessionKey(chat *tgbotapi.Chat, from *tgbotapi.User) sessionKey {
+	key := sessionKey{ChatID: chat.ID, UserID: chat.ID}
+	if from != nil {
+		key.UserID = from.ID
+	}
+	return key
+}
+
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat.IsGroup() || chat.IsSuperGroup()
+}
+
+func NewBotHandler(bot Sender, botUserName string, db database.Store, cfg *config.Config) *BotHandler {
+	var backups *backup.Manager
+	if cfg.BackupDir != "" {
+		backups = backup.New(db, cfg.BackupDir, backup.Retention{
+			Daily:   cfg.BackupKeepDaily,
+			Weekly:  cfg.BackupKeepWeekly,
+			Monthly: cfg.BackupKeepMonthly,
+		}, cfg.Location)
+	}
+
 	return &BotHandler{
 		bot:            bot,
+		botUserName:    botUserName,
 		db:             db,
 		excelProcessor: excel.NewExcelProcessor(db),
-		userStates:     make(map[int64]string),
-		userData:       make(map[int64]map[string]interface{}),
+		userStates:     make(map[sessionKey]string),
+		userData:       make(map[sessionKey]map[string]interface{}),
 		config:         cfg,
+		backups:        backups,
 	}
 }
 
@@ -42,16 +76,54 @@ func (h *BotHandler) HandleMessage(update tgbotapi.Update) {
 	}
 
 	chatID := update.Message.Chat.ID
-	text := update.Message.Text
+	key := newSessionKey(update.Message.Chat, update.Message.From)
+
+	text, forThisBot := h.normalizeCommand(update.Message.Text)
+	if !forThisBot {
+		return
+	}
+
+	// В группах бот работает только в зарегистрированных чатах сотрудников
+	if isGroupChat(update.Message.Chat) {
+		switch {
+		case text == "/register_group":
+			h.handleRegisterGroup(key, update.Message.Chat.Title)
+			return
+		case text == "/unregister_group":
+			h.handleUnregisterGroup(key)
+			return
+		case !h.isSupervisorChat(chatID):
+			if strings.HasPrefix(text, "/") {
+				h.sendError(chatID, "Группа не зарегистрирована. Администратор может зарегистрировать её командой /register_group")
+			}
+			return
+		}
+	} else {
+		// Представители видят только информацию о своих детях и только в личном чате: в группах
+		// сотрудников тот же человек работает как сотрудник, а сведения о ребёнке не попадают в группу
+		if update.Message.From != nil {
+			if guardians := h.guardianFor(update.Message.From.ID); len(guardians) > 0 {
+				h.handleGuardianMessage(chatID, update.Message.From.ID, text, guardians)
+				return
+			}
+		}
+
+		// Номер телефона, отправленный представителем для подключения уведомлений
+		if update.Message.Contact != nil {
+			h.handleGuardianContact(update.Message)
+			return
+		}
+	}
 
 	// Проверяем, есть ли документ с командой /add_excel
-	if update.Message.Document != nil && update.Message.Caption != "" {
-		if strings.HasPrefix(update.Message.Caption, "/add_excel") {
-			if !h.isAdmin(chatID) {
+	caption, _ := h.normalizeCommand(update.Message.Caption)
+	if update.Message.Document != nil && caption != "" {
+		if strings.HasPrefix(caption, "/add_excel") {
+			if !h.isAdmin(key.UserID) {
 				h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
 				return
 			}
-			h.handleAddExcel(chatID, update.Message.Document)
+			h.handleAddExcel(key, update.Message.Document)
 			return
 		}
 	}
@@ -60,45 +132,128 @@ func (h *BotHandler) HandleMessage(update tgbotapi.Update) {
 	switch {
 	case text == "/start":
 		h.handleStart(chatID)
-	case text == "Зафиксировать уход":
-		h.handleRecordLeave(chatID)
-	case text == "Внеплановая деятельность":
-		h.handleUnplannedActivity(chatID) // ← обновленный вызов
-	case text == "Где подчинённые":
+	case text == "Зафиксировать уход" || text == "/leave":
+		h.handleRecordLeave(key)
+	case text == "Внеплановая деятельность" || text == "/activity":
+		h.handleUnplannedActivity(key) // ← обновленный вызов
+	case text == "Где подчинённые" || text == "/where":
 		h.handleWhereSubordinates(chatID)
-	case text == "Статистика":
+	case text == "Статистика" || text == "/statistics":
 		h.handleStatisticsMenu(chatID)
 	case strings.HasPrefix(text, "/stat"):
-		h.handleStatisticsCommand(chatID, text)
+		h.handleStatisticsCommand(key, text)
+	case strings.HasPrefix(text, "/report"):
+		h.handleReportCommand(chatID, text)
+	case strings.HasPrefix(text, "/subscribe"):
+		h.handleSubscribe(chatID, text)
+	case text == "/unsubscribe":
+		h.handleUnsubscribe(chatID)
+	case text == "/add" || strings.HasPrefix(text, "/add "):
+		h.handleAddSubordinate(key, text)
+	case strings.HasPrefix(text, "/rename"):
+		h.handleRosterCommand(key, "/rename", text)
+	case strings.HasPrefix(text, "/archive"):
+		h.handleRosterCommand(key, "/archive", text)
+	case strings.HasPrefix(text, "/restore"):
+		h.handleRosterCommand(key, "/restore", text)
+	case strings.HasPrefix(text, "/merge"):
+		h.handleRosterCommand(key, "/merge", text)
+	case text == "/dashboard":
+		h.handleDashboardCommand(key)
+	case strings.HasPrefix(text, "/api_token"):
+		h.handleAPITokenCommand(key, text)
+	case strings.HasPrefix(text, "/webhook"):
+		h.handleWebhookCommand(key, text)
+	case strings.HasPrefix(text, "/backup"):
+		h.handleBackupCommand(key, text)
+	case strings.HasPrefix(text, "/who"):
+		h.handleWhoCommand(key, text)
+	case strings.HasPrefix(text, "/calendar"):
+		h.handleCalendarCommand(key, text)
+	case strings.HasPrefix(text, "/search"):
+		h.handleSearchCommand(chatID, text)
+	case strings.HasPrefix(text, "/returned"):
+		h.handleReturnedCommand(key, text)
+	case text == "/parent":
+		h.handleGuardianStart(chatID)
 	case strings.HasPrefix(text, "/add_excel"):
 		h.sendError(chatID, "❌ Прикрепите Excel файл к команде /add_excel")
-	case h.userStates[chatID] == "waiting_activity_desc_input":
-		h.processActivityDescriptionInput(chatID, text) // ← новый обработчик
-	case h.userStates[chatID] == "waiting_leave_input":
-		h.processLeaveInput(chatID, text)
-	case h.userStates[chatID] == "waiting_unplanned_input":
-		h.processUnplannedInput(chatID, text)
-	case h.userStates[chatID] == "waiting_leave_time":
-		h.handleLeaveTimeInput(chatID, text)
-	case h.userStates[chatID] == "waiting_unplanned_details":
-		h.handleUnplannedDetailsInput(chatID, text)
+	case h.isFilteringSelection(key) && !strings.HasPrefix(text, "/"):
+		h.handleSelectionFilter(key, text)
+	case h.userStates[key] == "waiting_rename_input":
+		h.handleRenameInput(key, text)
+	case h.userStates[key] == "waiting_activity_desc_input":
+		h.processActivityDescriptionInput(key, text) // ← новый обработчик
+	case h.userStates[key] == "waiting_leave_input":
+		h.processLeaveInput(key, text)
+	case h.userStates[key] == "waiting_unplanned_input":
+		h.processUnplannedInput(key, text)
+	case h.userStates[key] == "waiting_leave_time":
+		h.handleLeaveTimeInput(key, text)
+	case h.userStates[key] == "waiting_unplanned_details":
+		h.handleUnplannedDetailsInput(key, text)
+	case isGroupChat(update.Message.Chat):
+		// В группе свободный текст не разбираем, чтобы не реагировать на обычную переписку
 	default:
-		h.handleFreeTextInput(chatID, text)
+		h.handleFreeTextInput(key, text)
 	}
 }
 
 func (h *BotHandler) HandleCallback(update tgbotapi.Update) {
 	callback := update.CallbackQuery
 	data := callback.Data
-	chatID := callback.Message.Chat.ID
+	key := newSessionKey(callback.Message.Chat, callback.From)
+
+	// В группах кнопки работают только в зарегистрированных чатах сотрудников, как и команды.
+	// Представителям кнопки сотрудников в личном чате недоступны (в группах сотрудников они работают как сотрудники)
+	if isGroupChat(callback.Message.Chat) {
+		if !h.isSupervisorChat(callback.Message.Chat.ID) {
+			return
+		}
+	} else if len(h.guardianFor(callback.From.ID)) > 0 {
+		return
+	}
+
+	// Кнопки выбора и подтверждения принадлежат сессии того, кто их вызвал: нажатие другого
+	// участника группы не должно ни удалить сообщение, ни продвинуть чужой или свой сценарий
+	if isSessionCallback(data) && !h.ownsKeyboard(callback.Message.Chat, key, callback.Message.MessageID) {
+		h.sendError(key.ChatID, "❌ Эти кнопки относятся к запросу другого сотрудника")
+		return
+	}
 
 	switch {
 	case strings.HasPrefix(data, "select_sub_"):
 		subID, _ := strconv.Atoi(strings.TrimPrefix(data, "select_sub_"))
-		h.handleSubordinateSelection(chatID, subID, callback.Message.MessageID)
-	case data == "confirm_yes" || data == "confirm_no":
-		h.handleConfirmation(chatID, data == "confirm_yes", callback.Message.MessageID)
+		h.handleSubordinateSelection(key, subID, callback.Message.MessageID)
+	case strings.HasPrefix(data, "confirm_yes") || strings.HasPrefix(data, "confirm_no"):
+		answer, action, _ := strings.Cut(data, ":")
+		h.handleConfirmation(key, action, answer == "confirm_yes", callback.Message.MessageID)
+	case strings.HasPrefix(data, "subpage_") || strings.HasPrefix(data, "subletter_") ||
+		data == "subsearch" || data == "subreset" || data == "subnoop":
+		h.handleSelectionCallback(key, data, callback.Message.MessageID)
+	case strings.HasPrefix(data, "who_"):
+		h.handleProfileCallback(key, data)
+	case strings.HasPrefix(data, "expect_"):
+		h.handleExpectCallback(callback)
+	case strings.HasPrefix(data, "returned_"):
+		h.handleReturnedCallback(callback)
+	case strings.HasPrefix(data, "ack_"):
+		h.handleAckCallback(callback)
+	case strings.HasPrefix(data, "curfewret_") || strings.HasPrefix(data, "curfewout_"):
+		h.handleCurfewCallback(callback)
+	case strings.HasPrefix(data, "curfewpage_"):
+		h.handleCurfewPageCallback(callback)
+	}
+}
+
+// isSessionCallback сообщает, что кнопка продолжает сценарий из сессии пользователя
+func isSessionCallback(data string) bool {
+	for _, prefix := range []string{"select_sub_", "confirm_yes", "confirm_no", "subpage_", "subletter_", "subsearch", "subreset"} {
+		if strings.HasPrefix(data, prefix) {
+			return true
+		}
 	}
+	return false
 }
 
 func (h *BotHandler) handleStart(chatID int64) {
@@ -107,7 +262,9 @@ func (h *BotHandler) handleStart(chatID int64) {
 	h.bot.Send(msg)
 }
 
-func (h *BotHandler) handleRecordLeave(chatID int64) {
+func (h *BotHandler) handleRecordLeave(key sessionKey) {
+	chatID := key.ChatID
+
 	// Получаем всех подчиненных
 	subordinates, err := h.db.GetAllSubordinates()
 	if err != nil {
@@ -124,19 +281,19 @@ func (h *BotHandler) handleRecordLeave(chatID int64) {
 	h.sortSubordinatesAlphabetically(subordinates)
 
 	// Сохраняем состояние
-	h.userStates[chatID] = "waiting_leave_selection"
-	h.userData[chatID] = map[string]interface{}{
+	h.userStates[key] = "waiting_leave_selection"
+	h.userData[key] = map[string]interface{}{
 		"action":   "record_leave",
 		"sub_list": subordinates,
 	}
 
 	// Отправляем сообщение с клавиатурой для выбора
-	msg := tgbotapi.NewMessage(chatID, "👥 Выберите подчиненного, который уходит:")
-	msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates)
-	h.bot.Send(msg)
+	h.sendSubordinateSelection(key, "👥 Выберите подчиненного, который уходит:")
 }
 
-func (h *BotHandler) handleUnplannedActivity(chatID int64) {
+func (h *BotHandler) handleUnplannedActivity(key sessionKey) {
+	chatID := key.ChatID
+
 	// Получаем всех подчиненных
 	subordinates, err := h.db.GetAllSubordinates()
 	if err != nil {
@@ -153,97 +310,97 @@ func (h *BotHandler) handleUnplannedActivity(chatID int64) {
 	h.sortSubordinatesAlphabetically(subordinates)
 
 	// Сохраняем состояние для ввода описания после выбора сотрудника
-	h.userStates[chatID] = "waiting_activity_description"
-	h.userData[chatID] = map[string]interface{}{
+	h.userStates[key] = "waiting_activity_description"
+	h.userData[key] = map[string]interface{}{
 		"action":   "unplanned_activity",
 		"sub_list": subordinates,
 	}
 
 	// Отправляем сообщение с клавиатурой для выбора сотрудника
-	msg := tgbotapi.NewMessage(chatID, "👥 Выберите сотрудника для внеплановой деятельности:")
-	msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates)
-	h.bot.Send(msg)
+	h.sendSubordinateSelection(key, "👥 Выберите сотрудника для внеплановой деятельности:")
 }
 
 func (h *BotHandler) handleWhereSubordinates(chatID int64) {
-	subordinates, err := h.db.GetAllSubordinates()
+	message, err := h.buildStatusMessage(time.Now())
 	if err != nil {
 		h.sendError(chatID, "❌ Ошибка получения данных: "+err.Error())
 		return
 	}
 
-	// Сортируем по алфавиту (фамилия, имя)
-	h.sortSubordinatesAlphabetically(subordinates)
-
-	leaves, err := h.db.GetTodayLeaves()
-	if err != nil {
-		log.Printf("Error getting today leaves: %v", err)
-		leaves = make(map[int]time.Time)
-	}
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👤 Карточка подчиненного", "who_pick"),
+		),
+	)
+	h.bot.Send(msg)
+}
 
-	activities, err := h.db.GetTodayUnplannedActivities()
+// buildStatusMessage формирует отчёт "Где подчинённые" за указанный день
+func (h *BotHandler) buildStatusMessage(date time.Time) (string, error) {
+	statuses, err := h.db.GetStatusesForDate(date)
 	if err != nil {
-		log.Printf("Error getting today activities: %v", err)
-		activities = make(map[int]database.UnplannedActivity)
+		return "", err
 	}
 
 	// Логируем для отладки
-	log.Printf("Total subordinates: %d", len(subordinates))
-	log.Printf("Leaves found: %d", len(leaves))
-	log.Printf("Activities found: %d", len(activities))
-
-	for id, leave := range leaves {
-		log.Printf("Leave - Subordinate ID: %d, Time: %s", id, leave.Format("15:04"))
-	}
+	log.Printf("Total subordinates: %d", len(statuses))
 
 	message := "📊 **Статус подчиненных на сегодня:**\n\n"
 
-	for _, sub := range subordinates {
-		status := "📍 На месте"
-
-		// СНАЧАЛА проверяем внеплановую деятельность (высший приоритет)
-		if activity, exists := activities[sub.ID]; exists {
-			// Обрезаем длинное описание
-			shortDescription := activity.Description
-			if len(shortDescription) > 50 {
-				shortDescription = shortDescription[:47] + "..."
-			}
-			status = fmt.Sprintf("📋 Внеплановая (%s) - %s",
-				activity.ActivityTime.Format("15:04"),
-				shortDescription)
-		} else if leaveTime, exists := leaves[sub.ID]; exists {
-			// ЗАТЕМ проверяем уход (низший приоритет)
-			status = fmt.Sprintf("🚪 Ушел в %s", leaveTime.Format("15:04"))
-		}
-
+	for _, item := range statuses {
 		message += fmt.Sprintf("**%s %s %s** - %s\n",
-			sub.LastName, sub.FirstName, sub.MiddleName, status)
+			item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName,
+			formatStatus(item))
 	}
 
-	// Добавляем статистику (исправляем подсчет)
-	total := len(subordinates)
+	// Статус определяется последней записью за день, поэтому каждый учитывается один раз
+	presentCount, leftCount, activityCount := database.CountStatuses(statuses)
 
-	// Учитываем, что один сотрудник может быть и в уходах и в деятельности
-	// Но при отображении мы показываем только деятельность (высший приоритет)
-	uniqueLeftCount := 0
-	uniqueActivityCount := 0
-
-	for _, sub := range subordinates {
-		if _, exists := activities[sub.ID]; exists {
-			uniqueActivityCount++
-		} else if _, exists := leaves[sub.ID]; exists {
-			uniqueLeftCount++
-		}
-	}
+	message += fmt.Sprintf("\n📈 **Статистика:** Всего: %d, На месте: %d, Ушли: %d, Внеплановая: %d",
+		len(statuses), presentCount, leftCount, activityCount)
 
-	presentCount := total - uniqueLeftCount - uniqueActivityCount
+	return message, nil
+}
 
-	message += fmt.Sprintf("\n📈 **Статистика:** Всего: %d, На месте: %d, Ушли: %d, Внеплановая: %d",
-		total, presentCount, uniqueLeftCount, uniqueActivityCount)
+// formatExpectedReturn возвращает пометку об ожидаемом возвращении для строки статуса
+func formatExpectedReturn(expected *time.Time) string {
+	if expected == nil {
+		return ""
+	}
+	if expected.Before(time.Now()) {
+		return fmt.Sprintf(" ⚠️ не вернулся к %s", expected.Format("15:04"))
+	}
+	return fmt.Sprintf(" (вернётся к %s)", expected.Format("15:04"))
+}
 
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = "Markdown"
-	h.bot.Send(msg)
+// formatStatus возвращает строку статуса подчиненного для отчётов
+func formatStatus(item database.SubordinateStatus) string {
+	switch item.Status {
+	case database.StatusActivity:
+		// Обрезаем длинное описание
+		shortDescription := item.Activity.Description
+		if len(shortDescription) > 50 {
+			shortDescription = shortDescription[:47] + "..."
+		}
+		returnNote := formatExpectedReturn(item.ExpectedReturn)
+		if item.StaysOut {
+			returnNote = " 🌙 остаётся вне"
+		}
+		return fmt.Sprintf("📋 Внеплановая (%s) - %s%s",
+			item.Activity.ActivityTime.Format("15:04"),
+			shortDescription, returnNote)
+	case database.StatusLeft:
+		if item.StaysOut {
+			return fmt.Sprintf("🚪 Ушел в %s 🌙 остаётся вне", item.LeaveTime.Format("15:04"))
+		}
+		return fmt.Sprintf("🚪 Ушел в %s%s", item.LeaveTime.Format("15:04"), formatExpectedReturn(item.ExpectedReturn))
+	case database.StatusReturned:
+		return fmt.Sprintf("🏠 Вернулся в %s", item.ReturnedAt.Format("15:04"))
+	default:
+		return "📍 На месте"
+	}
 }
 
 // Сортировка подчиненных по алфавиту
@@ -265,21 +422,30 @@ func (h *BotHandler) handleStatisticsMenu(chatID int64) {
 		"/stat сегодня - за сегодня\n"+
 		"/stat вчера - за вчера\n"+
 		"/stat ДД.ММ.ГГГГ - за конкретную дату\n"+
-		"/stat excel - выгрузка в Excel")
+		"/stat excel - выгрузка в Excel\n"+
+		"/stat график [неделя|месяц|ДД.ММ.ГГГГ-ДД.ММ.ГГГГ] - графики\n"+
+		"/report pdf [ДД.ММ.ГГГГ] - отчёт для печати в PDF")
 	h.bot.Send(msg)
 }
 
-func (h *BotHandler) handleStatisticsCommand(chatID int64, text string) {
+func (h *BotHandler) handleStatisticsCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	parts := strings.Fields(text)
 	if len(parts) < 2 {
-		h.sendError(chatID, "Укажите период: /stat сегодня|вчера|ДД.ММ.ГГГГ|excel")
+		h.sendError(chatID, "Укажите период: /stat сегодня|вчера|ДД.ММ.ГГГГ|excel|график")
 		return
 	}
 
 	period := parts[1]
 
 	if period == "excel" {
-		h.handleExcelExport(chatID)
+		h.handleExcelExport(key)
+		return
+	}
+
+	if period == "график" {
+		h.handleStatisticsCharts(chatID, strings.Join(parts[2:], " "))
 		return
 	}
 
@@ -308,32 +474,56 @@ func (h *BotHandler) handleStatisticsCommand(chatID int64, text string) {
 }
 
 func (h *BotHandler) showStatisticsForDate(chatID int64, date time.Time) {
-	leaves, err := h.db.GetLeavesByDate(date)
+	message, err := h.buildStatisticsMessage(date)
 	if err != nil {
 		h.sendError(chatID, "Ошибка получения статистики: "+err.Error())
 		return
 	}
 
+	msg := tgbotapi.NewMessage(chatID, message)
+	msg.ParseMode = "Markdown"
+	h.bot.Send(msg)
+}
+
+// buildStatisticsMessage формирует статистику за указанный день: все уходы и вся внеплановая
+// деятельность в порядке времени
+func (h *BotHandler) buildStatisticsMessage(date time.Time) (string, error) {
+	absences, err := h.db.GetAbsencesBetween(date, date, 0, "")
+	if err != nil {
+		return "", err
+	}
+
 	message := fmt.Sprintf("📈 **Статистика за %s:**\n\n", date.Format("02.01.2006"))
 
-	if len(leaves) == 0 {
-		message += "Нет данных об уходах за этот день."
+	if len(absences) == 0 {
+		message += "Нет данных об уходах и внеплановой деятельности за этот день."
 	} else {
-		for _, item := range leaves {
-			message += fmt.Sprintf("**%s %s %s** - ушел в %s\n",
-				item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName,
-				item.LeaveTime.Format("15:04"))
+		for _, item := range absences {
+			event := "ушел в " + item.Start.Format("15:04")
+			if item.Kind == database.KindActivity {
+				event = fmt.Sprintf("внеплановая деятельность в %s: %s",
+					item.Start.Format("15:04"), truncateString(item.Description, 50))
+			}
+			message += fmt.Sprintf("**%s %s %s** - %s\n",
+				item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName, event)
 		}
 	}
 
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = "Markdown"
-	h.bot.Send(msg)
+	return message, nil
 }
 
-func (h *BotHandler) processLeaveInput(chatID int64, text string) {
+func (h *BotHandler) processLeaveInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Убираем состояние
-	delete(h.userStates, chatID)
+	delete(h.userStates, key)
+
+	// Ожидаемое время возвращения "до ЧЧ:ММ" вырезаем до разбора времени ухода
+	text, returnClock, hasReturn, err := utils.ExtractReturnTime(text)
+	if err != nil {
+		h.sendError(chatID, "❌ "+err.Error())
+		return
+	}
 
 	// Проверяем наличие "сейчас" или времени
 	hasTime := utils.ContainsTime(text)
@@ -346,7 +536,6 @@ func (h *BotHandler) processLeaveInput(chatID int64, text string) {
 
 	// Определяем время ухода
 	var leaveTime time.Time
-	var err error
 
 	if hasNow {
 		leaveTime = time.Now()
@@ -374,8 +563,14 @@ func (h *BotHandler) processLeaveInput(chatID int64, text string) {
 
 	log.Printf("Processing leave: '%s' at %s", cleanText, leaveTime.Format("15:04"))
 
+	var expectedReturn *time.Time
+	if hasReturn {
+		expected := returnAfter(leaveTime, returnClock)
+		expectedReturn = &expected
+	}
+
 	// Ищем сотрудника (одинаковая логика для "сейчас" и времени)
-	subordinates, err := h.findExactSubordinate(cleanText, "")
+	subordinates, exact, err := h.findExactSubordinate(cleanText, "")
 	if err != nil {
 		h.sendError(chatID, "❌ Ошибка поиска подчиненных: "+err.Error())
 		return
@@ -386,28 +581,33 @@ func (h *BotHandler) processLeaveInput(chatID int64, text string) {
 		return
 	}
 
-	if len(subordinates) == 1 {
+	action := "leave_time"
+	if hasNow {
+		action = "leave_now"
+	}
+	data := map[string]interface{}{
+		"action":          action,
+		"sub_list":        subordinates,
+		"leave_time":      leaveTime,
+		"expected_return": expectedReturn,
+	}
+
+	if len(subordinates) == 1 && exact {
 		// Если один подчиненный - сразу фиксируем
-		h.recordLeave(chatID, subordinates[0].ID, leaveTime)
+		h.recordLeave(key, subordinates[0].ID, leaveTime, expectedReturn, false)
+	} else if len(subordinates) == 1 {
+		h.confirmMatch(key, subordinates[0], data)
 	} else {
 		// Если несколько - сохраняем время и предлагаем выбрать
-		action := "leave_time"
-		if hasNow {
-			action = "leave_now"
-		}
-
-		h.userData[chatID] = map[string]interface{}{
-			"action":     action,
-			"sub_list":   subordinates,
-			"leave_time": leaveTime,
-		}
-		msg := tgbotapi.NewMessage(chatID, "Найдено несколько сотрудников. Выберите нужного:")
-		msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates)
-		h.bot.Send(msg)
+		h.userData[key] = data
+		h.userStates[key] = "waiting_match_selection"
+		h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
 	}
 }
 
-func (h *BotHandler) processLeaveNow(chatID int64, text string) {
+func (h *BotHandler) processLeaveNow(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Убираем "сейчас" из текста, НЕ переводя весь текст в нижний регистр
 	cleanText := strings.ReplaceAll(text, "сейчас", "")
 	cleanText = strings.ReplaceAll(cleanText, "Сейчас", "") // на случай заглавной
@@ -421,7 +621,7 @@ func (h *BotHandler) processLeaveNow(chatID int64, text string) {
 	log.Printf("Searching for subordinate with: '%s'", cleanText)
 
 	// Ищем точное совпадение (регистр уже правильный)
-	subordinates, err := h.findExactSubordinate(cleanText, "")
+	subordinates, exact, err := h.findExactSubordinate(cleanText, "")
 	if err != nil {
 		h.sendError(chatID, "❌ Ошибка поиска подчиненных: "+err.Error())
 		return
@@ -441,36 +641,60 @@ func (h *BotHandler) processLeaveNow(chatID int64, text string) {
 		return
 	}
 
-	if len(subordinates) == 1 {
+	data := map[string]interface{}{
+		"action":     "leave_now",
+		"sub_list":   subordinates,
+		"leave_time": time.Now(),
+	}
+
+	if len(subordinates) == 1 && exact {
 		// Если один подчиненный - сразу фиксируем
-		h.recordLeave(chatID, subordinates[0].ID, time.Now())
+		h.recordLeave(key, subordinates[0].ID, time.Now(), nil, false)
+	} else if len(subordinates) == 1 {
+		h.confirmMatch(key, subordinates[0], data)
 	} else {
 		// Если несколько - предлагаем выбрать
-		h.userData[chatID] = map[string]interface{}{
-			"action":   "leave_now",
-			"sub_list": subordinates,
-		}
-		msg := tgbotapi.NewMessage(chatID, "Найдено несколько сотрудников. Выберите нужного:")
-		msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates)
-		h.bot.Send(msg)
+		h.userData[key] = data
+		h.userStates[key] = "waiting_match_selection"
+		h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
 	}
 }
-func (h *BotHandler) findExactSubordinate(searchTerm1, searchTerm2 string) ([]database.Subordinate, error) {
+
+// findExactSubordinate возвращает лучшие совпадения с запросом и признак того, что они точные.
+// Неточное единственное совпадение (опечатка, начало фамилии, латиница) перед записью
+// нужно подтвердить - см. confirmMatch
+func (h *BotHandler) findExactSubordinate(searchTerm1, searchTerm2 string) ([]database.Subordinate, bool, error) {
 	// Если оба термина пустые
 	if searchTerm1 == "" && searchTerm2 == "" {
-		return nil, fmt.Errorf("не указаны данные для поиска")
+		return nil, false, fmt.Errorf("не указаны данные для поиска")
 	}
 
-	// Если только один термин - используем улучшенный поиск
-	if searchTerm2 == "" {
-		return h.db.FindSubordinatesBySingleTerm(searchTerm1)
+	// Нечёткий поиск с учётом опечаток, латиницы и инициалов; если лучших совпадений
+	// несколько, пользователь выберет нужного
+	matches, err := h.db.SearchSubordinates(strings.TrimSpace(searchTerm1 + " " + searchTerm2))
+	if err != nil || len(matches) == 0 {
+		return nil, false, err
 	}
+	return database.BestMatches(matches), matches[0].Exact, nil
+}
 
-	// Если два термина - первый считаем фамилией, второй именем
-	return h.db.FindSubordinatesByFullName(searchTerm1, searchTerm2)
+// confirmMatch спрашивает, того ли подчиненного нашёл неточный поиск, прежде чем выполнить действие.
+// data - данные действия в том же виде, что и при выборе из списка (см. recordSelectedMatch)
+func (h *BotHandler) confirmMatch(key sessionKey, sub database.Subordinate, data map[string]interface{}) {
+	data["match_action"] = data["action"]
+	data["action"] = "confirm_match"
+	data["subordinate_id"] = sub.ID
+	h.userData[key] = data
+
+	name := strings.TrimSpace(sub.LastName + " " + sub.FirstName + " " + sub.MiddleName)
+	msg := tgbotapi.NewMessage(key.ChatID, fmt.Sprintf("🔎 Точного совпадения нет. Вы имели в виду %s?", name))
+	msg.ReplyMarkup = CreateConfirmationKeyboard("confirm_match")
+	h.sendSessionKeyboard(key, msg)
 }
 
-func (h *BotHandler) processLeaveWithTime(chatID int64, text string) {
+func (h *BotHandler) processLeaveWithTime(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Парсим время
 	leaveTime, err := utils.ParseTime(text)
 	if err != nil {
@@ -489,7 +713,7 @@ func (h *BotHandler) processLeaveWithTime(chatID int64, text string) {
 	}
 
 	// Ищем точное совпадение
-	subordinates, err := h.findExactSubordinate(cleanText, "")
+	subordinates, exact, err := h.findExactSubordinate(cleanText, "")
 	if err != nil {
 		h.sendError(chatID, "❌ Ошибка поиска подчиненных: "+err.Error())
 		return
@@ -500,25 +724,37 @@ func (h *BotHandler) processLeaveWithTime(chatID int64, text string) {
 		return
 	}
 
-	if len(subordinates) == 1 {
+	data := map[string]interface{}{
+		"action":     "leave_time",
+		"sub_list":   subordinates,
+		"leave_time": leaveTime,
+	}
+
+	if len(subordinates) == 1 && exact {
 		// Если один подчиненный - сразу фиксируем
-		h.recordLeave(chatID, subordinates[0].ID, leaveTime)
+		h.recordLeave(key, subordinates[0].ID, leaveTime, nil, false)
+	} else if len(subordinates) == 1 {
+		h.confirmMatch(key, subordinates[0], data)
 	} else {
 		// Если несколько - сохраняем время и предлагаем выбрать
-		h.userData[chatID] = map[string]interface{}{
-			"action":     "leave_time",
-			"sub_list":   subordinates,
-			"leave_time": leaveTime,
-		}
-		msg := tgbotapi.NewMessage(chatID, "Найдено несколько сотрудников. Выберите нужного:")
-		msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates)
-		h.bot.Send(msg)
+		h.userData[key] = data
+		h.userStates[key] = "waiting_match_selection"
+		h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
 	}
 }
 
-func (h *BotHandler) processUnplannedInput(chatID int64, text string) {
+func (h *BotHandler) processUnplannedInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Убираем состояние
-	delete(h.userStates, chatID)
+	delete(h.userStates, key)
+
+	// Ожидаемое время возвращения "до ЧЧ:ММ" вырезаем до разбора времени деятельности
+	text, returnClock, hasReturn, err := utils.ExtractReturnTime(text)
+	if err != nil {
+		h.sendError(chatID, err.Error())
+		return
+	}
 
 	// Проверяем наличие времени или "сейчас"
 	hasTime := utils.ContainsTime(text)
@@ -531,9 +767,16 @@ func (h *BotHandler) processUnplannedInput(chatID int64, text string) {
 
 	// Определяем время деятельности
 	var activityTime time.Time
-	var err error
 	var searchText string
 
+	expectedReturnAfter := func(eventTime time.Time) *time.Time {
+		if !hasReturn {
+			return nil
+		}
+		expected := returnAfter(eventTime, returnClock)
+		return &expected
+	}
+
 	if hasNow {
 		activityTime = time.Now()
 		// Находим позицию "сейчас" в тексте
@@ -557,13 +800,8 @@ func (h *BotHandler) processUnplannedInput(chatID int64, text string) {
 			return
 		}
 
-		// Проверяем длину описания
-		if len(description) > 1000 {
-			description = description[:1000]
-		}
-
 		// Ищем подчиненных и фиксируем деятельность
-		h.processUnplannedActivity(chatID, searchText, activityTime, description)
+		h.processUnplannedActivity(key, searchText, activityTime, description, expectedReturnAfter(activityTime))
 		return
 
 	} else {
@@ -596,28 +834,23 @@ func (h *BotHandler) processUnplannedInput(chatID int64, text string) {
 			return
 		}
 
-		// Проверяем длину описания
-		if len(description) > 1000 {
-			description = description[:1000]
-		}
-
 		// Ищем подчиненных и фиксируем деятельность
-		h.processUnplannedActivity(chatID, searchText, activityTime, description)
+		h.processUnplannedActivity(key, searchText, activityTime, description, expectedReturnAfter(activityTime))
 		return
 	}
 }
-func (h *BotHandler) processUnplannedActivity(chatID int64, searchText string, activityTime time.Time, description string) {
-	// Извлекаем только фамилию (первое слово)
-	parts := strings.Fields(searchText)
-	if len(parts) == 0 {
+func (h *BotHandler) processUnplannedActivity(key sessionKey, searchText string, activityTime time.Time, description string, expectedReturn *time.Time) {
+	chatID := key.ChatID
+
+	// Фамилия, при необходимости с именем или инициалами
+	lastName := strings.TrimSpace(searchText)
+	if lastName == "" {
 		h.sendError(chatID, "Укажите фамилию сотрудника")
 		return
 	}
 
-	lastName := parts[0]
-
-	// Ищем подчиненных по ТОЧНОЙ фамилии
-	subordinates, err := h.findExactSubordinate(lastName, "")
+	// Ищем подчиненных с ранжированием по точности совпадения
+	subordinates, exact, err := h.findExactSubordinate(lastName, "")
 	if err != nil {
 		h.sendError(chatID, "Ошибка поиска подчиненных: "+err.Error())
 		return
@@ -637,196 +870,315 @@ func (h *BotHandler) processUnplannedActivity(chatID int64, searchText string, a
 		return
 	}
 
-	if len(subordinates) == 1 {
+	data := map[string]interface{}{
+		"action":          "unplanned",
+		"sub_list":        subordinates,
+		"activity_time":   activityTime,
+		"description":     description,
+		"expected_return": expectedReturn,
+	}
+
+	if len(subordinates) == 1 && exact {
 		// Если один подчиненный - сразу фиксируем
-		h.recordUnplannedActivity(chatID, subordinates[0].ID, activityTime, description)
+		h.recordUnplannedActivity(key, subordinates[0].ID, activityTime, description, expectedReturn, false)
+	} else if len(subordinates) == 1 {
+		h.confirmMatch(key, subordinates[0], data)
 	} else {
 		// Если несколько - сохраняем данные и предлагаем выбрать
-		h.userData[chatID] = map[string]interface{}{
-			"action":        "unplanned",
-			"sub_list":      subordinates,
-			"activity_time": activityTime,
-			"description":   description,
-		}
-		msg := tgbotapi.NewMessage(chatID, "Найдено несколько сотрудников. Выберите нужного:")
-		msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates)
-		h.bot.Send(msg)
+		h.userData[key] = data
+		h.userStates[key] = "waiting_match_selection"
+		h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
 	}
 }
-func (h *BotHandler) recordLeave(chatID int64, subordinateID int, leaveTime time.Time) {
+
+// recordLeave фиксирует уход. Если у подчиненного уже есть уход на это время, а replace не задан,
+// спрашивает, заменить ли его (подтверждение приходит в handleConfirmation)
+func (h *BotHandler) recordLeave(key sessionKey, subordinateID int, leaveTime time.Time, expectedReturn *time.Time, replace bool) {
+	chatID := key.ChatID
 	log.Printf("Recording leave for subordinate %d at %s", subordinateID, leaveTime.Format("15:04"))
 
-	// УДАЛЯЕМ КОНФЛИКТУЮЩИЕ ЗАПИСИ
-	err := h.db.RemoveConflictingRecords(subordinateID, leaveTime)
-	if err != nil {
-		log.Printf("Error removing conflicting records: %v", err)
-		h.sendError(chatID, "❌ Ошибка обработки записи: "+err.Error())
+	// Проверка и правила конфликтов общие с API: повторный уход на то же время заменяет прежний
+	leave, err := h.db.RecordLeave(subordinateID, leaveTime, replace)
+	var conflict *database.ConflictError
+	if errors.As(err, &conflict) {
+		h.userData[key] = map[string]interface{}{
+			"action":          "confirm_leave",
+			"subordinate_id":  subordinateID,
+			"leave_time":      leaveTime,
+			"expected_return": expectedReturn,
+		}
+		h.askToReplace(key, "confirm_leave", subordinateID, conflict, "уходом в "+leaveTime.Format("15:04"))
 		return
 	}
-
-	// Добавляем новую запись ухода
-	err = h.db.AddLeave(subordinateID, leaveTime)
 	if err != nil {
-		log.Printf("Error adding leave: %v", err)
-		h.sendError(chatID, "❌ Ошибка записи ухода: "+err.Error())
+		log.Printf("Error recording leave: %v", err)
+		h.sendError(chatID, "❌ Ошибка записи ухода: "+recordErrorText(err))
 		return
 	}
 
+	// Отслеживание возвращения
+	returnNote, keyboard := h.trackReturn(chatID, database.KindLeave, leave.ID, leaveTime, expectedReturn)
+
 	sub, _ := h.db.GetSubordinateByID(subordinateID)
 	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
-		"✅ %s %s ушёл в %s (МСК)",
-		sub.LastName, sub.FirstName, leaveTime.Format("15:04")))
+		"✅ %s %s ушёл в %s (МСК)%s",
+		sub.LastName, sub.FirstName, leaveTime.Format("15:04"), returnNote))
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
 	h.bot.Send(msg)
+
+	h.notifyGuardians(subordinateID, fmt.Sprintf("%s %s: зафиксирован уход в %s",
+		sub.LastName, sub.FirstName, leaveTime.Format("15:04")))
 }
 
-func (h *BotHandler) recordUnplannedActivity(chatID int64, subordinateID int, activityTime time.Time, description string) {
-	// УДАЛЯЕМ КОНФЛИКТУЮЩИЕ ЗАПИСИ
-	err := h.db.RemoveConflictingRecords(subordinateID, activityTime)
-	if err != nil {
-		h.sendError(chatID, "Ошибка обработки записи: "+err.Error())
+// recordUnplannedActivity фиксирует внеплановую деятельность; конфликты - как в recordLeave
+func (h *BotHandler) recordUnplannedActivity(key sessionKey, subordinateID int, activityTime time.Time, description string, expectedReturn *time.Time, replace bool) {
+	chatID := key.ChatID
+
+	// Проверка и правила конфликтов общие с API
+	activity, err := h.db.RecordActivity(subordinateID, activityTime, description, replace)
+	var conflict *database.ConflictError
+	if errors.As(err, &conflict) {
+		h.userData[key] = map[string]interface{}{
+			"action":          "confirm_unplanned",
+			"subordinate_id":  subordinateID,
+			"activity_time":   activityTime,
+			"description":     description,
+			"expected_return": expectedReturn,
+		}
+		h.askToReplace(key, "confirm_unplanned", subordinateID, conflict, "деятельностью в "+activityTime.Format("15:04"))
 		return
 	}
-
-	// Добавляем новую запись деятельности
-	err = h.db.AddUnplannedActivity(subordinateID, activityTime, description)
 	if err != nil {
-		h.sendError(chatID, "Ошибка записи деятельности: "+err.Error())
+		h.sendError(chatID, "Ошибка записи деятельности: "+recordErrorText(err))
 		return
 	}
+	description = activity.Description
+
+	// Отслеживание возвращения
+	returnNote, keyboard := h.trackReturn(chatID, database.KindActivity, activity.ID, activityTime, expectedReturn)
 
 	sub, _ := h.db.GetSubordinateByID(subordinateID)
 	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
-		"✅ Для %s %s зафиксирована деятельность в %s: %s",
-		sub.LastName, sub.FirstName, activityTime.Format("15:04"), description))
+		"✅ Для %s %s зафиксирована деятельность в %s: %s%s",
+		sub.LastName, sub.FirstName, activityTime.Format("15:04"), description, returnNote))
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
 	h.bot.Send(msg)
-}
 
-func (h *BotHandler) getExistingLeaveToday(subordinateID int) (time.Time, error) {
-	leaves, err := h.db.GetTodayLeaves()
-	if err != nil {
-		return time.Time{}, err
-	}
+	h.notifyGuardians(subordinateID, fmt.Sprintf("%s %s: внеплановая деятельность в %s - %s",
+		sub.LastName, sub.FirstName, activityTime.Format("15:04"), description))
+}
 
-	if leaveTime, exists := leaves[subordinateID]; exists {
-		return leaveTime, nil
+// askToReplace сообщает о записи, которая уже есть у подчиненного на это время, и предлагает её заменить.
+// action - действие, сохранённое в сессии для кнопки "Да"
+func (h *BotHandler) askToReplace(key sessionKey, action string, subordinateID int, conflict *database.ConflictError, replacement string) {
+	existing := "уход в " + conflict.Time.Format("15:04")
+	if conflict.Kind == database.KindActivity {
+		existing = fmt.Sprintf("внеплановая деятельность в %s (%s)",
+			conflict.Time.Format("15:04"), truncateString(conflict.Description, 100))
 	}
 
-	return time.Time{}, nil
+	sub, _ := h.db.GetSubordinateByID(subordinateID)
+	msg := tgbotapi.NewMessage(key.ChatID, fmt.Sprintf("⚠️ %s %s: уже есть %s — заменить %s?",
+		sub.LastName, sub.FirstName, existing, replacement))
+	msg.ReplyMarkup = CreateConfirmationKeyboard(action)
+	h.sendSessionKeyboard(key, msg)
 }
 
-func (h *BotHandler) getExistingActivityToday(subordinateID int) (*database.UnplannedActivity, error) {
-	activities, err := h.db.GetTodayUnplannedActivities()
-	if err != nil {
-		return nil, err
-	}
-
-	if activity, exists := activities[subordinateID]; exists {
-		return &activity, nil
+// recordErrorText переводит ошибки проверки записи в понятный пользователю текст
+func recordErrorText(err error) string {
+	switch err {
+	case database.ErrSubordinateNotFound:
+		return "сотрудник не найден"
+	case database.ErrSubordinateArchived:
+		return "сотрудник в архиве"
+	case database.ErrEmptyDescription:
+		return "описание не может быть пустым"
+	case database.ErrInvalidName:
+		return "укажите фамилию и имя"
+	case database.ErrSubordinateExists:
+		return "сотрудник с таким ФИО уже есть"
+	default:
+		return err.Error()
 	}
-
-	return nil, nil
 }
 
-func (h *BotHandler) handleSubordinateSelection(chatID int64, subID int, messageID int) {
-	// Удаляем сообщение с кнопками
-	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
-	h.bot.Send(deleteMsg)
+func (h *BotHandler) handleSubordinateSelection(key sessionKey, subID int, messageID int) {
+	chatID := key.ChatID
 
-	// Проверяем состояние пользователя
-	userState, exists := h.userStates[chatID]
-	// Убираем лишнюю переменную userData, так как она не используется в этом коде
-	_, dataExists := h.userData[chatID]
+	// Проверяем состояние пользователя до удаления кнопок: без сессии сообщение остаётся как было
+	userState, exists := h.userStates[key]
+	_, dataExists := h.userData[key]
 
 	if !exists || !dataExists {
 		h.sendError(chatID, "❌ Данные сессии устарели")
 		return
 	}
 
+	// Удаляем сообщение с кнопками
+	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
+	h.bot.Send(deleteMsg)
+
 	// Получаем московское время
 	mskTime := utils.GetMoscowTime()
 
 	switch userState {
 	case "waiting_leave_selection":
-		// Для ухода - сразу фиксируем
-		h.recordLeave(chatID, subID, mskTime)
-		delete(h.userStates, chatID)
-		delete(h.userData, chatID)
+		// Для ухода - сразу фиксируем. Сессию очищаем до записи: при конфликте
+		// в ней сохраняется запрос на замену
+		delete(h.userStates, key)
+		delete(h.userData, key)
+		h.recordLeave(key, subID, mskTime, nil, false)
 
 	case "waiting_activity_description":
 		// Для внеплановой деятельности - запрашиваем описание
-		h.userData[chatID] = map[string]interface{}{
+		h.userData[key] = map[string]interface{}{
 			"subordinate_id": subID,
 			"activity_time":  mskTime,
 		}
-		h.userStates[chatID] = "waiting_activity_desc_input"
+		h.userStates[key] = "waiting_activity_desc_input"
 
 		msg := tgbotapi.NewMessage(chatID, "📝 Введите описание внеплановой деятельности:")
+		// Ответ на сообщение бота доходит до него и в группе с включённым режимом приватности
+		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
 		h.bot.Send(msg)
 
+	case "waiting_rename_selection", "waiting_archive_selection", "waiting_restore_selection",
+		"waiting_merge_keep", "waiting_merge_duplicate":
+		h.handleRosterSelection(key, userState, subID)
+
+	case "waiting_profile_selection":
+		h.sendProfile(key, subID)
+		delete(h.userStates, key)
+		delete(h.userData, key)
+
+	case "waiting_calendar_selection":
+		data := h.userData[key]
+		delete(h.userStates, key)
+		delete(h.userData, key)
+		from, hasFrom := data["from"].(time.Time)
+		to, hasTo := data["to"].(time.Time)
+		if !hasFrom || !hasTo {
+			h.sendError(chatID, "Данные сессии устарели")
+			return
+		}
+		h.sendSubordinateCalendar(chatID, subID, from, to)
+
+	case "waiting_match_selection":
+		// Выбор из нескольких найденных по тексту - данные записи сохранены при поиске
+		userData := h.userData[key]
+		delete(h.userStates, key)
+		delete(h.userData, key)
+		h.recordSelectedMatch(key, subID, userData)
+
 	default:
 		h.sendError(chatID, "❌ Неизвестное состояние")
-		delete(h.userStates, chatID)
-		delete(h.userData, chatID)
+		delete(h.userStates, key)
+		delete(h.userData, key)
 	}
 }
 
-func (h *BotHandler) handleConfirmation(chatID int64, confirmed bool, messageID int) {
-	// Удаляем сообщение с кнопками
-	deleteMsg := tgbotapi.NewDeleteMessage(chatID, messageID)
-	h.bot.Send(deleteMsg)
+// recordSelectedMatch фиксирует уход или деятельность для подчиненного, выбранного из нескольких найденных
+func (h *BotHandler) recordSelectedMatch(key sessionKey, subID int, userData map[string]interface{}) {
+	expectedReturn, _ := userData["expected_return"].(*time.Time)
 
-	if !confirmed {
-		msg := tgbotapi.NewMessage(chatID, "❌ Действие отменено")
-		h.bot.Send(msg)
-		delete(h.userData, chatID)
-		return
+	switch userData["action"] {
+	case "leave_time", "leave_now":
+		leaveTime, ok := userData["leave_time"].(time.Time)
+		if !ok {
+			leaveTime = time.Now()
+		}
+		h.recordLeave(key, subID, leaveTime, expectedReturn, false)
+	case "unplanned":
+		activityTime, _ := userData["activity_time"].(time.Time)
+		description, _ := userData["description"].(string)
+		h.recordUnplannedActivity(key, subID, activityTime, description, expectedReturn, false)
+	default:
+		h.sendError(key.ChatID, "❌ Неизвестное действие")
 	}
+}
 
-	userData, exists := h.userData[chatID]
-	if !exists {
+// handleConfirmation выполняет или отменяет действие, о котором спросили кнопками CreateConfirmationKeyboard.
+// Кнопка действует, только если сессия всё ещё ждёт ответа на тот же вопрос: после другой команды
+// старая кнопка "Да" не должна подтвердить чужое действие
+func (h *BotHandler) handleConfirmation(key sessionKey, action string, confirmed bool, messageID int) {
+	chatID := key.ChatID
+
+	userData := h.userData[key]
+	current, _ := userData["action"].(string)
+	subordinateID, ok := userData["subordinate_id"].(int)
+	if action == "" || current != action || !ok {
 		h.sendError(chatID, "Данные сессии устарели")
 		return
 	}
 
-	action := userData["action"].(string)
-	subordinateID := userData["subordinate_id"].(int)
+	// Удаляем сообщение с кнопками. Данные сессии удаляем до записи: при совпадении
+	// по времени запись сама спросит о замене и сохранит свои данные
+	h.bot.Send(tgbotapi.NewDeleteMessage(chatID, messageID))
+	delete(h.userData, key)
 
+	if !confirmed {
+		h.bot.Send(tgbotapi.NewMessage(chatID, "❌ Действие отменено"))
+		return
+	}
+
+	expectedReturn, _ := userData["expected_return"].(*time.Time)
 	switch action {
 	case "confirm_leave":
-		leaveTime := userData["leave_time"].(time.Time)
-		err := h.db.AddLeave(subordinateID, leaveTime)
-		if err != nil {
-			h.sendError(chatID, "Ошибка обновления ухода: "+err.Error())
+		// Замена записи за день, о которой спросил recordLeave
+		if leaveTime, ok := userData["leave_time"].(time.Time); ok {
+			h.recordLeave(key, subordinateID, leaveTime, expectedReturn, true)
 			return
 		}
-		sub, _ := h.db.GetSubordinateByID(subordinateID)
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
-			"✅ Уход для %s %s обновлен на %s",
-			sub.LastName, sub.FirstName, leaveTime.Format("15:04")))
-		h.bot.Send(msg)
 
 	case "confirm_unplanned":
-		activityTime := userData["activity_time"].(time.Time)
-		description := userData["description"].(string)
-		err := h.db.AddUnplannedActivity(subordinateID, activityTime, description)
-		if err != nil {
-			h.sendError(chatID, "Ошибка обновления деятельности: "+err.Error())
+		activityTime, ok := userData["activity_time"].(time.Time)
+		description, hasDescription := userData["description"].(string)
+		if ok && hasDescription {
+			h.recordUnplannedActivity(key, subordinateID, activityTime, description, expectedReturn, true)
+			return
+		}
+
+	case "confirm_merge":
+		if mergeID, ok := userData["merge_id"].(int); ok {
+			h.mergeSubordinates(chatID, subordinateID, mergeID)
+			return
+		}
+
+	case "confirm_match":
+		// Подчиненный, найденный неточным поиском, подтверждён
+		userData["action"] = userData["match_action"]
+		switch userData["action"] {
+		case "calendar":
+			from, hasFrom := userData["from"].(time.Time)
+			to, hasTo := userData["to"].(time.Time)
+			if hasFrom && hasTo {
+				h.sendSubordinateCalendar(chatID, subordinateID, from, to)
+				return
+			}
+		case "returned":
+			if returnedAt, ok := userData["returned_at"].(time.Time); ok {
+				h.markReturned(chatID, subordinateID, returnedAt)
+				return
+			}
+		default:
+			h.recordSelectedMatch(key, subordinateID, userData)
 			return
 		}
-		sub, _ := h.db.GetSubordinateByID(subordinateID)
-		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
-			"✅ Деятельность для %s %s обновлена: %s - %s",
-			sub.LastName, sub.FirstName, activityTime.Format("15:04"), description))
-		h.bot.Send(msg)
 	}
 
-	delete(h.userData, chatID)
+	h.sendError(chatID, "Данные сессии устарели")
 }
 
-func (h *BotHandler) handleFreeTextInput(chatID int64, text string) {
+func (h *BotHandler) handleFreeTextInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Автоматическое определение типа команды
 	if strings.Contains(text, "сейчас") || utils.ContainsTime(text) {
-		h.processLeaveInput(chatID, text)
+		h.processLeaveInput(key, text)
 	} else {
 		msg := tgbotapi.NewMessage(chatID, "Не понимаю команду. Используйте кнопки или стандартные форматы.")
 		h.bot.Send(msg)
@@ -839,22 +1191,42 @@ func (h *BotHandler) sendError(chatID int64, message string) {
 }
 
 func truncateString(s string, maxLength int) string {
-	if len(s) <= maxLength {
+	runes := []rune(s)
+	if len(runes) <= maxLength {
 		return s
 	}
-	return s[:maxLength-3] + "..."
+	return string(runes[:maxLength-3]) + "..."
 }
-func (h *BotHandler) isAdmin(chatID int64) bool {
-	return h.config.IsAdmin(chatID)
+func (h *BotHandler) isAdmin(userID int64) bool {
+	return h.config.IsAdmin(userID)
 }
 
-func (h *BotHandler) checkAdmin(chatID int64) bool {
-	if !h.isAdmin(chatID) {
-		h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
+func (h *BotHandler) checkAdmin(key sessionKey) bool {
+	if !h.isAdmin(key.UserID) {
+		h.sendError(key.ChatID, "❌ У вас нет прав для выполнения этой команды")
 		return false
 	}
 	return true
 }
+
+// normalizeCommand убирает из команды упоминание бота: "/stat@MyBot сегодня" -> "/stat сегодня".
+// Возвращает false, если команда адресована другому боту
+func (h *BotHandler) normalizeCommand(text string) (string, bool) {
+	if !strings.HasPrefix(text, "/") {
+		return text, true
+	}
+
+	command, args, hasArgs := strings.Cut(text, " ")
+	command, mention, hasMention := strings.Cut(command, "@")
+	if hasMention && !strings.EqualFold(mention, h.botUserName) {
+		return "", false
+	}
+
+	if hasArgs {
+		return command + " " + args, true
+	}
+	return command, true
+}
 func normalizeSearchTerm(term string) string {
 	if term == "" {
 		return ""
@@ -870,17 +1242,19 @@ func normalizeSearchTerm(term string) string {
 
 	return lower
 }
-func (h *BotHandler) processActivityDescriptionInput(chatID int64, text string) {
+func (h *BotHandler) processActivityDescriptionInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
 	// Проверяем состояние
-	if h.userStates[chatID] != "waiting_activity_desc_input" {
+	if h.userStates[key] != "waiting_activity_desc_input" {
 		h.sendError(chatID, "❌ Неверное состояние")
 		return
 	}
 
-	userData, exists := h.userData[chatID]
+	userData, exists := h.userData[key]
 	if !exists {
 		h.sendError(chatID, "❌ Данные сессии устарели")
-		delete(h.userStates, chatID)
+		delete(h.userStates, key)
 		return
 	}
 
@@ -889,11 +1263,24 @@ func (h *BotHandler) processActivityDescriptionInput(chatID int64, text string)
 
 	if !ok1 || !ok2 {
 		h.sendError(chatID, "❌ Ошибка данных сессии")
-		delete(h.userStates, chatID)
-		delete(h.userData, chatID)
+		delete(h.userStates, key)
+		delete(h.userData, key)
 		return
 	}
 
+	// Ожидаемое время возвращения можно указать в описании: "олимпиада до 15:00"
+	text, returnClock, hasReturn, err := utils.ExtractReturnTime(text)
+	if err != nil {
+		h.sendError(chatID, "❌ "+err.Error())
+		return
+	}
+
+	var expectedReturn *time.Time
+	if hasReturn {
+		expected := returnAfter(activityTime, returnClock)
+		expectedReturn = &expected
+	}
+
 	// Проверяем, что описание не пустое
 	description := strings.TrimSpace(text)
 	if description == "" {
@@ -901,15 +1288,10 @@ func (h *BotHandler) processActivityDescriptionInput(chatID int64, text string)
 		return
 	}
 
-	// Ограничиваем длину описания
-	if len(description) > 1000 {
-		description = description[:1000]
-	}
+	// Очищаем состояние до записи: при конфликте в сессии сохраняется запрос на замену
+	delete(h.userStates, key)
+	delete(h.userData, key)
 
 	// Фиксируем внеплановую деятельность
-	h.recordUnplannedActivity(chatID, subordinateID, activityTime, description)
-
-	// Очищаем состояние
-	delete(h.userStates, chatID)
-	delete(h.userData, chatID)
+	h.recordUnplannedActivity(key, subordinateID, activityTime, description, expectedReturn, false)
 }
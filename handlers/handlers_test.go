@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"testing"
+
+	"whereismychildren/bottest"
+	"whereismychildren/config"
+	"whereismychildren/database"
+	"whereismychildren/database/dbtest"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestHandler возвращает обработчик с базой в памяти и записью отправленных сообщений
+func newTestHandler(t *testing.T) (*BotHandler, *fakeSender, *database.DB) {
+	t.Helper()
+
+	db := dbtest.New(t)
+	sender := newFakeSender()
+	cfg := &config.Config{AdminIDs: []int64{bottest.AdminID}}
+
+	return NewBotHandler(sender, "test_bot", db, cfg), sender, db
+}
+
+// textUpdate - сообщение пользователя в личном чате с ботом
+func textUpdate(userID int64, text string) tgbotapi.Update {
+	return tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Text:      text,
+	}}
+}
+
+// callbackUpdate - нажатие inline-кнопки под сообщением бота
+func callbackUpdate(userID int64, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   "callback",
+		From: &tgbotapi.User{ID: userID},
+		Message: &tgbotapi.Message{
+			MessageID: 2,
+			Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		},
+		Data: data,
+	}}
+}
+
+// countRecords возвращает количество записей подчиненного в таблице уходов или деятельности
+func countRecords(t *testing.T, db *database.DB, table string, subordinateID int) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE subordinate_id = ?", subordinateID).Scan(&count); err != nil {
+		t.Fatalf("failed to count %s: %v", table, err)
+	}
+	return count
+}
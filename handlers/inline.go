@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Максимальное количество результатов во встроенном режиме
+const inlineResultsLimit = 20
+
+// HandleInlineQuery ищет подчиненных по запросу "@бот Фамилия" из любого чата
+// и возвращает карточки с текущим статусом
+func (h *BotHandler) HandleInlineQuery(update tgbotapi.Update) {
+	query := update.InlineQuery
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		IsPersonal:    true,
+		CacheTime:     0, // статус меняется в течение дня, поэтому не кэшируем
+		Results:       []interface{}{},
+	}
+
+	// Представителям поиск по всем подчиненным недоступен
+	if len(h.guardianFor(query.From.ID)) > 0 || strings.TrimSpace(query.Query) == "" {
+		h.answerInlineQuery(answer)
+		return
+	}
+
+	subordinates, err := h.searchSubordinatesInline(query.Query)
+	if err != nil {
+		log.Printf("Error searching subordinates inline: %v", err)
+		h.answerInlineQuery(answer)
+		return
+	}
+
+	statuses, err := h.db.GetStatusesForDate(time.Now())
+	if err != nil {
+		log.Printf("Error getting statuses for inline query: %v", err)
+		h.answerInlineQuery(answer)
+		return
+	}
+
+	statusByID := make(map[int]database.SubordinateStatus)
+	for _, item := range statuses {
+		statusByID[item.Subordinate.ID] = item
+	}
+
+	for i, sub := range subordinates {
+		if i >= inlineResultsLimit {
+			break
+		}
+
+		item, exists := statusByID[sub.ID]
+		if !exists {
+			item = database.SubordinateStatus{Subordinate: sub, Status: database.StatusPresent}
+		}
+
+		result := tgbotapi.NewInlineQueryResultArticle(
+			strconv.Itoa(sub.ID),
+			fmt.Sprintf("%s %s %s", sub.LastName, sub.FirstName, sub.MiddleName),
+			formatStatusCard(item),
+		)
+		result.Description = formatStatus(item)
+		answer.Results = append(answer.Results, result)
+	}
+
+	h.answerInlineQuery(answer)
+}
+
+// searchSubordinatesInline сначала ищет точное совпадение, затем - частичное,
+// так как во встроенном режиме запрос приходит по мере набора
+func (h *BotHandler) searchSubordinatesInline(query string) ([]database.Subordinate, error) {
+	lastName, firstName := utils.ParseName(query)
+
+	subordinates, _, err := h.findExactSubordinate(lastName, firstName)
+	if err != nil || len(subordinates) > 0 {
+		return subordinates, err
+	}
+
+	if firstName == "" {
+		firstName = lastName
+	}
+	return h.db.FindSubordinatesByName(lastName, firstName)
+}
+
+func (h *BotHandler) answerInlineQuery(answer tgbotapi.InlineConfig) {
+	if _, err := h.bot.Request(answer); err != nil {
+		log.Printf("Error answering inline query: %v", err)
+	}
+}
+
+// formatStatusCard формирует короткую карточку статуса подчиненного для отправки в чат
+func formatStatusCard(item database.SubordinateStatus) string {
+	return fmt.Sprintf("👤 %s %s %s\n%s\n🕒 По состоянию на %s",
+		item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName,
+		formatStatus(item), time.Now().Format("15:04"))
+}
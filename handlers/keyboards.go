@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"fmt"
+	"strings"
 
 	"whereismychildren/database"
 
@@ -21,25 +22,80 @@ func GetMainKeyboard() tgbotapi.ReplyKeyboardMarkup {
 	)
 }
 
-func CreateSubordinateSelectionKeyboard(subordinates []database.Subordinate) tgbotapi.InlineKeyboardMarkup {
+// GetGuardianKeyboard - клавиатура представителя, подключившего уведомления
+func GetGuardianKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	return tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Где мой ребёнок"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("🔔 Включить уведомления"),
+			tgbotapi.NewKeyboardButton("🔕 Отключить уведомления"),
+		),
+	)
+}
+
+// GetContactKeyboard - клавиатура с кнопкой отправки своего номера телефона
+func GetContactKeyboard() tgbotapi.ReplyKeyboardMarkup {
+	keyboard := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonContact("📱 Отправить номер телефона"),
+		),
+	)
+	keyboard.OneTimeKeyboard = true
+	return keyboard
+}
+
+// CreateConfirmationKeyboard - кнопки подтверждения действия (confirm_yes:<action> / confirm_no:<action>).
+// action совпадает с userData["action"] сессии: кнопка от прежнего действия не подтвердит текущее
+func CreateConfirmationKeyboard(action string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да", "confirm_yes:"+action),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Нет", "confirm_no:"+action),
+		),
+	)
+}
+
+// Количество подчиненных на одной странице клавиатуры выбора (10 рядов по две кнопки)
+const selectionPageSize = 20
+
+// CreateSubordinateSelectionKeyboard - клавиатура выбора подчиненного: страница списка в две колонки,
+// переключение страниц, алфавитный указатель и поиск. Выбор подчиненного - кнопка select_sub_<id>
+func CreateSubordinateSelectionKeyboard(subordinates []database.Subordinate, page int, filtered bool) tgbotapi.InlineKeyboardMarkup {
 	var rows [][]tgbotapi.InlineKeyboardButton
 
+	pages := selectionPages(subordinates)
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+
+	start := page * selectionPageSize
+	end := start + selectionPageSize
+	if end > len(subordinates) {
+		end = len(subordinates)
+	}
+	pageItems := subordinates[start:end]
+
 	// Создаем кнопки в две колонки для компактности
-	for i := 0; i < len(subordinates); i += 2 {
+	for i := 0; i < len(pageItems); i += 2 {
 		var row []tgbotapi.InlineKeyboardButton
 
 		// Первая кнопка в ряду
 		btn1 := tgbotapi.NewInlineKeyboardButtonData(
-			fmt.Sprintf("%s %s", subordinates[i].LastName, subordinates[i].FirstName),
-			fmt.Sprintf("select_sub_%d", subordinates[i].ID),
+			fmt.Sprintf("%s %s", pageItems[i].LastName, pageItems[i].FirstName),
+			fmt.Sprintf("select_sub_%d", pageItems[i].ID),
 		)
 		row = append(row, btn1)
 
 		// Вторая кнопка в ряду (если есть)
-		if i+1 < len(subordinates) {
+		if i+1 < len(pageItems) {
 			btn2 := tgbotapi.NewInlineKeyboardButtonData(
-				fmt.Sprintf("%s %s", subordinates[i+1].LastName, subordinates[i+1].FirstName),
-				fmt.Sprintf("select_sub_%d", subordinates[i+1].ID),
+				fmt.Sprintf("%s %s", pageItems[i+1].LastName, pageItems[i+1].FirstName),
+				fmt.Sprintf("select_sub_%d", pageItems[i+1].ID),
 			)
 			row = append(row, btn2)
 		}
@@ -47,5 +103,81 @@ func CreateSubordinateSelectionKeyboard(subordinates []database.Subordinate) tgb
 		rows = append(rows, row)
 	}
 
+	if pages > 1 {
+		// Переключение страниц
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀", fmt.Sprintf("subpage_%d", page-1)))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d / %d", page+1, pages), "subnoop"))
+		if page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶", fmt.Sprintf("subpage_%d", page+1)))
+		}
+		rows = append(rows, nav)
+
+		// Алфавитный указатель: первые буквы фамилий, по 8 в ряду
+		var letters []tgbotapi.InlineKeyboardButton
+		for _, letter := range selectionLetters(subordinates) {
+			letters = append(letters, tgbotapi.NewInlineKeyboardButtonData(letter, "subletter_"+letter))
+			if len(letters) == 8 {
+				rows = append(rows, letters)
+				letters = nil
+			}
+		}
+		if len(letters) > 0 {
+			rows = append(rows, letters)
+		}
+	}
+
+	if filtered {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✖ Сбросить поиск", "subreset"),
+		))
+	} else {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔍 Найти по фамилии", "subsearch"),
+		))
+	}
+
 	return tgbotapi.NewInlineKeyboardMarkup(rows...)
 }
+
+// selectionPages возвращает количество страниц клавиатуры выбора (не меньше одной)
+func selectionPages(subordinates []database.Subordinate) int {
+	pages := (len(subordinates) + selectionPageSize - 1) / selectionPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	return pages
+}
+
+// selectionLetters возвращает первые буквы фамилий в порядке следования в списке
+func selectionLetters(subordinates []database.Subordinate) []string {
+	var letters []string
+	seen := make(map[string]bool)
+	for _, sub := range subordinates {
+		letter := firstLetter(sub.LastName)
+		if letter != "" && !seen[letter] {
+			seen[letter] = true
+			letters = append(letters, letter)
+		}
+	}
+	return letters
+}
+
+// selectionPageForLetter возвращает страницу, на которой начинаются фамилии на указанную букву
+func selectionPageForLetter(subordinates []database.Subordinate, letter string) int {
+	for i, sub := range subordinates {
+		if firstLetter(sub.LastName) == letter {
+			return i / selectionPageSize
+		}
+	}
+	return 0
+}
+
+func firstLetter(name string) string {
+	for _, r := range strings.TrimSpace(name) {
+		return strings.ToUpper(string(r))
+	}
+	return ""
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"whereismychildren/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleWhoCommand показывает карточку подчиненного: /who Фамилия [Имя]
+func (h *BotHandler) handleWhoCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+
+	query := strings.TrimSpace(strings.TrimPrefix(text, "/who"))
+	if query == "" {
+		h.startProfileSelection(key)
+		return
+	}
+
+	subordinates, _, err := h.findExactSubordinate(query, "")
+	if err != nil {
+		h.sendError(chatID, "Ошибка поиска подчиненных: "+err.Error())
+		return
+	}
+
+	switch len(subordinates) {
+	case 0:
+		h.sendError(chatID, "Сотрудник не найден")
+	case 1:
+		h.sendProfile(key, subordinates[0].ID)
+	default:
+		h.userStates[key] = "waiting_profile_selection"
+		h.userData[key] = map[string]interface{}{
+			"sub_list": subordinates,
+		}
+		h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
+	}
+}
+
+// startProfileSelection предлагает выбрать подчиненного из полного списка, чтобы открыть его карточку
+func (h *BotHandler) startProfileSelection(key sessionKey) {
+	subordinates, err := h.db.GetAllSubordinates()
+	if err != nil {
+		h.sendError(key.ChatID, "❌ Ошибка получения списка подчиненных: "+err.Error())
+		return
+	}
+
+	if len(subordinates) == 0 {
+		h.sendError(key.ChatID, "❌ Нет добавленных подчиненных. Сначала добавьте их через Excel.")
+		return
+	}
+
+	h.sortSubordinatesAlphabetically(subordinates)
+
+	h.userStates[key] = "waiting_profile_selection"
+	h.userData[key] = map[string]interface{}{
+		"sub_list": subordinates,
+	}
+	h.sendSubordinateSelection(key, "👤 Выберите подчиненного:")
+}
+
+// handleProfileCallback открывает карточку по кнопке who_<id> или выбор подчиненного по кнопке who_pick
+func (h *BotHandler) handleProfileCallback(key sessionKey, data string) {
+	if data == "who_pick" {
+		h.startProfileSelection(key)
+		return
+	}
+
+	subID, err := strconv.Atoi(strings.TrimPrefix(data, "who_"))
+	if err != nil {
+		h.sendError(key.ChatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+	h.sendProfile(key, subID)
+}
+
+func (h *BotHandler) sendProfile(key sessionKey, subID int) {
+	chatID := key.ChatID
+	sub, err := h.db.GetSubordinateByID(subID)
+	if err != nil {
+		h.sendError(chatID, "Сотрудник не найден")
+		return
+	}
+
+	profile, err := h.db.GetSubordinateProfile(sub, time.Now())
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения данных: "+err.Error())
+		return
+	}
+
+	// Телефоны представителей видит только администратор и только в личном чате, чтобы они не попали в группу
+	showPhones := h.isAdmin(key.UserID) && chatID == key.UserID
+
+	msg := tgbotapi.NewMessage(chatID, formatProfile(profile, showPhones))
+	h.bot.Send(msg)
+}
+
+// formatProfile формирует текст карточки подчиненного. Без showPhones телефоны представителей не выводятся
+func formatProfile(profile database.SubordinateProfile, showPhones bool) string {
+	sub := profile.Status.Subordinate
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("👤 %s %s %s\n", sub.LastName, sub.FirstName, sub.MiddleName))
+	if sub.Group != "" {
+		b.WriteString(fmt.Sprintf("👥 Группа: %s\n", sub.Group))
+	}
+	b.WriteString(fmt.Sprintf("Сейчас: %s\n", formatStatus(profile.Status)))
+
+	b.WriteString("\n📅 Сегодня:\n")
+	if len(profile.Today) == 0 {
+		b.WriteString("• событий нет\n")
+	}
+	for _, event := range profile.Today {
+		if event.Kind == database.KindActivity {
+			b.WriteString(fmt.Sprintf("• %s внеплановая деятельность: %s%s\n", event.Start.Format("15:04"),
+				truncateString(event.Description, 100), formatReturnNote(event.ReturnedAt, event.ExpectedReturn)))
+		} else {
+			b.WriteString(fmt.Sprintf("• %s уход%s\n", event.Start.Format("15:04"),
+				formatReturnNote(event.ReturnedAt, event.ExpectedReturn)))
+		}
+	}
+
+	b.WriteString(fmt.Sprintf("\n📊 За 7 дней: уходов %d, внеплановой деятельности %d\n", profile.Leaves7, profile.Activities7))
+	b.WriteString(fmt.Sprintf("📊 За 30 дней: уходов %d, внеплановой деятельности %d\n", profile.Leaves30, profile.Activities30))
+
+	if len(profile.RecentActivities) > 0 {
+		b.WriteString("\n📝 Последняя деятельность:\n")
+		for _, activity := range profile.RecentActivities {
+			b.WriteString(fmt.Sprintf("• %s %s\n", activity.ActivityTime.Format("02.01 15:04"),
+				truncateString(activity.Description, 80)))
+		}
+	}
+
+	if len(profile.Guardians) > 0 {
+		b.WriteString("\n👪 Представители:\n")
+		for _, guardian := range profile.Guardians {
+			line := "• " + guardian.FullName
+			if guardian.Relation != "" {
+				line += " (" + guardian.Relation + ")"
+			}
+			if showPhones {
+				line += ", " + guardian.Phone
+			}
+			if guardian.TelegramID != nil {
+				line += " - в боте"
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// formatReturnNote возвращает пометку о возвращении для события в карточке
+func formatReturnNote(returnedAt, expectedReturn *time.Time) string {
+	if returnedAt != nil {
+		return fmt.Sprintf(", вернулся в %s", returnedAt.Format("15:04"))
+	}
+	return formatExpectedReturn(expectedReturn)
+}
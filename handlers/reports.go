@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"os"
+	"strings"
+	"time"
+
+	"whereismychildren/report"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *BotHandler) handleReportCommand(chatID int64, text string) {
+	parts := strings.Fields(text)
+	if len(parts) < 2 || strings.ToLower(parts[1]) != "pdf" {
+		h.sendError(chatID, "Укажите формат отчёта: /report pdf [ДД.ММ.ГГГГ]")
+		return
+	}
+
+	date := time.Now()
+	if len(parts) > 2 {
+		var err error
+		date, err = utils.ParseDate(parts[2])
+		if err != nil {
+			h.sendError(chatID, "Неверный формат даты. Используйте ДД.ММ.ГГГГ")
+			return
+		}
+	}
+
+	h.sendPDFReport(chatID, date)
+}
+
+func (h *BotHandler) sendPDFReport(chatID int64, date time.Time) {
+	statuses, err := h.db.GetStatusesForDate(date)
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения данных: "+err.Error())
+		return
+	}
+
+	absences, err := h.db.GetAbsencesBetween(date, date, 0, "")
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения данных: "+err.Error())
+		return
+	}
+
+	filepath, err := report.GenerateDailyPDF(report.DailyReport{
+		Date:        date,
+		Statuses:    statuses,
+		Events:      absences,
+		GeneratedAt: time.Now(),
+	})
+	if err != nil {
+		h.sendError(chatID, "Ошибка создания PDF: "+err.Error())
+		return
+	}
+	defer os.Remove(filepath) // Удаляем временный файл
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FilePath(filepath))
+	doc.Caption = "🖨 Отчёт о присутствии за " + date.Format("02.01.2006")
+
+	if _, err := h.bot.Send(doc); err != nil {
+		h.sendError(chatID, "Ошибка отправки файла: "+err.Error())
+	}
+}
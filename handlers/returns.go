@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Варианты ожидаемого возвращения, предлагаемые после фиксации отсутствия (в минутах)
+var expectedReturnOptions = []int{60, 120, 180}
+
+// resolveExpectedReturn возвращает явно указанное время возвращения или значение по умолчанию для вида отсутствия
+func (h *BotHandler) resolveExpectedReturn(kind string, eventTime time.Time, expected *time.Time) *time.Time {
+	if expected != nil {
+		return expected
+	}
+
+	after := h.config.LeaveReturnAfter
+	if kind == database.KindActivity {
+		after = h.config.ActivityReturnAfter
+	}
+	if after <= 0 {
+		return nil
+	}
+
+	defaultReturn := eventTime.Add(after)
+	return &defaultReturn
+}
+
+// trackReturn сохраняет ожидаемое время возвращения для записи и возвращает пометку для ответа
+// и клавиатуру с вариантами, если время не задано
+func (h *BotHandler) trackReturn(chatID int64, kind string, id int, eventTime time.Time, expected *time.Time) (string, *tgbotapi.InlineKeyboardMarkup) {
+	expected = h.resolveExpectedReturn(kind, eventTime, expected)
+	if expected == nil {
+		keyboard := createExpectedReturnKeyboard(kind, id)
+		return "", &keyboard
+	}
+
+	if err := h.db.SetExpectedReturn(kind, id, *expected, chatID); err != nil {
+		log.Printf("Error setting expected return for %s %d: %v", kind, id, err)
+		return "", nil
+	}
+
+	return fmt.Sprintf(", вернётся к %s", expected.Format("15:04")), nil
+}
+
+// returnAfter переносит время суток clock на дату события; если оно не позже события - на следующий день
+func returnAfter(eventTime, clock time.Time) time.Time {
+	expected := time.Date(eventTime.Year(), eventTime.Month(), eventTime.Day(),
+		clock.Hour(), clock.Minute(), 0, 0, eventTime.Location())
+	if !expected.After(eventTime) {
+		expected = expected.AddDate(0, 0, 1)
+	}
+	return expected
+}
+
+func createExpectedReturnKeyboard(kind string, id int) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, minutes := range expectedReturnOptions {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("⏱ через %d ч", minutes/60),
+			fmt.Sprintf("expect_%s_%d_%d", kind, id, minutes),
+		))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+func createOverdueKeyboard(kind string, id int) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Вернулся", fmt.Sprintf("returned_%s_%d", kind, id)),
+			tgbotapi.NewInlineKeyboardButtonData("👌 Принято", fmt.Sprintf("ack_%s_%d", kind, id)),
+		),
+	)
+}
+
+// parseRecordCallback разбирает данные кнопки вида "<действие>_<вид>_<id>[_<значение>]"
+func parseRecordCallback(data string) (kind string, id int, value int, err error) {
+	parts := strings.Split(data, "_")
+	if len(parts) < 3 {
+		return "", 0, 0, fmt.Errorf("неверные данные кнопки: %s", data)
+	}
+
+	kind = parts[1]
+	if id, err = strconv.Atoi(parts[2]); err != nil {
+		return "", 0, 0, err
+	}
+	if len(parts) > 3 {
+		if value, err = strconv.Atoi(parts[3]); err != nil {
+			return "", 0, 0, err
+		}
+	}
+	return kind, id, value, nil
+}
+
+// canHandleReturnButton проверяет, может ли нажавший отметить возвращение по записи кнопкой.
+// Это администраторы, участники зарегистрированных групп сотрудников (кнопка нажата в такой группе)
+// и в личном чате - тот, кому по этой записи приходят напоминания
+func (h *BotHandler) canHandleReturnButton(callback *tgbotapi.CallbackQuery, kind string, id int) bool {
+	if h.isAdmin(callback.From.ID) {
+		return true
+	}
+
+	chat := callback.Message.Chat
+	if isGroupChat(chat) {
+		return h.isSupervisorChat(chat.ID)
+	}
+
+	notifyChatID, err := h.db.GetNotifyChat(kind, id)
+	return err == nil && notifyChatID == chat.ID
+}
+
+func (h *BotHandler) handleExpectCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	kind, id, minutes, err := parseRecordCallback(callback.Data)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+
+	expected := time.Now().Add(time.Duration(minutes) * time.Minute)
+	if err := h.db.SetExpectedReturn(kind, id, expected, chatID); err != nil {
+		h.sendError(chatID, "Ошибка сохранения времени возвращения: "+err.Error())
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID,
+		callback.Message.Text+fmt.Sprintf("\n⏱ Ожидаемое возвращение: %s", expected.Format("15:04")))
+	h.bot.Send(edit)
+}
+
+func (h *BotHandler) handleReturnedCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	kind, id, _, err := parseRecordCallback(callback.Data)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+	if !h.canHandleReturnButton(callback, kind, id) {
+		h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
+		return
+	}
+
+	now := time.Now()
+	if err := h.db.MarkReturned(kind, id, now); err != nil {
+		h.sendError(chatID, "Ошибка фиксации возвращения: "+err.Error())
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID,
+		callback.Message.Text+fmt.Sprintf("\n\n✅ Возвращение отмечено в %s", now.Format("15:04")))
+	h.bot.Send(edit)
+}
+
+func (h *BotHandler) handleAckCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	kind, id, _, err := parseRecordCallback(callback.Data)
+	if err != nil {
+		h.sendError(chatID, "Ошибка обработки кнопки: "+err.Error())
+		return
+	}
+	if !h.canHandleReturnButton(callback, kind, id) {
+		h.sendError(chatID, "❌ У вас нет прав для выполнения этой команды")
+		return
+	}
+
+	if err := h.db.AcknowledgeAlert(kind, id); err != nil {
+		h.sendError(chatID, "Ошибка отключения напоминаний: "+err.Error())
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, callback.Message.MessageID,
+		callback.Message.Text+"\n\n👌 Принято, напоминания отключены")
+	h.bot.Send(edit)
+}
+
+// handleReturnedCommand фиксирует возвращение: /returned Фамилия [Имя] [ЧЧ:ММ]
+func (h *BotHandler) handleReturnedCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+	text = strings.TrimSpace(strings.TrimPrefix(text, "/returned"))
+
+	returnedAt := time.Now()
+	if utils.ContainsTime(text) {
+		var err error
+		returnedAt, err = utils.ParseTime(text)
+		if err != nil {
+			h.sendError(chatID, "Ошибка парсинга времени: "+err.Error())
+			return
+		}
+		text = regexp.MustCompile(`\d{1,2}:\d{2}`).ReplaceAllString(text, "")
+	}
+
+	lastName, firstName := utils.ParseName(text)
+	if lastName == "" {
+		h.sendError(chatID, "Укажите фамилию: /returned Фамилия [Имя] [ЧЧ:ММ]")
+		return
+	}
+
+	subordinates, exact, err := h.findExactSubordinate(lastName, firstName)
+	if err != nil {
+		h.sendError(chatID, "Ошибка поиска подчиненных: "+err.Error())
+		return
+	}
+
+	if len(subordinates) == 0 {
+		h.sendError(chatID, "Сотрудник не найден")
+		return
+	}
+
+	if len(subordinates) > 1 {
+		h.sendError(chatID, "Найдено несколько сотрудников, укажите фамилию и имя")
+		return
+	}
+
+	if !exact {
+		h.confirmMatch(key, subordinates[0], map[string]interface{}{"action": "returned", "returned_at": returnedAt})
+		return
+	}
+	h.markReturned(chatID, subordinates[0].ID, returnedAt)
+}
+
+// markReturned закрывает незакрытые за сегодня отсутствия подчиненного
+func (h *BotHandler) markReturned(chatID int64, subordinateID int, returnedAt time.Time) {
+	sub, err := h.db.GetSubordinateByID(subordinateID)
+	if err != nil {
+		h.sendError(chatID, "Сотрудник не найден")
+		return
+	}
+
+	closed, err := h.db.MarkReturnedForDate(sub.ID, returnedAt)
+	if err != nil {
+		h.sendError(chatID, "Ошибка фиксации возвращения: "+err.Error())
+		return
+	}
+
+	if closed == 0 {
+		h.sendError(chatID, fmt.Sprintf("У %s %s нет незакрытых отсутствий за сегодня", sub.LastName, sub.FirstName))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🏠 %s %s вернулся в %s",
+		sub.LastName, sub.FirstName, returnedAt.Format("15:04")))
+	h.bot.Send(msg)
+}
+
+// CheckOverdueReturns отправляет напоминания о подчиненных, не вернувшихся к ожидаемому времени.
+// Вызывается планировщиком
+func (h *BotHandler) CheckOverdueReturns() {
+	pending, err := h.db.GetPendingReturns(time.Now().In(h.config.Location))
+	if err != nil {
+		log.Printf("Error getting pending returns: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, item := range pending {
+		if item.ExpectedReturn.After(now) {
+			continue
+		}
+
+		// Повторяем напоминание не чаще заданного интервала (0 - напомнить один раз)
+		if item.LastAlertAt != nil {
+			interval := h.config.OverdueRepeatInterval
+			if interval <= 0 || now.Sub(*item.LastAlertAt) < interval {
+				continue
+			}
+		}
+
+		h.sendOverdueAlert(item, now)
+
+		if err := h.db.MarkAlerted(item.Kind, item.ID, now); err != nil {
+			log.Printf("Error marking %s %d as alerted: %v", item.Kind, item.ID, err)
+		}
+	}
+}
+
+func (h *BotHandler) sendOverdueAlert(item database.PendingReturn, now time.Time) {
+	message := fmt.Sprintf("⏰ Не вернулся: %s %s %s\n",
+		item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName)
+
+	if item.Kind == database.KindActivity {
+		message += fmt.Sprintf("Внеплановая деятельность с %s: %s\n",
+			item.EventTime.Format("15:04"), truncateString(item.Description, 200))
+	} else {
+		message += fmt.Sprintf("Ушёл в %s\n", item.EventTime.Format("15:04"))
+	}
+
+	message += fmt.Sprintf("Ожидался к %s (опоздание %s)",
+		item.ExpectedReturn.Format("15:04"), formatDuration(now.Sub(item.ExpectedReturn)))
+
+	// Напоминание получает чат, зафиксировавший отсутствие, иначе - администраторы и группы ответственных
+	recipients := h.supervisorRecipients()
+	if item.NotifyChatID != 0 {
+		recipients = []int64{item.NotifyChatID}
+	}
+
+	for _, chatID := range recipients {
+		msg := tgbotapi.NewMessage(chatID, message)
+		msg.ReplyMarkup = createOverdueKeyboard(item.Kind, item.ID)
+		if _, err := h.bot.Send(msg); err != nil {
+			log.Printf("Error sending overdue alert to %d: %v", chatID, err)
+		}
+	}
+}
+
+// formatDuration выводит длительность в виде "1 ч 5 мин"
+func formatDuration(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%d мин", minutes)
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("%d ч", minutes/60)
+	}
+	return fmt.Sprintf("%d ч %d мин", minutes/60, minutes%60)
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"whereismychildren/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleAddSubordinate добавляет подчиненного: /add Фамилия Имя [Отчество]
+func (h *BotHandler) handleAddSubordinate(key sessionKey, text string) {
+	chatID := key.ChatID
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	lastName, firstName, middleName, ok := parseFullName(strings.TrimPrefix(text, "/add"))
+	if !ok {
+		h.sendError(chatID, "Укажите ФИО: /add Фамилия Имя [Отчество]")
+		return
+	}
+
+	existing, err := h.db.CreateSubordinate(database.Subordinate{
+		LastName:   lastName,
+		FirstName:  firstName,
+		MiddleName: middleName,
+	})
+	if err == database.ErrSubordinateExists {
+		if existing.ArchivedAt != nil {
+			h.sendError(chatID, fmt.Sprintf("%s %s в архиве. Вернуть: /restore %s %s", lastName, firstName, lastName, firstName))
+		} else {
+			h.sendError(chatID, fmt.Sprintf("%s %s уже есть в списке", lastName, firstName))
+		}
+		return
+	}
+	if err != nil {
+		h.sendError(chatID, "Ошибка добавления подчиненного: "+recordErrorText(err))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Добавлен: %s %s %s", lastName, firstName, middleName))
+	h.bot.Send(msg)
+}
+
+// handleRosterCommand обрабатывает /rename, /archive, /restore и /merge: находит подчиненного
+// по фамилии и выполняет действие, а при нескольких совпадениях предлагает выбрать
+func (h *BotHandler) handleRosterCommand(key sessionKey, command, text string) {
+	chatID := key.ChatID
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	query := strings.TrimSpace(strings.TrimPrefix(text, command))
+	if query == "" {
+		h.sendError(chatID, fmt.Sprintf("Укажите фамилию: %s Фамилия [Имя]", command))
+		return
+	}
+
+	var candidates []database.Subordinate
+	var err error
+	switch command {
+	case "/restore":
+		candidates, err = h.db.GetArchivedSubordinates()
+	case "/merge":
+		// Дубликатом может оказаться и архивная запись
+		candidates, err = h.db.GetSubordinatesIncludingArchived()
+	default:
+		candidates, err = h.db.GetAllSubordinates()
+	}
+	if err != nil {
+		h.sendError(chatID, "Ошибка получения списка подчиненных: "+err.Error())
+		return
+	}
+
+	matches := database.RankSubordinates(candidates, query)
+	if len(matches) == 0 {
+		h.sendError(chatID, "Сотрудник не найден")
+		return
+	}
+
+	if command == "/merge" {
+		// Для объединения нужны все похожие записи, а не только лучшие совпадения
+		var found []database.Subordinate
+		for _, match := range matches {
+			found = append(found, match.Subordinate)
+		}
+		h.startMerge(key, found)
+		return
+	}
+
+	found := database.BestMatches(matches)
+	state := map[string]string{
+		"/rename":  "waiting_rename_selection",
+		"/archive": "waiting_archive_selection",
+		"/restore": "waiting_restore_selection",
+	}[command]
+
+	if len(found) == 1 {
+		h.handleRosterSelection(key, state, found[0].ID)
+		return
+	}
+
+	h.userStates[key] = state
+	h.userData[key] = map[string]interface{}{
+		"sub_list": found,
+	}
+	h.sendSubordinateSelection(key, "Найдено несколько сотрудников. Выберите нужного:")
+}
+
+func (h *BotHandler) startMerge(key sessionKey, candidates []database.Subordinate) {
+	if len(candidates) < 2 {
+		h.sendError(key.ChatID, "Найдена только одна запись - объединять нечего")
+		return
+	}
+
+	h.userStates[key] = "waiting_merge_keep"
+	h.userData[key] = map[string]interface{}{
+		"sub_list":         candidates,
+		"merge_candidates": candidates,
+	}
+	h.sendSubordinateSelection(key, "🔗 Выберите запись, которую нужно оставить:")
+}
+
+// handleRosterSelection выполняет действие над выбранным подчиненным в зависимости от состояния сессии
+func (h *BotHandler) handleRosterSelection(key sessionKey, state string, subID int) {
+	chatID := key.ChatID
+	userData := h.userData[key]
+	delete(h.userStates, key)
+	delete(h.userData, key)
+
+	sub, err := h.db.GetSubordinateByID(subID)
+	if err != nil {
+		h.sendError(chatID, "Сотрудник не найден")
+		return
+	}
+	name := fmt.Sprintf("%s %s %s", sub.LastName, sub.FirstName, sub.MiddleName)
+
+	switch state {
+	case "waiting_rename_selection":
+		h.userStates[key] = "waiting_rename_input"
+		h.userData[key] = map[string]interface{}{
+			"subordinate_id": subID,
+		}
+
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✏️ Введите новое ФИО для %s (Фамилия Имя [Отчество]):", name))
+		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
+		h.bot.Send(msg)
+
+	case "waiting_archive_selection":
+		archived, err := h.db.ArchiveSubordinate(subID)
+		if err != nil {
+			h.sendError(chatID, "Ошибка переноса в архив: "+err.Error())
+			return
+		}
+		text := fmt.Sprintf("%s уже в архиве", name)
+		if archived {
+			text = fmt.Sprintf("🗄 %s перенесён в архив. История сохранена, вернуть: /restore %s", name, sub.LastName)
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, text))
+
+	case "waiting_restore_selection":
+		restored, err := h.db.RestoreSubordinate(subID)
+		if err != nil {
+			h.sendError(chatID, "Ошибка возврата из архива: "+err.Error())
+			return
+		}
+		text := fmt.Sprintf("%s не в архиве", name)
+		if restored {
+			text = fmt.Sprintf("✅ %s возвращён из архива", name)
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, text))
+
+	case "waiting_merge_keep":
+		candidates, _ := userData["merge_candidates"].([]database.Subordinate)
+		var duplicates []database.Subordinate
+		for _, candidate := range candidates {
+			if candidate.ID != subID {
+				duplicates = append(duplicates, candidate)
+			}
+		}
+
+		h.userStates[key] = "waiting_merge_duplicate"
+		h.userData[key] = map[string]interface{}{
+			"sub_list": duplicates,
+			"keep_id":  subID,
+		}
+		h.sendSubordinateSelection(key, fmt.Sprintf("🔗 Выберите дубликат, который будет объединён с %s:", name))
+
+	case "waiting_merge_duplicate":
+		keepID, _ := userData["keep_id"].(int)
+		keep, err := h.db.GetSubordinateByID(keepID)
+		if err != nil {
+			h.sendError(chatID, "Сотрудник не найден")
+			return
+		}
+
+		h.userData[key] = map[string]interface{}{
+			"action":         "confirm_merge",
+			"subordinate_id": keepID,
+			"merge_id":       subID,
+		}
+
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"Объединить записи?\n\nОстанется: %s %s %s\nБудет удалена: %s\n\n"+
+				"Уходы, внеплановая деятельность и представители перейдут к оставшейся записи. "+
+				"Если у обоих есть запись на одно и то же время, сохранится запись оставшейся.",
+			keep.LastName, keep.FirstName, keep.MiddleName, name))
+		msg.ReplyMarkup = CreateConfirmationKeyboard("confirm_merge")
+		h.sendSessionKeyboard(key, msg)
+	}
+}
+
+// handleRenameInput применяет новое ФИО, введённое после /rename
+func (h *BotHandler) handleRenameInput(key sessionKey, text string) {
+	chatID := key.ChatID
+
+	subID, ok := h.userData[key]["subordinate_id"].(int)
+	delete(h.userStates, key)
+	delete(h.userData, key)
+	if !ok {
+		h.sendError(chatID, "Данные сессии устарели")
+		return
+	}
+
+	lastName, firstName, middleName, ok := parseFullName(text)
+	if !ok {
+		h.sendError(chatID, "Укажите ФИО полностью: Фамилия Имя [Отчество]. Повторите /rename")
+		return
+	}
+
+	err := h.db.RenameSubordinate(subID, lastName, firstName, middleName)
+	if err == database.ErrSubordinateExists {
+		h.sendError(chatID, fmt.Sprintf("%s %s %s уже есть в списке. Если это один человек, используйте /merge %s",
+			lastName, firstName, middleName, lastName))
+		return
+	}
+	if err != nil {
+		h.sendError(chatID, "Ошибка переименования: "+err.Error())
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Новое ФИО: %s %s %s", lastName, firstName, middleName))
+	h.bot.Send(msg)
+}
+
+// mergeSubordinates объединяет записи после подтверждения
+func (h *BotHandler) mergeSubordinates(chatID int64, keepID, duplicateID int) {
+	if err := h.db.MergeSubordinates(keepID, duplicateID); err != nil {
+		h.sendError(chatID, "Ошибка объединения: "+err.Error())
+		return
+	}
+
+	sub, _ := h.db.GetSubordinateByID(keepID)
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Записи объединены: %s %s %s", sub.LastName, sub.FirstName, sub.MiddleName))
+	h.bot.Send(msg)
+}
+
+// parseFullName разбирает "Фамилия Имя [Отчество]"; отчество может состоять из нескольких слов
+func parseFullName(text string) (lastName, firstName, middleName string, ok bool) {
+	words := strings.Fields(text)
+	if len(words) < 2 {
+		return "", "", "", false
+	}
+	return words[0], words[1], strings.Join(words[2:], " "), true
+}
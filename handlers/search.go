@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Сколько найденных записей выводить в одном ответе
+const searchResultLimit = 30
+
+// handleSearchCommand ищет внеплановую деятельность по описанию: /search <текст> [период]
+func (h *BotHandler) handleSearchCommand(chatID int64, text string) {
+	words := strings.Fields(strings.TrimPrefix(text, "/search"))
+	if len(words) == 0 {
+		h.sendError(chatID, "Укажите текст для поиска: /search <текст> [неделя|месяц|ДД.ММ.ГГГГ|ДД.ММ.ГГГГ-ДД.ММ.ГГГГ]")
+		return
+	}
+
+	// Последнее слово считаем периодом, если оно разбирается как период; без периода ищем за всё время
+	var from, to time.Time
+	if len(words) > 1 {
+		if periodFrom, periodTo, err := utils.ParsePeriod(words[len(words)-1]); err == nil {
+			from, to = periodFrom, periodTo
+			words = words[:len(words)-1]
+		}
+	}
+	query := strings.Join(words, " ")
+
+	results, err := h.db.SearchActivities(query, from, to)
+	if err != nil {
+		h.sendError(chatID, "Ошибка поиска: "+err.Error())
+		return
+	}
+
+	periodTitle := "за всё время"
+	if !from.IsZero() {
+		periodTitle = fmt.Sprintf("за %s - %s", from.Format("02.01.2006"), to.Format("02.01.2006"))
+	}
+
+	if len(results) == 0 {
+		h.sendError(chatID, fmt.Sprintf("По запросу «%s» %s ничего не найдено", query, periodTitle))
+		return
+	}
+
+	var message strings.Builder
+	message.WriteString(fmt.Sprintf("🔎 «%s» %s: найдено %d\n\n", query, periodTitle, len(results)))
+
+	for i, item := range results {
+		if i == searchResultLimit {
+			message.WriteString(fmt.Sprintf("\n... показаны последние %d, уточните запрос или период", searchResultLimit))
+			break
+		}
+		message.WriteString(fmt.Sprintf("%s %s %s: %s\n",
+			item.Activity.ActivityTime.Format("02.01.2006 15:04"),
+			item.Subordinate.LastName, item.Subordinate.FirstName,
+			truncateString(item.Activity.Description, 80)))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message.String())
+	h.bot.Send(msg)
+}
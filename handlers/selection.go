@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"whereismychildren/database"
+	"whereismychildren/utils"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sendSubordinateSelection отправляет первую страницу клавиатуры выбора.
+// Список подчиненных должен быть сохранён в userData[key]["sub_list"]
+func (h *BotHandler) sendSubordinateSelection(key sessionKey, prompt string) {
+	subordinates, filtered, ok := h.selectionList(key)
+	if !ok {
+		h.sendError(key.ChatID, "❌ Данные сессии устарели")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(key.ChatID, prompt)
+	msg.ReplyMarkup = CreateSubordinateSelectionKeyboard(subordinates, 0, filtered)
+	h.sendSessionKeyboard(key, msg)
+}
+
+// sendSessionKeyboard отправляет сообщение с кнопками текущей сессии и запоминает его номер:
+// в группе кнопки видят все участники, а засчитываются они только владельцу сессии (см. ownsKeyboard)
+func (h *BotHandler) sendSessionKeyboard(key sessionKey, msg tgbotapi.MessageConfig) {
+	sent, err := h.bot.Send(msg)
+	if err == nil && h.userData[key] != nil {
+		h.userData[key]["keyboard_message_id"] = sent.MessageID
+	}
+}
+
+// ownsKeyboard сообщает, что нажата клавиатура сессии того, кто нажал кнопку. В личном чате кнопки
+// нажимает только его владелец; в группе у каждого участника своя сессия, и чужие кнопки её не трогают
+func (h *BotHandler) ownsKeyboard(chat *tgbotapi.Chat, key sessionKey, messageID int) bool {
+	if !isGroupChat(chat) {
+		return true
+	}
+	keyboardID, ok := h.userData[key]["keyboard_message_id"].(int)
+	return ok && keyboardID == messageID
+}
+
+// selectionList возвращает список для выбора с учётом введённого фильтра
+func (h *BotHandler) selectionList(key sessionKey) (subordinates []database.Subordinate, filtered bool, ok bool) {
+	userData, exists := h.userData[key]
+	if !exists {
+		return nil, false, false
+	}
+
+	subordinates, ok = userData["sub_list"].([]database.Subordinate)
+	if !ok {
+		return nil, false, false
+	}
+
+	filter, _ := userData["filter"].(string)
+	if filter == "" {
+		return subordinates, false, true
+	}
+	return filterSubordinates(subordinates, filter), true, true
+}
+
+// handleSelectionCallback обрабатывает кнопки навигации клавиатуры выбора: страницы, буквы и поиск
+func (h *BotHandler) handleSelectionCallback(key sessionKey, data string, messageID int) {
+	if data == "subnoop" {
+		return
+	}
+
+	if _, _, ok := h.selectionList(key); !ok {
+		h.sendError(key.ChatID, "❌ Данные сессии устарели")
+		return
+	}
+
+	switch {
+	case data == "subsearch":
+		// Следующее текстовое сообщение сужает список
+		h.userData[key]["filter_mode"] = true
+		h.userData[key]["selection_message_id"] = messageID
+
+		msg := tgbotapi.NewMessage(key.ChatID, "🔍 Введите часть фамилии или имени:")
+		msg.ReplyMarkup = tgbotapi.ForceReply{ForceReply: true}
+		h.bot.Send(msg)
+		return
+	case data == "subreset":
+		delete(h.userData[key], "filter")
+		h.showSelectionPage(key, messageID, 0)
+	case strings.HasPrefix(data, "subpage_"):
+		page, err := strconv.Atoi(strings.TrimPrefix(data, "subpage_"))
+		if err != nil {
+			h.sendError(key.ChatID, "Ошибка обработки кнопки: "+err.Error())
+			return
+		}
+		h.showSelectionPage(key, messageID, page)
+	case strings.HasPrefix(data, "subletter_"):
+		subordinates, _, _ := h.selectionList(key)
+		page := selectionPageForLetter(subordinates, strings.TrimPrefix(data, "subletter_"))
+		h.showSelectionPage(key, messageID, page)
+	}
+}
+
+// showSelectionPage заменяет клавиатуру выбора в сообщении на указанную страницу
+func (h *BotHandler) showSelectionPage(key sessionKey, messageID int, page int) {
+	subordinates, filtered, _ := h.selectionList(key)
+	keyboard := CreateSubordinateSelectionKeyboard(subordinates, page, filtered)
+	h.bot.Send(tgbotapi.NewEditMessageReplyMarkup(key.ChatID, messageID, keyboard))
+}
+
+// handleSelectionFilter сужает список выбора по введённому тексту и отправляет новую клавиатуру
+func (h *BotHandler) handleSelectionFilter(key sessionKey, text string) {
+	filter := strings.TrimSpace(text)
+	subordinates, _ := h.userData[key]["sub_list"].([]database.Subordinate)
+
+	found := filterSubordinates(subordinates, filter)
+	if len(found) == 0 {
+		// Остаёмся в режиме поиска, чтобы можно было уточнить запрос
+		h.sendError(key.ChatID, fmt.Sprintf("Никого не найдено по запросу «%s». Введите другой запрос:", filter))
+		return
+	}
+
+	delete(h.userData[key], "filter_mode")
+	h.userData[key]["filter"] = filter
+
+	// Старая клавиатура больше не нужна
+	if messageID, ok := h.userData[key]["selection_message_id"].(int); ok {
+		h.bot.Send(tgbotapi.NewDeleteMessage(key.ChatID, messageID))
+		delete(h.userData[key], "selection_message_id")
+	}
+
+	h.sendSubordinateSelection(key, fmt.Sprintf("Найдено: %d. Выберите нужного:", len(found)))
+}
+
+// isFilteringSelection сообщает, ждёт ли клавиатура выбора текст для поиска
+func (h *BotHandler) isFilteringSelection(key sessionKey) bool {
+	filtering, _ := h.userData[key]["filter_mode"].(bool)
+	return filtering
+}
+
+// filterSubordinates оставляет подчиненных, в ФИО которых встречается каждое слово запроса
+func filterSubordinates(subordinates []database.Subordinate, filter string) []database.Subordinate {
+	words := strings.Fields(utils.NormalizeName(filter))
+
+	var result []database.Subordinate
+	for _, sub := range subordinates {
+		fullName := utils.NormalizeName(sub.LastName + " " + sub.FirstName + " " + sub.MiddleName)
+		matched := true
+		for _, word := range words {
+			if !strings.Contains(fullName, word) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, sub)
+		}
+	}
+	return result
+}
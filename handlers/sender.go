@@ -0,0 +1,11 @@
+package handlers
+
+import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+// Sender - методы Telegram Bot API, которыми пользуется обработчик.
+// Его реализует *tgbotapi.BotAPI, а в тестах - запись отправленных сообщений без обращения к Telegram
+type Sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetFileDirectURL(fileID string) (string, error)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeSender записывает всё, что обработчик отправляет в Telegram
+type fakeSender struct {
+	mu       sync.Mutex
+	sent     []tgbotapi.Chattable
+	requests []tgbotapi.Chattable
+	nextID   int
+	// Прямые ссылки на файлы по FileID для загрузки документов
+	files map[string]string
+}
+
+func newFakeSender() *fakeSender {
+	return &fakeSender{files: make(map[string]string)}
+}
+
+func (f *fakeSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, c)
+	f.nextID++
+	return tgbotapi.Message{MessageID: f.nextID}, nil
+}
+
+func (f *fakeSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (f *fakeSender) GetFileDirectURL(fileID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.files[fileID], nil
+}
+
+// messages возвращает тексты отправленных сообщений и подписи к файлам
+func (f *fakeSender) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var texts []string
+	for _, c := range f.sent {
+		switch msg := c.(type) {
+		case tgbotapi.MessageConfig:
+			texts = append(texts, msg.Text)
+		case tgbotapi.DocumentConfig:
+			texts = append(texts, msg.Caption)
+		case tgbotapi.PhotoConfig:
+			texts = append(texts, msg.Caption)
+		}
+	}
+	return texts
+}
+
+// lastMessage возвращает последнее отправленное текстовое сообщение
+func (f *fakeSender) lastMessage(t *testing.T) tgbotapi.MessageConfig {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.sent) - 1; i >= 0; i-- {
+		if msg, ok := f.sent[i].(tgbotapi.MessageConfig); ok {
+			return msg
+		}
+	}
+	t.Fatal("no messages were sent")
+	return tgbotapi.MessageConfig{}
+}
+
+// lastEdit возвращает последнее изменённое сообщение
+func (f *fakeSender) lastEdit(t *testing.T) tgbotapi.EditMessageTextConfig {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.sent) - 1; i >= 0; i-- {
+		if edit, ok := f.sent[i].(tgbotapi.EditMessageTextConfig); ok {
+			return edit
+		}
+	}
+	t.Fatal("no messages were edited")
+	return tgbotapi.EditMessageTextConfig{}
+}
+
+// reset забывает отправленные сообщения, чтобы проверять только следующий шаг
+func (f *fakeSender) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = nil
+	f.requests = nil
+}
+
+// requireMessage проверяет, что среди отправленных есть сообщение с подстрокой
+func (f *fakeSender) requireMessage(t *testing.T, substring string) {
+	t.Helper()
+
+	messages := f.messages()
+	for _, text := range messages {
+		if strings.Contains(text, substring) {
+			return
+		}
+	}
+	t.Fatalf("no message contains %q, sent: %q", substring, messages)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *BotHandler) handleSubscribe(chatID int64, text string) {
+	status, statistics := true, true
+
+	parts := strings.Fields(text)
+	if len(parts) > 1 {
+		switch strings.ToLower(parts[1]) {
+		case "статус":
+			statistics = false
+		case "статистика":
+			status = false
+		default:
+			h.sendError(chatID, "Используйте: /subscribe [статус|статистика]")
+			return
+		}
+	}
+
+	if err := h.db.Subscribe(chatID, status, statistics); err != nil {
+		h.sendError(chatID, "Ошибка оформления подписки: "+err.Error())
+		return
+	}
+
+	var reports []string
+	if status {
+		reports = append(reports, "статус подчиненных")
+	}
+	if statistics {
+		reports = append(reports, "статистика за день")
+	}
+
+	message := "🔔 Подписка оформлена: " + strings.Join(reports, " и ")
+	if len(h.config.SummaryTimes) > 0 {
+		message += "\nВремя рассылки: " + strings.Join(h.config.SummaryTimes, ", ")
+	} else {
+		message += "\n⚠️ Время рассылки не настроено (SUMMARY_TIMES)"
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	h.bot.Send(msg)
+}
+
+func (h *BotHandler) handleUnsubscribe(chatID int64) {
+	removed, err := h.db.Unsubscribe(chatID)
+	if err != nil {
+		h.sendError(chatID, "Ошибка отмены подписки: "+err.Error())
+		return
+	}
+
+	message := "🔕 Подписка отменена"
+	if !removed {
+		message = "Вы не были подписаны на рассылку"
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message)
+	h.bot.Send(msg)
+}
+
+// SendScheduledSummary рассылает отчёты всем подписанным чатам. Вызывается планировщиком
+func (h *BotHandler) SendScheduledSummary() {
+	subscriptions, err := h.db.GetSubscriptions()
+	if err != nil {
+		log.Printf("Error getting subscriptions: %v", err)
+		return
+	}
+
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	now := time.Now().In(h.config.Location)
+
+	// Отчёты одинаковы для всех подписчиков, поэтому формируем их один раз
+	var statusMessage, statisticsMessage string
+	for _, sub := range subscriptions {
+		if sub.Status && statusMessage == "" {
+			if statusMessage, err = h.buildStatusMessage(now); err != nil {
+				log.Printf("Error building status report: %v", err)
+				return
+			}
+		}
+		if sub.Statistics && statisticsMessage == "" {
+			if statisticsMessage, err = h.buildStatisticsMessage(now); err != nil {
+				log.Printf("Error building statistics report: %v", err)
+				return
+			}
+		}
+	}
+
+	for _, sub := range subscriptions {
+		if sub.Status {
+			h.sendScheduledMessage(sub.ChatID, statusMessage)
+		}
+		if sub.Statistics {
+			h.sendScheduledMessage(sub.ChatID, statisticsMessage)
+		}
+	}
+
+	log.Printf("Scheduled summary sent to %d chats", len(subscriptions))
+}
+
+func (h *BotHandler) sendScheduledMessage(chatID int64, message string) {
+	msg := tgbotapi.NewMessage(chatID, "⏰ "+message)
+	msg.ParseMode = "Markdown"
+	if _, err := h.bot.Send(msg); err != nil {
+		log.Printf("Error sending scheduled message to %d: %v", chatID, err)
+	}
+}
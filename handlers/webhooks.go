@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleWebhookCommand управляет вебхуками: /webhook add <url> | list | remove <id>
+func (h *BotHandler) handleWebhookCommand(key sessionKey, text string) {
+	chatID := key.ChatID
+	if !h.checkAdmin(key) {
+		return
+	}
+
+	usage := "Использование: /webhook add <url> | /webhook list | /webhook remove <id>"
+	args := strings.Fields(strings.TrimPrefix(text, "/webhook"))
+	if len(args) == 0 {
+		h.sendError(chatID, usage)
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		// Секрет подписи показывается при создании, поэтому не выводим его в группах
+		if chatID != key.UserID {
+			h.sendError(chatID, "Добавляйте вебхуки в личном чате с ботом")
+			return
+		}
+		if len(args) < 2 || !isWebhookURL(args[1]) {
+			h.sendError(chatID, "Укажите адрес http(s), например: /webhook add https://portal.example.ru/hooks/attendance")
+			return
+		}
+
+		webhook, err := h.db.AddWebhook(args[1], key.UserID)
+		if err != nil {
+			h.sendError(chatID, "Ошибка добавления вебхука: "+err.Error())
+			return
+		}
+
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf(
+			"🔗 Вебхук %d добавлен: %s\n\nСекрет для проверки подписи (сохраните, повторно он показан не будет):\n\n`%s`\n\n"+
+				"Каждое событие приходит POST запросом с JSON телом, подпись - в заголовке X-Webhook-Signature: sha256=<HMAC-SHA256 тела>",
+			webhook.ID, tgbotapi.EscapeText(tgbotapi.ModeMarkdown, webhook.URL), webhook.Secret))
+		msg.ParseMode = "Markdown"
+		msg.DisableWebPagePreview = true
+		h.bot.Send(msg)
+
+	case "list":
+		webhooks, err := h.db.GetWebhooks()
+		if err != nil {
+			h.sendError(chatID, "Ошибка получения вебхуков: "+err.Error())
+			return
+		}
+		if len(webhooks) == 0 {
+			h.sendError(chatID, "Вебхуков нет")
+			return
+		}
+
+		message := "🔗 Вебхуки:\n\n"
+		for _, webhook := range webhooks {
+			message += fmt.Sprintf("%d. %s - добавлен %s, в очереди: %d, не доставлено: %d\n",
+				webhook.ID, webhook.URL, webhook.CreatedAt.Format("02.01.2006"), webhook.Pending, webhook.Failed)
+		}
+		msg := tgbotapi.NewMessage(chatID, message)
+		msg.DisableWebPagePreview = true
+		h.bot.Send(msg)
+
+	case "remove":
+		if len(args) < 2 {
+			h.sendError(chatID, "Укажите номер вебхука: /webhook remove <id>")
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			h.sendError(chatID, "Неверный номер вебхука")
+			return
+		}
+
+		removed, err := h.db.RemoveWebhook(id)
+		if err != nil {
+			h.sendError(chatID, "Ошибка удаления вебхука: "+err.Error())
+			return
+		}
+		if !removed {
+			h.sendError(chatID, "Вебхука с таким номером нет")
+			return
+		}
+		h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Вебхук %d удалён", id)))
+
+	default:
+		h.sendError(chatID, usage)
+	}
+}
+
+// isWebhookURL проверяет, что адрес - абсолютный http(s) URL
+func isWebhookURL(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -1,87 +1,59 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
+	"whereismychildren/app"
+	"whereismychildren/backup"
 	"whereismychildren/config"
 	"whereismychildren/database"
-	"whereismychildren/handlers"
-
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 func main() {
 	// Загрузка конфигурации
 	cfg := config.Load()
-	if cfg.TelegramToken == "" {
-		log.Fatal("TELEGRAM_TOKEN is required")
-	}
-
-	if len(cfg.AdminIDs) == 0 {
-		log.Println("Warning: ADMIN_IDS not set, some commands will be unavailable")
-	}
-
-	// Инициализация базы данных
-	db, err := database.NewDB(cfg.DBPath)
-	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
-	}
-	defer db.Close()
-
-	// Инициализация бота
-	bot, err := tgbotapi.NewBotAPI(cfg.TelegramToken)
-	if err != nil {
-		log.Fatalf("Failed to create bot: %v", err)
-	}
 
-	bot.Debug = true
-	log.Printf("Authorized on account %s", bot.Self.UserName)
-
-	// Инициализация обработчика с конфигом
-	handler := handlers.NewBotHandler(bot, db, cfg)
-
-	// Настройка обновлений
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-
-	updates := bot.GetUpdatesChan(u)
-
-	// Обработка сообщений
-	for update := range updates {
-		if update.CallbackQuery != nil {
-			handler.HandleCallback(update)
-			continue
+	// Восстановление базы из резервной копии: whereismychildren restore backups/bot-20261018-030000.db
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := restore(cfg, os.Args[2:]); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
 
-		if update.Message != nil {
-			// Проверяем права для административных команд
-			if isAdminCommand(update.Message.Text) && !cfg.IsAdmin(update.Message.From.ID) {
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❌ У вас нет прав для выполнения этой команды")
-				bot.Send(msg)
-				continue
-			}
+	// Останавливаемся по Ctrl+C или сигналу завершения, закрывая базу и серверы
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-			handler.HandleMessage(update)
-		}
+	if err := app.Run(ctx, cfg); err != nil {
+		log.Fatal(err)
 	}
 }
 
-// isAdminCommand проверяет, является ли команда административной
-func isAdminCommand(text string) bool {
-	if text == "" {
-		return false
+// restore заменяет базу DB_PATH указанной резервной копией. Бот должен быть остановлен
+func restore(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: restore <backup file>")
+	}
+	switch strings.ToLower(cfg.DBDriver) {
+	case database.DriverPostgres, "postgresql", "pg":
+		return database.ErrBackupUnsupported
 	}
 
-	adminCommands := []string{
-		"/add_excel",
-		"/stat excel",
+	previous, err := backup.Restore(cfg.DBDriver, args[0], cfg.DBPath)
+	if err != nil {
+		return err
 	}
 
-	for _, cmd := range adminCommands {
-		if strings.HasPrefix(text, cmd) {
-			return true
-		}
+	log.Printf("Database %s restored from %s", cfg.DBPath, args[0])
+	if previous != "" {
+		log.Printf("Previous database saved to %s", previous)
 	}
-	return false
+	return nil
 }
@@ -0,0 +1,194 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"whereismychildren/database"
+
+	"github.com/go-pdf/fpdf"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+const (
+	fontFamily = "GoFont"
+	rowHeight  = 7.0
+)
+
+// Ширина колонок таблицы статусов (мм), в сумме равна рабочей ширине листа A4
+var columnWidths = []float64{10, 70, 45, 18, 47}
+
+// DailyReport содержит данные для печатного отчёта о присутствии за день
+type DailyReport struct {
+	Date        time.Time
+	Statuses    []database.SubordinateStatus
+	Events      []database.Absence // все уходы и деятельность за день в порядке времени
+	GeneratedAt time.Time
+}
+
+// GenerateDailyPDF формирует PDF-отчёт во временном файле и возвращает путь к нему
+func GenerateDailyPDF(r DailyReport) (string, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// Шрифты Go поддерживают кириллицу и встраиваются без внешних файлов
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	pdf.SetMargins(10, 15, 10)
+	pdf.SetAutoPageBreak(false, 15)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(fontFamily, "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Стр. %d из {nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+
+	// Заголовок
+	pdf.SetFont(fontFamily, "B", 16)
+	pdf.CellFormat(0, 10, "Ежедневный отчёт о присутствии", "", 1, "C", false, 0, "")
+	pdf.SetFont(fontFamily, "", 11)
+	pdf.CellFormat(0, 6, "Дата: "+r.Date.Format("02.01.2006"), "", 1, "C", false, 0, "")
+	pdf.SetFont(fontFamily, "", 9)
+	pdf.CellFormat(0, 5, "Сформирован: "+r.GeneratedAt.Format("02.01.2006 15:04"), "", 1, "C", false, 0, "")
+	pdf.Ln(4)
+
+	// Таблица статусов
+	writeTableHeader(pdf)
+	pdf.SetFont(fontFamily, "", 9)
+	for i, item := range r.Statuses {
+		ensureSpace(pdf, rowHeight, true)
+
+		name := fmt.Sprintf("%s %s %s", item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName)
+		status, eventTime, note := describeStatus(item)
+
+		cells := []string{fmt.Sprintf("%d", i+1), name, status, eventTime, note}
+		aligns := []string{"C", "L", "L", "C", "L"}
+		for j, text := range cells {
+			pdf.CellFormat(columnWidths[j], rowHeight, fitText(pdf, text, columnWidths[j]-2), "1", 0, aligns[j], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	if len(r.Statuses) == 0 {
+		pdf.CellFormat(sum(columnWidths), rowHeight, "Нет добавленных подчиненных", "1", 1, "C", false, 0, "")
+	}
+
+	// Итоги
+	present, left, activity := database.CountStatuses(r.Statuses)
+	ensureSpace(pdf, 20, false)
+	pdf.Ln(4)
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(0, 6, "Итого", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Всего: %d   На месте: %d   Ушли: %d   Внеплановая деятельность: %d",
+		len(r.Statuses), present, left, activity), "", 1, "L", false, 0, "")
+
+	// Все уходы и внеплановая деятельность за день в хронологическом порядке
+	ensureSpace(pdf, 14, false)
+	pdf.Ln(4)
+	pdf.SetFont(fontFamily, "B", 11)
+	pdf.CellFormat(0, 6, "События за день", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 10)
+	if len(r.Events) == 0 {
+		pdf.CellFormat(0, 6, "Нет данных об уходах и внеплановой деятельности за этот день.", "", 1, "L", false, 0, "")
+	}
+	for _, item := range r.Events {
+		ensureSpace(pdf, 6, false)
+		event := "уход"
+		if item.Kind == database.KindActivity {
+			event = "внеплановая деятельность: " + item.Description
+		}
+		line := fmt.Sprintf("%s  %s %s %s - %s", item.Start.Format("15:04"),
+			item.Subordinate.LastName, item.Subordinate.FirstName, item.Subordinate.MiddleName, event)
+		pdf.CellFormat(0, 6, fitText(pdf, line, sum(columnWidths)-2), "", 1, "L", false, 0, "")
+	}
+
+	// Строка для подписи
+	ensureSpace(pdf, 30, false)
+	pdf.Ln(12)
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.CellFormat(0, 6, "Ответственный: ______________________ /______________________/", "", 1, "L", false, 0, "")
+	pdf.SetFont(fontFamily, "", 8)
+	pdf.CellFormat(35, 4, "", "", 0, "L", false, 0, "")
+	pdf.CellFormat(55, 4, "подпись", "", 0, "C", false, 0, "")
+	pdf.CellFormat(50, 4, "расшифровка", "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+	pdf.SetFont(fontFamily, "", 10)
+	pdf.CellFormat(0, 6, "Дата: «____» ______________ 20____ г.", "", 1, "L", false, 0, "")
+
+	filename := fmt.Sprintf("report_%s_%s.pdf", r.Date.Format("20060102"), time.Now().Format("150405"))
+	path := filepath.Join(os.TempDir(), filename)
+
+	if err := pdf.OutputFileAndClose(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func writeTableHeader(pdf *fpdf.Fpdf) {
+	headers := []string{"№", "ФИО", "Статус", "Время", "Примечание"}
+	pdf.SetFont(fontFamily, "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, header := range headers {
+		pdf.CellFormat(columnWidths[i], rowHeight, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(fontFamily, "", 9)
+}
+
+// ensureSpace переносит вывод на новую страницу, если до нижнего поля осталось меньше height мм
+func ensureSpace(pdf *fpdf.Fpdf, height float64, tableHeader bool) {
+	_, pageHeight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+height <= pageHeight-bottom {
+		return
+	}
+
+	pdf.AddPage()
+	if tableHeader {
+		writeTableHeader(pdf)
+	}
+}
+
+func describeStatus(item database.SubordinateStatus) (status, eventTime, note string) {
+	switch item.Status {
+	case database.StatusActivity:
+		return "Внеплановая", item.Activity.ActivityTime.Format("15:04"), item.Activity.Description
+	case database.StatusLeft:
+		return "Ушёл", item.LeaveTime.Format("15:04"), ""
+	case database.StatusReturned:
+		if item.Activity != nil {
+			note = item.Activity.ActivityTime.Format("15:04") + " " + item.Activity.Description
+		} else {
+			note = "Ушёл в " + item.LeaveTime.Format("15:04")
+		}
+		return "Вернулся", item.ReturnedAt.Format("15:04"), note
+	default:
+		return "На месте", "", ""
+	}
+}
+
+// fitText обрезает текст по ширине ячейки, добавляя многоточие
+func fitText(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+func sum(values []float64) float64 {
+	var total float64
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,109 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Scheduler запускает задачи ежедневно в заданное время или с фиксированным интервалом
+type Scheduler struct {
+	location *time.Location
+	jobs     []job
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+type job struct {
+	name     string
+	hour     int
+	minute   int
+	interval time.Duration
+	fn       func()
+}
+
+func New(location *time.Location) *Scheduler {
+	if location == nil {
+		location = time.Local
+	}
+	return &Scheduler{
+		location: location,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Daily добавляет задачу, выполняемую каждый день в указанное время (ЧЧ:ММ)
+func (s *Scheduler) Daily(name, at string, fn func()) error {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return fmt.Errorf("неверное время %q для задачи %s: %v", at, name, err)
+	}
+
+	s.jobs = append(s.jobs, job{name: name, hour: t.Hour(), minute: t.Minute(), fn: fn})
+	return nil
+}
+
+// Every добавляет задачу, выполняемую с заданным интервалом
+func (s *Scheduler) Every(name string, interval time.Duration, fn func()) {
+	s.jobs = append(s.jobs, job{name: name, interval: interval, fn: fn})
+}
+
+// Start запускает все добавленные задачи в фоне
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.run(j)
+	}
+	log.Printf("Scheduler started with %d jobs (%s)", len(s.jobs), s.location)
+}
+
+// Stop останавливает планировщик и дожидается завершения выполняющихся задач
+func (s *Scheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(j job) {
+	defer s.wg.Done()
+
+	for {
+		wait := j.interval
+		if wait == 0 {
+			wait = time.Until(nextRun(time.Now().In(s.location), j.hour, j.minute))
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			s.execute(j)
+		case <-s.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// execute выполняет задачу, не позволяя панике остановить планировщик
+func (s *Scheduler) execute(j job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Scheduled job %s panicked: %v", j.name, r)
+		}
+	}()
+
+	// Интервальные задачи выполняются часто, поэтому логируем только ежедневные
+	if j.interval == 0 {
+		log.Printf("Running scheduled job: %s", j.name)
+	}
+	j.fn()
+}
+
+// nextRun возвращает ближайший момент после now с указанным временем суток
+func nextRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, now.Location())
+	}
+	return next
+}
@@ -0,0 +1,300 @@
+// Package telegramtest - локальная замена Telegram Bot API для интеграционных тестов.
+// Сервер отвечает на методы, которыми пользуется бот, хранит отправленные ботом сообщения
+// и отдаёт бота обновления от имени пользователей, не обращаясь к сети
+package telegramtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Token - токен, который сервер принимает от бота
+	Token = "123456:TEST-TOKEN"
+	// BotUserName - имя бота, возвращаемое getMe
+	BotUserName = "test_whereismychildren_bot"
+	// Сколько getUpdates ждёт новых обновлений, прежде чем вернуть пустой ответ
+	pollWait = 100 * time.Millisecond
+)
+
+// Sent - сообщение или файл, отправленные ботом
+type Sent struct {
+	Method      string
+	MessageID   int
+	ChatID      int64
+	Text        string
+	Caption     string
+	FileName    string
+	ReplyMarkup string
+}
+
+// Server эмулирует методы Bot API: getMe, getUpdates, sendMessage, sendDocument, sendPhoto,
+// deleteMessage, getFile, answerInlineQuery, answerCallbackQuery и скачивание файлов
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	updates       []tgbotapi.Update
+	nextUpdateID  int
+	nextMessageID int
+	sent          []Sent
+	files         map[string][]byte
+	newUpdate     chan struct{}
+	newSent       chan struct{}
+}
+
+// NewServer запускает сервер; он останавливается по окончании теста
+func NewServer(t testing.TB) *Server {
+	s := &Server{
+		nextUpdateID:  1,
+		nextMessageID: 1,
+		files:         make(map[string][]byte),
+		newUpdate:     make(chan struct{}, 1),
+		newSent:       make(chan struct{}, 1),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SendText отправляет боту текстовое сообщение от пользователя в личном чате
+func (s *Server) SendText(userID int64, text string) {
+	s.addUpdate(tgbotapi.Update{Message: s.newMessage(userID, text)})
+}
+
+// SendDocument отправляет боту файл с подписью от пользователя в личном чате
+func (s *Server) SendDocument(userID int64, caption, fileName string, content []byte) {
+	s.mu.Lock()
+	fileID := fmt.Sprintf("file-%d", len(s.files)+1)
+	s.files[fileID] = content
+	s.mu.Unlock()
+
+	message := s.newMessage(userID, "")
+	message.Caption = caption
+	message.Document = &tgbotapi.Document{FileID: fileID, FileUniqueID: fileID, FileName: fileName, FileSize: len(content)}
+	s.addUpdate(tgbotapi.Update{Message: message})
+}
+
+// PressButton нажимает inline-кнопку под сообщением бота
+func (s *Server) PressButton(userID int64, messageID int, data string) {
+	s.mu.Lock()
+	id := s.nextUpdateID
+	s.mu.Unlock()
+
+	s.addUpdate(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   strconv.Itoa(id),
+		From: &tgbotapi.User{ID: userID, FirstName: "Test"},
+		Message: &tgbotapi.Message{
+			MessageID: messageID,
+			Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		},
+		Data: data,
+	}})
+}
+
+// Sent возвращает всё, что бот отправил на данный момент
+func (s *Server) Sent() []Sent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Sent(nil), s.sent...)
+}
+
+// WaitFor ждёт сообщение бота, текст или подпись которого содержит substring
+func (s *Server) WaitFor(t testing.TB, substring string, timeout time.Duration) Sent {
+	t.Helper()
+
+	deadline := time.After(timeout)
+	for {
+		for _, sent := range s.Sent() {
+			if strings.Contains(sent.Text, substring) || strings.Contains(sent.Caption, substring) {
+				return sent
+			}
+		}
+
+		select {
+		case <-s.newSent:
+		case <-deadline:
+			var texts []string
+			for _, sent := range s.Sent() {
+				texts = append(texts, sent.Method+": "+sent.Text+sent.Caption)
+			}
+			t.Fatalf("bot did not send %q within %s, sent: %q", substring, timeout, texts)
+			return Sent{}
+		}
+	}
+}
+
+func (s *Server) newMessage(userID int64, text string) *tgbotapi.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextMessageID++
+	return &tgbotapi.Message{
+		MessageID: s.nextMessageID,
+		From:      &tgbotapi.User{ID: userID, FirstName: "Test"},
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+}
+
+func (s *Server) addUpdate(update tgbotapi.Update) {
+	s.mu.Lock()
+	update.UpdateID = s.nextUpdateID
+	s.nextUpdateID++
+	s.updates = append(s.updates, update)
+	s.mu.Unlock()
+
+	notify(s.newUpdate)
+}
+
+// notify будит ожидающего, не блокируясь, если сигнал уже отправлен
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if path, found := strings.CutPrefix(r.URL.Path, "/file/bot"+Token+"/"); found {
+		s.serveFile(w, r, path)
+		return
+	}
+
+	method, found := strings.CutPrefix(r.URL.Path, "/bot"+Token+"/")
+	if !found {
+		writeResult(w, http.StatusUnauthorized, nil, errors.New("Unauthorized"))
+		return
+	}
+
+	if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		writeResult(w, http.StatusBadRequest, nil, err)
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, http.StatusOK, tgbotapi.User{ID: 1, IsBot: true, FirstName: "Test", UserName: BotUserName}, nil)
+	case "getUpdates":
+		writeResult(w, http.StatusOK, s.pollUpdates(r), nil)
+	case "sendMessage", "sendDocument", "sendPhoto":
+		writeResult(w, http.StatusOK, s.recordSent(method, r), nil)
+	case "deleteMessage", "answerCallbackQuery", "answerInlineQuery":
+		writeResult(w, http.StatusOK, true, nil)
+	case "getFile":
+		fileID := r.FormValue("file_id")
+		s.mu.Lock()
+		content, exists := s.files[fileID]
+		s.mu.Unlock()
+		if !exists {
+			writeResult(w, http.StatusBadRequest, nil, errors.New("Bad Request: invalid file_id"))
+			return
+		}
+		writeResult(w, http.StatusOK, tgbotapi.File{
+			FileID: fileID, FileUniqueID: fileID, FileSize: len(content), FilePath: "documents/" + fileID,
+		}, nil)
+	default:
+		writeResult(w, http.StatusNotFound, nil, fmt.Errorf("Not Found: method %s is not emulated", method))
+	}
+}
+
+// pollUpdates возвращает обновления начиная с offset; если их нет, недолго ждёт новых
+func (s *Server) pollUpdates(r *http.Request) []tgbotapi.Update {
+	offset, _ := strconv.Atoi(r.FormValue("offset"))
+
+	for attempt := 0; attempt < 2; attempt++ {
+		s.mu.Lock()
+		var result []tgbotapi.Update
+		for _, update := range s.updates {
+			if update.UpdateID >= offset {
+				result = append(result, update)
+			}
+		}
+		s.mu.Unlock()
+
+		if len(result) > 0 || attempt == 1 {
+			return result
+		}
+
+		select {
+		case <-s.newUpdate:
+		case <-time.After(pollWait):
+		case <-r.Context().Done():
+		}
+	}
+	return nil
+}
+
+func (s *Server) recordSent(method string, r *http.Request) tgbotapi.Message {
+	chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	sent := Sent{
+		Method:      method,
+		ChatID:      chatID,
+		Text:        r.FormValue("text"),
+		Caption:     r.FormValue("caption"),
+		ReplyMarkup: r.FormValue("reply_markup"),
+	}
+	if r.MultipartForm != nil {
+		for _, files := range r.MultipartForm.File {
+			if len(files) > 0 {
+				sent.FileName = files[0].Filename
+			}
+		}
+	}
+
+	s.mu.Lock()
+	s.nextMessageID++
+	sent.MessageID = s.nextMessageID
+	s.sent = append(s.sent, sent)
+	s.mu.Unlock()
+
+	notify(s.newSent)
+
+	return tgbotapi.Message{
+		MessageID: sent.MessageID,
+		Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      sent.Text,
+		Caption:   sent.Caption,
+	}
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	fileID := strings.TrimPrefix(path, "documents/")
+
+	s.mu.Lock()
+	content, exists := s.files[fileID]
+	s.mu.Unlock()
+
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(content)
+}
+
+func writeResult(w http.ResponseWriter, status int, result interface{}, err error) {
+	response := map[string]interface{}{"ok": err == nil}
+	if err != nil {
+		response["error_code"] = status
+		response["description"] = err.Error()
+	} else {
+		response["result"] = result
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameToken - слово поискового запроса по ФИО
+type NameToken struct {
+	Text    string // нормализованное слово без точки
+	Initial bool   // инициал: "И." или одна буква
+}
+
+// NormalizeName приводит слово к виду для сравнения: нижний регистр, ё заменена на е,
+// без знаков препинания по краям
+func NormalizeName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.ReplaceAll(s, "ё", "е")
+	return strings.TrimFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NameTokens разбивает запрос на слова, отделяя инициалы: "Иванов И.П." -> Иванов, И, П
+func NameTokens(query string) []NameToken {
+	var tokens []NameToken
+	for _, word := range strings.Fields(strings.ReplaceAll(query, ".", ". ")) {
+		text := NormalizeName(word)
+		if text == "" {
+			continue
+		}
+		initial := len([]rune(text)) == 1
+		tokens = append(tokens, NameToken{Text: text, Initial: initial})
+	}
+	return tokens
+}
+
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p",
+	'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch",
+	'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// Transliterate переводит нормализованное имя в латиницу и сглаживает различия
+// распространённых вариантов записи (Alexey/Aleksei, Jurij/Yurii), чтобы "Ivanov" совпадал с "Иванов"
+func Transliterate(s string) string {
+	var latin strings.Builder
+	for _, r := range s {
+		if t, ok := cyrillicToLatin[r]; ok {
+			latin.WriteString(t)
+		} else {
+			latin.WriteRune(r)
+		}
+	}
+
+	replacer := strings.NewReplacer("x", "ks", "w", "v", "ph", "f", "ck", "k")
+	runes := []rune(replacer.Replace(latin.String()))
+
+	var result strings.Builder
+	for i, r := range runes {
+		nextIsVowel := i+1 < len(runes) && strings.ContainsRune("aeiou", runes[i+1])
+		switch {
+		case (r == 'y' || r == 'j') && !nextIsVowel:
+			result.WriteRune('i')
+		case r == 'j':
+			result.WriteRune('y')
+		default:
+			result.WriteRune(r)
+		}
+	}
+	return result.String()
+}
+
+// Levenshtein возвращает расстояние редактирования между строками (по символам)
+func Levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// ScoreName оценивает совпадение запроса с ФИО: 0 - не совпадает, чем больше, тем точнее.
+// Каждое слово запроса должно совпасть со своей частью ФИО; инициалы сравниваются с именем и отчеством
+func ScoreName(tokens []NameToken, lastName, firstName, middleName string) int {
+	if len(tokens) == 0 {
+		return 0
+	}
+
+	parts := []string{NormalizeName(lastName), NormalizeName(firstName), NormalizeName(middleName)}
+	used := make([]bool, len(parts))
+
+	total := 0
+	for i, token := range tokens {
+		best, bestPart := 0, -1
+		for p, part := range parts {
+			if used[p] || part == "" {
+				continue
+			}
+
+			var score int
+			if token.Initial {
+				if p > 0 && strings.HasPrefix(Transliterate(part), Transliterate(token.Text)) {
+					score = 30
+				}
+			} else {
+				score = scoreWord(token.Text, part)
+			}
+
+			// Фамилию обычно пишут первой
+			if score > 0 && p == 0 && i == 0 {
+				score += 5
+			}
+			if score > best {
+				best, bestPart = score, p
+			}
+		}
+
+		if best == 0 {
+			return 0
+		}
+		used[bestPart] = true
+		total += best
+	}
+
+	return total
+}
+
+// ExactNameScore - наименьшая оценка ScoreName, при которой каждое слово запроса совпало с частью ФИО
+// точно (с точностью до регистра и ё/е), а инициалы - с началом имени или отчества. Начало слова,
+// латиница и опечатки дают меньше даже с надбавкой за фамилию
+func ExactNameScore(tokens []NameToken) int {
+	score := 0
+	for _, token := range tokens {
+		if token.Initial {
+			score += 30
+		} else {
+			score += 100
+		}
+	}
+	return score
+}
+
+// scoreWord сравнивает слово запроса с частью ФИО: точное совпадение, транслитерация,
+// начало слова и опечатки (1 ошибка для слов от 4 букв, 2 - от 7)
+func scoreWord(query, word string) int {
+	if query == word {
+		return 100
+	}
+
+	latinQuery, latinWord := Transliterate(query), Transliterate(word)
+	if latinQuery == latinWord {
+		return 90
+	}
+
+	length := len([]rune(query))
+	if length >= 3 && (strings.HasPrefix(word, query) || strings.HasPrefix(latinWord, latinQuery)) {
+		return 70
+	}
+
+	allowed := 0
+	switch {
+	case length >= 7:
+		allowed = 2
+	case length >= 4:
+		allowed = 1
+	}
+
+	distance := min(Levenshtein(query, word), Levenshtein(latinQuery, latinWord))
+	if distance <= allowed {
+		return 60 - 10*distance
+	}
+	return 0
+}
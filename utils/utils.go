@@ -78,6 +78,10 @@ func ParseDate(dateStr string) (time.Time, error) {
 				return t, nil
 			}
 		}
+		// Дата без года - в текущем году
+		if t, err := time.Parse("02.01", dateStr); err == nil {
+			return time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
 		return time.Time{}, fmt.Errorf("неверный формат даты")
 	}
 }
@@ -118,3 +122,81 @@ func GetMoscowTime() time.Time {
 	}
 	return time.Now().In(location)
 }
+
+// ParsePeriod разбирает период для статистики: "неделя", "месяц", дату
+// или диапазон "ДД.ММ.ГГГГ-ДД.ММ.ГГГГ" (пробелы вокруг дефиса допускаются). Возвращает границы включительно.
+func ParsePeriod(period string) (time.Time, time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	period = strings.TrimSpace(period)
+
+	switch strings.ToLower(period) {
+	case "", "неделя":
+		return today.AddDate(0, 0, -6), today, nil
+	case "месяц":
+		return today.AddDate(0, 0, -29), today, nil
+	}
+
+	// Диапазон дат через дефис (даты вида 2006-01-02 сами содержат дефис, их не разбиваем)
+	if fromStr, toStr, found := strings.Cut(period, "-"); found && len(strings.TrimSpace(fromStr)) != 4 {
+		fromStr, toStr = strings.TrimSpace(fromStr), strings.TrimSpace(toStr)
+		from, err := ParseDate(fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to, err := ParseDate(toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		if to.Before(from) {
+			return time.Time{}, time.Time{}, fmt.Errorf("конец периода раньше начала")
+		}
+		return from, to, nil
+	}
+
+	date, err := ParseDate(period)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return date, date, nil
+}
+
+// ExtractReturnTime вырезает из текста ожидаемое время возвращения в формате "до ЧЧ:ММ".
+// Возвращает текст без этой части и время с текущей датой
+func ExtractReturnTime(input string) (string, time.Time, bool, error) {
+	returnRegex := regexp.MustCompile(`(?i)(^|\s)до\s+(\d{1,2}):(\d{2})`)
+	matches := returnRegex.FindStringSubmatchIndex(input)
+	if matches == nil {
+		return input, time.Time{}, false, nil
+	}
+
+	hour := parseInt(input[matches[4]:matches[5]])
+	minute := parseInt(input[matches[6]:matches[7]])
+	if hour > 23 || minute > 59 {
+		return input, time.Time{}, true, fmt.Errorf("неверное время возвращения: %02d:%02d", hour, minute)
+	}
+
+	now := time.Now()
+	cleaned := strings.TrimSpace(input[:matches[0]] + " " + input[matches[1]:])
+	return cleaned, time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, time.Local), true, nil
+}
+
+// NormalizePhone приводит номер телефона к виду 79991234567 для сравнения.
+// Российские номера с 8 в начале и без кода страны приводятся к коду 7
+func NormalizePhone(phone string) string {
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+
+	result := digits.String()
+	switch {
+	case len(result) == 11 && result[0] == '8':
+		return "7" + result[1:]
+	case len(result) == 10 && result[0] == '9':
+		return "7" + result
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriodRange(t *testing.T) {
+	year := time.Now().Year()
+
+	tests := []struct {
+		period   string
+		from, to time.Time
+	}{
+		{"01.09.2026-15.09.2026", date(2026, 9, 1), date(2026, 9, 15)},
+		{"01.09.2026 - 15.09.2026", date(2026, 9, 1), date(2026, 9, 15)},
+		{" 01.09.26 -15.09.26 ", date(2026, 9, 1), date(2026, 9, 15)},
+		{"01.09 - 15.09", date(year, 9, 1), date(year, 9, 15)},
+		{"2026-09-01", date(2026, 9, 1), date(2026, 9, 1)},
+	}
+
+	for _, tt := range tests {
+		from, to, err := ParsePeriod(tt.period)
+		if err != nil {
+			t.Errorf("ParsePeriod(%q): %v", tt.period, err)
+			continue
+		}
+		if !from.Equal(tt.from) || !to.Equal(tt.to) {
+			t.Errorf("ParsePeriod(%q) = %s - %s, expected %s - %s", tt.period,
+				from.Format("02.01.2006"), to.Format("02.01.2006"), tt.from.Format("02.01.2006"), tt.to.Format("02.01.2006"))
+		}
+	}
+}
+
+func TestParsePeriodRejectsReversedRange(t *testing.T) {
+	if _, _, err := ParsePeriod("15.09.2026 - 01.09.2026"); err == nil {
+		t.Fatal("expected an error for a period that ends before it starts")
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "иван", 4},
+		{"иванов", "иванов", 0},
+		{"ивонов", "иванов", 1},
+		{"иванов", "иванова", 1},
+		{"пётр", "петр", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := Levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := Levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, expected %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"иванов", "ivanov"},
+		{"щукин", "shchukin"},
+		{"юрий", "yurii"},
+		{"jurij", "yurii"},
+		{"yuriy", "yurii"},
+		{"алексей", "aleksei"},
+		{"alexey", "aleksei"},
+		{"ivanov", "ivanov"},
+	}
+
+	for _, tt := range tests {
+		if got := Transliterate(tt.name); got != tt.want {
+			t.Errorf("Transliterate(%q) = %q, expected %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScoreName(t *testing.T) {
+	tests := []struct {
+		query                           string
+		lastName, firstName, middleName string
+		want                            int
+	}{
+		// Точное совпадение, в том числе без учёта регистра и ё/е
+		{"Иванов", "Иванов", "Иван", "Иванович", 105},
+		{"иванов иван", "Иванов", "Иван", "Иванович", 205},
+		{"Семенов", "Семёнов", "Пётр", "", 105},
+		{"Семёнов Петр", "Семенов", "Петр", "", 205},
+		// Инициалы совпадают с именем и отчеством, но не с фамилией
+		{"Иванов И.И.", "Иванов", "Иван", "Иванович", 165},
+		{"Иванов И. П.", "Иванов", "Иван", "Иванович", 0},
+		{"И.", "Иванов", "Петр", "", 0},
+		// Латиница
+		{"Ivanov", "Иванов", "Иван", "Иванович", 95},
+		{"Ivanov Yurii", "Иванов", "Юрий", "", 185},
+		{"Иванo", "Иванов", "Петр", "", 75}, // последняя буква набрана в латинской раскладке
+		// Начало слова
+		{"Иван", "Иванов", "Иван", "Иванович", 100},
+		{"Ива", "Иванов", "Петр", "", 75},
+		// Опечатки: одна на слово от 4 букв, две - от 7
+		{"Ивонов", "Иванов", "Иван", "Иванович", 55},
+		{"Ивонова", "Иванов", "Петр", "", 45},
+		{"Констатинов", "Константинов", "Петр", "", 55},
+		{"Кнстатинов", "Константинов", "Петр", "", 45},
+		{"Ивнв", "Иванов", "Петр", "", 0},
+		{"Пертов", "Иванов", "Петр", "", 0},
+		// Каждое слово запроса должно найти свою часть ФИО
+		{"Иванов Сидоров", "Иванов", "Иван", "Иванович", 0},
+		{"", "Иванов", "Иван", "Иванович", 0},
+	}
+
+	for _, tt := range tests {
+		got := ScoreName(NameTokens(tt.query), tt.lastName, tt.firstName, tt.middleName)
+		if got != tt.want {
+			t.Errorf("ScoreName(%q, %s %s %s) = %d, expected %d",
+				tt.query, tt.lastName, tt.firstName, tt.middleName, got, tt.want)
+		}
+	}
+}
+
+func TestExactNameScoreSeparatesExactMatches(t *testing.T) {
+	tests := []struct {
+		query, lastName, firstName string
+		exact                      bool
+	}{
+		{"Иванов", "Иванов", "Иван", true},
+		{"Иванов И.", "Иванов", "Иван", true},
+		{"Семенов Петр", "Семёнов", "Пётр", true},
+		{"Ivanov", "Иванов", "Иван", false},
+		{"Ивонов", "Иванов", "Иван", false},
+		{"Ива", "Иванов", "Иван", false},
+	}
+
+	for _, tt := range tests {
+		tokens := NameTokens(tt.query)
+		score := ScoreName(tokens, tt.lastName, tt.firstName, "")
+		if exact := score >= ExactNameScore(tokens); exact != tt.exact {
+			t.Errorf("%q: score %d, exact threshold %d, expected exact=%v", tt.query, score, ExactNameScore(tokens), tt.exact)
+		}
+	}
+}
+
+func date(year, month, day int) time.Time {
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+}
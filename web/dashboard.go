@@ -0,0 +1,153 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/events"
+)
+
+// refreshInterval - как часто страница за сегодня обновляется сама
+const refreshInterval = 60
+
+// statusFilters - варианты фильтра по статусу в порядке отображения
+var statusFilters = []struct{ Value, Label string }{
+	{"", "Все"},
+	{database.StatusPresent, "На месте"},
+	{database.StatusLeft, "Ушли"},
+	{database.StatusActivity, "Внеплановая"},
+	{database.StatusReturned, "Вернулись"},
+}
+
+type dashboardRow struct {
+	Name    string
+	Group   string
+	Status  string
+	Label   string
+	Details string
+	Overdue bool
+}
+
+type dashboardPage struct {
+	Date            string
+	Today           bool
+	Group           string
+	Status          string
+	Groups          []string
+	StatusFilters   []struct{ Value, Label string }
+	Rows            []dashboardRow
+	Total           int
+	Present         int
+	Left            int
+	Activity        int
+	UpdatedAt       string
+	RefreshInterval int
+	EventTypes      []string
+}
+
+func (s *Server) dashboard(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	date := today
+	if value := query.Get("date"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, now.Location())
+		if err != nil {
+			s.renderMessage(w, http.StatusBadRequest, "Неверная дата")
+			return
+		}
+		date = parsed
+	}
+
+	statuses, err := s.db.GetStatusesForDate(date)
+	if err != nil {
+		log.Printf("Error getting statuses for dashboard: %v", err)
+		s.renderMessage(w, http.StatusInternalServerError, "Ошибка получения данных")
+		return
+	}
+
+	page := dashboardPage{
+		Date:            date.Format("2006-01-02"),
+		Today:           date.Equal(today),
+		Group:           query.Get("group"),
+		Status:          query.Get("status"),
+		StatusFilters:   statusFilters,
+		UpdatedAt:       now.Format("15:04:05"),
+		RefreshInterval: refreshInterval,
+		EventTypes:      events.Types,
+	}
+
+	groups := make(map[string]bool)
+	for _, item := range statuses {
+		if item.Subordinate.Group != "" {
+			groups[item.Subordinate.Group] = true
+		}
+	}
+	for group := range groups {
+		page.Groups = append(page.Groups, group)
+	}
+	sort.Strings(page.Groups)
+
+	// Итоги считаются по выбранной группе, чтобы совпадать с таблицей
+	var visible []database.SubordinateStatus
+	for _, item := range statuses {
+		if page.Group != "" && item.Subordinate.Group != page.Group {
+			continue
+		}
+		visible = append(visible, item)
+	}
+	page.Total = len(visible)
+	page.Present, page.Left, page.Activity = database.CountStatuses(visible)
+
+	for _, item := range visible {
+		if page.Status != "" && item.Status != page.Status {
+			continue
+		}
+		page.Rows = append(page.Rows, buildRow(item, now))
+	}
+
+	s.render(w, http.StatusOK, "dashboard.html", page)
+}
+
+// buildRow переводит статус подчиненного в строку таблицы
+func buildRow(item database.SubordinateStatus, now time.Time) dashboardRow {
+	sub := item.Subordinate
+	row := dashboardRow{
+		Name:   fmt.Sprintf("%s %s %s", sub.LastName, sub.FirstName, sub.MiddleName),
+		Group:  sub.Group,
+		Status: item.Status,
+	}
+
+	switch item.Status {
+	case database.StatusActivity:
+		row.Label = "Внеплановая"
+		row.Details = fmt.Sprintf("с %s - %s", item.Activity.ActivityTime.Format("15:04"), item.Activity.Description)
+	case database.StatusLeft:
+		row.Label = "Ушел"
+		row.Details = "в " + item.LeaveTime.Format("15:04")
+	case database.StatusReturned:
+		row.Label = "Вернулся"
+		row.Details = "в " + item.ReturnedAt.Format("15:04")
+	default:
+		row.Label = "На месте"
+	}
+
+	if item.Status == database.StatusActivity || item.Status == database.StatusLeft {
+		switch {
+		case item.StaysOut:
+			row.Details += ", остаётся вне"
+		case item.ExpectedReturn != nil && item.ExpectedReturn.Before(now):
+			row.Details += ", не вернулся к " + item.ExpectedReturn.Format("15:04")
+			row.Overdue = true
+		case item.ExpectedReturn != nil:
+			row.Details += ", вернётся к " + item.ExpectedReturn.Format("15:04")
+		}
+	}
+
+	return row
+}
@@ -0,0 +1,163 @@
+package web
+
+import (
+	"context"
+	"embed"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"whereismychildren/database"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+const (
+	sessionCookie = "wimc_session"
+	// Сессия рассчитана на компьютер дежурного, который открыт всю смену
+	sessionTTL = 24 * time.Hour
+	// Ссылка для входа, присланная ботом, действует недолго и только один раз
+	LoginLinkTTL = 15 * time.Minute
+)
+
+// Server - веб-панель только для чтения с таблицей "Где подчинённые".
+// Вход выполняется по одноразовой ссылке, которую присылает бот командой /dashboard
+type Server struct {
+	db        database.Store
+	server    *http.Server
+	templates *template.Template
+}
+
+func NewServer(db database.Store, addr string) *Server {
+	s := &Server{
+		db:        db,
+		templates: template.Must(template.ParseFS(templateFiles, "templates/*.html")),
+	}
+	// Потоки событий не завершаются сами, поэтому при остановке сервера закрываем их через контекст
+	ctx, cancel := context.WithCancel(context.Background())
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	s.server.RegisterOnShutdown(cancel)
+	return s
+}
+
+// Handler возвращает обработчик страниц панели
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /login", s.login)
+	mux.HandleFunc("GET /logout", s.logout)
+	mux.Handle("GET /{$}", s.requireSession(http.HandlerFunc(s.dashboard)))
+	// Страница за сегодня подписывается на поток и обновляется сразу после изменений
+	mux.Handle("GET /events", s.requireSession(s.db.Events()))
+	return mux
+}
+
+// Start запускает сервер в фоне
+func (s *Server) Start() {
+	go func() {
+		log.Printf("Web dashboard listening on %s", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Web dashboard error: %v", err)
+		}
+	}()
+}
+
+// Shutdown останавливает сервер, дожидаясь завершения текущих запросов
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// login гасит одноразовую ссылку из бота и открывает сессию браузера
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	userID, ok, err := s.db.UseLoginLink(r.URL.Query().Get("token"))
+	if err != nil {
+		log.Printf("Error using login link: %v", err)
+		s.renderMessage(w, http.StatusInternalServerError, "Не удалось выполнить вход, попробуйте ещё раз")
+		return
+	}
+	if !ok {
+		s.renderMessage(w, http.StatusUnauthorized,
+			"Ссылка недействительна: она уже использована или устарела. Получите новую командой /dashboard в боте")
+		return
+	}
+
+	if err := s.db.DeleteExpiredWebLogins(); err != nil {
+		log.Printf("Error deleting expired web logins: %v", err)
+	}
+
+	token, err := s.db.CreateWebSession(userID, sessionTTL)
+	if err != nil {
+		log.Printf("Error creating web session: %v", err)
+		s.renderMessage(w, http.StatusInternalServerError, "Не удалось выполнить вход, попробуйте ещё раз")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	// Убираем токен из адресной строки и истории браузера
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	// Удаляем сессию в базе: копия cookie (например, в другом браузере) тоже перестаёт действовать
+	if cookie, err := r.Cookie(sessionCookie); err == nil && cookie.Value != "" {
+		if err := s.db.DeleteWebSession(cookie.Value); err != nil {
+			log.Printf("Error deleting web session: %v", err)
+			s.renderMessage(w, http.StatusInternalServerError, "Не удалось выйти из панели, попробуйте ещё раз")
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+	s.renderMessage(w, http.StatusOK, "Вы вышли из панели. Для входа получите новую ссылку командой /dashboard в боте")
+}
+
+func (s *Server) requireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil || cookie.Value == "" {
+			s.renderMessage(w, http.StatusUnauthorized, "Для входа отправьте боту команду /dashboard и откройте присланную ссылку")
+			return
+		}
+
+		valid, err := s.db.ValidateWebSession(cookie.Value)
+		if err != nil {
+			log.Printf("Error validating web session: %v", err)
+			s.renderMessage(w, http.StatusInternalServerError, "Ошибка проверки сессии")
+			return
+		}
+		if !valid {
+			s.renderMessage(w, http.StatusUnauthorized, "Сессия истекла. Получите новую ссылку командой /dashboard в боте")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) render(w http.ResponseWriter, status int, name string, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := s.templates.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("Error rendering %s: %v", name, err)
+	}
+}
+
+func (s *Server) renderMessage(w http.ResponseWriter, status int, message string) {
+	s.render(w, status, "message.html", message)
+}
@@ -0,0 +1,167 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"whereismychildren/database"
+)
+
+const (
+	// Сколько доставок отправляется за один проход
+	batchSize = 50
+	// После стольких неудачных попыток доставка прекращается
+	maxAttempts = 10
+	// Пауза перед повтором удваивается с каждой попыткой: 30 с, 1 мин, 2 мин ... но не больше 6 ч
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	// Сколько времени проход тратит на один адрес. Адреса обслуживаются параллельно, поэтому медленный
+	// адрес не задерживает остальные, а его неотправленные доставки ждут следующего прохода
+	endpointBudget = 30 * time.Second
+)
+
+// Dispatcher отправляет события из очереди webhook_deliveries на зарегистрированные адреса.
+// Каждое событие - POST с JSON телом и заголовком X-Webhook-Signature: sha256=<HMAC тела>
+type Dispatcher struct {
+	db     database.Store
+	client *http.Client
+}
+
+func NewDispatcher(db database.Store) *Dispatcher {
+	return &Dispatcher{
+		db:     db,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// DeliverPending отправляет доставки, время которых наступило. Вызывается планировщиком.
+// Каждый вебхук получает свои события по порядку, разные вебхуки - параллельно
+func (d *Dispatcher) DeliverPending() {
+	deliveries, err := d.db.GetDueWebhookDeliveries(time.Now(), batchSize)
+	if err != nil {
+		log.Printf("Error getting webhook deliveries: %v", err)
+		return
+	}
+
+	var webhookIDs []int
+	byWebhook := make(map[int][]database.WebhookDelivery)
+	for _, delivery := range deliveries {
+		if _, ok := byWebhook[delivery.WebhookID]; !ok {
+			webhookIDs = append(webhookIDs, delivery.WebhookID)
+		}
+		byWebhook[delivery.WebhookID] = append(byWebhook[delivery.WebhookID], delivery)
+	}
+
+	var wg sync.WaitGroup
+	for _, id := range webhookIDs {
+		wg.Add(1)
+		go func(deliveries []database.WebhookDelivery) {
+			defer wg.Done()
+			d.deliverEndpoint(deliveries)
+		}(byWebhook[id])
+	}
+	wg.Wait()
+}
+
+// deliverEndpoint отправляет доставки одного вебхука по порядку, пока не истечёт endpointBudget.
+// После неудачи проход по адресу прекращается: следующие события ждут, пока не уйдёт предыдущее
+func (d *Dispatcher) deliverEndpoint(deliveries []database.WebhookDelivery) {
+	ctx, cancel := context.WithTimeout(context.Background(), endpointBudget)
+	defer cancel()
+
+	for i, delivery := range deliveries {
+		if ctx.Err() != nil {
+			log.Printf("Webhook %s is slow, %d deliveries postponed to the next pass", delivery.URL, len(deliveries)-i)
+			return
+		}
+		if !d.deliver(ctx, delivery) {
+			return
+		}
+	}
+}
+
+// deliver отправляет одну доставку и сохраняет результат. Возвращает false, если событие
+// не доставлено и будет отправлено повторно
+func (d *Dispatcher) deliver(ctx context.Context, delivery database.WebhookDelivery) bool {
+	err := d.send(ctx, delivery)
+	now := time.Now()
+
+	if err == nil {
+		if err := d.db.MarkWebhookDelivered(delivery.ID, now); err != nil {
+			log.Printf("Error marking webhook delivery %d: %v", delivery.ID, err)
+		}
+		return true
+	}
+
+	attempts := delivery.Attempts + 1
+	if attempts >= maxAttempts {
+		log.Printf("Webhook delivery %d to %s failed after %d attempts: %v", delivery.ID, delivery.URL, attempts, err)
+		// Событие больше не отправляется, и очередь за ним продолжает двигаться
+		if err := d.db.FailWebhookDelivery(delivery.ID, err.Error(), now); err != nil {
+			log.Printf("Error marking webhook delivery %d: %v", delivery.ID, err)
+		}
+		return true
+	}
+
+	log.Printf("Webhook delivery %d to %s failed (attempt %d): %v", delivery.ID, delivery.URL, attempts, err)
+	if err := d.db.RetryWebhookDelivery(delivery.ID, err.Error(), now.Add(backoff(attempts))); err != nil {
+		log.Printf("Error scheduling webhook delivery %d: %v", delivery.ID, err)
+	}
+	return false
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery database.WebhookDelivery) error {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "whereismychildren-webhooks")
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	// Номер доставки не меняется между повторами, по нему получатель отбрасывает дубликаты
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.ID))
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(delivery.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign возвращает HMAC-SHA256 тела запроса в шестнадцатеричном виде
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff возвращает паузу перед следующей попыткой после attempts неудачных
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
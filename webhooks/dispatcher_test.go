@@ -0,0 +1,178 @@
+package webhooks
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"whereismychildren/database"
+	"whereismychildren/database/dbtest"
+)
+
+// receiver - адрес вебхука, который запоминает номера полученных доставок и отвечает заданным статусом
+type receiver struct {
+	mu         sync.Mutex
+	status     int
+	deliveries []int
+	signatures []string
+	bodies     [][]byte
+}
+
+func newReceiver(t *testing.T) (*receiver, *httptest.Server) {
+	r := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		id, _ := strconv.Atoi(req.Header.Get("X-Webhook-Delivery"))
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.deliveries = append(r.deliveries, id)
+		r.signatures = append(r.signatures, req.Header.Get("X-Webhook-Signature"))
+		r.bodies = append(r.bodies, body)
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(server.Close)
+	return r, server
+}
+
+func (r *receiver) respond(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+	r.deliveries = nil
+	r.signatures = nil
+	r.bodies = nil
+}
+
+func (r *receiver) received() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.deliveries...)
+}
+
+// makeDue переносит время следующей попытки всех доставок в прошлое, как будто пауза уже прошла
+func makeDue(t *testing.T, db *database.DB) {
+	t.Helper()
+	if _, err := db.Exec("UPDATE webhook_deliveries SET next_attempt_at = ?", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// Эталон: echo -n '{"type":"leave.recorded"}' | openssl dgst -sha256 -hmac secret
+	got := Sign("secret", []byte(`{"type":"leave.recorded"}`))
+	want := "b57ef2b0d6cf5e56ba9de6f0ffb1df06e595bbccbc0d105acb20a72c871c3375"
+	if got != want {
+		t.Fatalf("Sign() = %s, want %s", got, want)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{9, 128 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, 6 * time.Hour},
+		{30, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestDeliveryIsSigned(t *testing.T) {
+	db := dbtest.New(t)
+	r, server := newReceiver(t)
+	webhook, err := db.AddWebhook(server.URL, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbtest.AddSubordinates(t, db, "Иванов Иван Иванович")
+
+	NewDispatcher(db).DeliverPending()
+
+	if len(r.bodies) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(r.bodies))
+	}
+	if want := "sha256=" + Sign(webhook.Secret, r.bodies[0]); r.signatures[0] != want {
+		t.Fatalf("signature %q does not match the body, want %q", r.signatures[0], want)
+	}
+}
+
+func TestFailedDeliveryHoldsBackLaterEvents(t *testing.T) {
+	db := dbtest.New(t)
+	r, server := newReceiver(t)
+	if _, err := db.AddWebhook(server.URL, 1); err != nil {
+		t.Fatal(err)
+	}
+	dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+	dispatcher := NewDispatcher(db)
+
+	// Первое событие не доставлено - второе в этом проходе не отправляется
+	r.respond(http.StatusInternalServerError)
+	dispatcher.DeliverPending()
+	first := r.received()
+	if len(first) != 1 {
+		t.Fatalf("expected the pass to stop after the first failure, got deliveries %v", first)
+	}
+
+	// Пока первое ждёт повтора, второе тоже ждёт, хотя его время наступило
+	r.respond(http.StatusOK)
+	dispatcher.DeliverPending()
+	if got := r.received(); len(got) != 0 {
+		t.Fatalf("expected deliveries behind a pending retry to wait, got %v", got)
+	}
+
+	// После паузы события уходят по порядку
+	makeDue(t, db)
+	dispatcher.DeliverPending()
+	got := r.received()
+	if len(got) != 2 || got[0] != first[0] || got[1] <= got[0] {
+		t.Fatalf("expected the retried delivery %d and then the next one, got %v", first[0], got)
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	db := dbtest.New(t)
+	r, server := newReceiver(t)
+	if _, err := db.AddWebhook(server.URL, 1); err != nil {
+		t.Fatal(err)
+	}
+	dbtest.AddSubordinates(t, db, "Иванов Иван Иванович", "Петров Петр Петрович")
+	dispatcher := NewDispatcher(db)
+
+	r.respond(http.StatusInternalServerError)
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		makeDue(t, db)
+		dispatcher.DeliverPending()
+	}
+
+	webhooks, err := db.GetWebhooks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webhooks[0].Failed != 1 || webhooks[0].Pending != 1 {
+		t.Fatalf("expected the first delivery to fail and the second to stay pending, got failed=%d pending=%d",
+			webhooks[0].Failed, webhooks[0].Pending)
+	}
+
+	// Неудавшееся событие больше не задерживает очередь
+	r.respond(http.StatusOK)
+	makeDue(t, db)
+	dispatcher.DeliverPending()
+	if got := r.received(); len(got) != 1 {
+		t.Fatalf("expected the next delivery to be sent after the failed one, got %v", got)
+	}
+}